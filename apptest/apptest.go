@@ -0,0 +1,102 @@
+// Package apptest provides helpers for testing Seatbelt applications,
+// middleware, and templates in isolation from the project tree.
+package apptest
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-seatbelt/seatbelt"
+
+	"github.com/gorilla/securecookie"
+)
+
+// drainTimeout is how long an application created with NewApp waits for its
+// in-flight requests when the test completes.
+const drainTimeout = 5 * time.Second
+
+// Options to configure an application created with NewApp.
+type Options struct {
+	// Templates maps template file paths, relative to the template directory,
+	// to their contents, i.e.,
+	//
+	//	map[string]string{
+	//		"layout.html":      `<main>{{ yield }}</main>`,
+	//		"users/index.html": `<h1>Users</h1>`,
+	//	}
+	Templates map[string]string
+
+	// Locales maps locale file names to their contents, i.e.,
+	//
+	//	map[string]string{
+	//		"active.en.json": `{"Greet": "Hello"}`,
+	//	}
+	Locales map[string]string
+
+	// Option is used as the base configuration of the application. Its
+	// TemplateDir, LocaleDir, and SigningKey are always overwritten, and
+	// static files are never served.
+	Option seatbelt.Option
+}
+
+// NewApp creates an application for use in tests. Templates and locales are
+// written to temporary directories that are removed when the test completes,
+// and the application is given its own random signing key, so no
+// "master.key" file is read or written. The application is drained when the
+// test completes, which stops its background tasks, i.e., session garbage
+// collection.
+//
+// Applications created with NewApp don't share templates, locales, or keys,
+// so it's safe to use NewApp in parallel tests. Package-level state, i.e.,
+// the clock set by seatbelt.TestMode and the standard logger, is still
+// shared, so tests that change it must not run in parallel.
+func NewApp(t testing.TB, opts ...Options) *seatbelt.App {
+	t.Helper()
+
+	var o Options
+	for _, opt := range opts {
+		o = opt
+	}
+
+	opt := o.Option
+	opt.TemplateDir = writeFiles(t, o.Templates)
+	opt.LocaleDir = ""
+	if len(o.Locales) > 0 {
+		opt.LocaleDir = writeFiles(t, o.Locales)
+	}
+	opt.SigningKey = hex.EncodeToString(securecookie.GenerateRandomKey(32))
+	opt.SkipServeFiles = true
+
+	app := seatbelt.New(opt)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+
+		if err := app.Drain(ctx); err != nil {
+			t.Errorf("apptest: failed to drain app: %v", err)
+		}
+	})
+	return app
+}
+
+// writeFiles writes the given files to a new temporary directory, and returns
+// the path to that directory.
+func writeFiles(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("apptest: failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("apptest: failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,75 @@
+package apptest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-seatbelt/seatbelt"
+)
+
+func TestNewApp(t *testing.T) {
+	t.Parallel()
+
+	app := NewApp(t, Options{
+		Templates: map[string]string{
+			"layout.html":      `<main>{{ yield }}</main>`,
+			"users/index.html": `<h1>{{ t "Greet" nil }}, {{ .Name }}</h1>`,
+		},
+		Locales: map[string]string{
+			"active.en.json": `{"Greet": "Hello"}`,
+		},
+	})
+
+	app.Get("/users", func(c *seatbelt.Context) error {
+		return c.Render("users/index", map[string]interface{}{"Name": "seatbelt"})
+	})
+
+	resp := app.Invoke(http.MethodGet, "/users", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	expected := "<main><h1>Hello, seatbelt</h1></main>"
+	if !strings.Contains(resp.String(), expected) {
+		t.Fatalf("expected %s to contain %s", resp.String(), expected)
+	}
+}
+
+func TestNewAppDrained(t *testing.T) {
+	t.Parallel()
+
+	var drained bool
+	t.Run("app", func(t *testing.T) {
+		app := NewApp(t)
+		app.OnDrain(func(ctx context.Context) error {
+			drained = true
+			return nil
+		})
+	})
+
+	if !drained {
+		t.Fatal("expected the app to be drained when the test completes")
+	}
+}
+
+func TestNewAppIsolated(t *testing.T) {
+	t.Parallel()
+
+	app1 := NewApp(t, Options{Templates: map[string]string{"layout.html": "{{ yield }}", "index.html": "one"}})
+	app2 := NewApp(t, Options{Templates: map[string]string{"layout.html": "{{ yield }}", "index.html": "two"}})
+
+	for _, app := range []*seatbelt.App{app1, app2} {
+		app.Get("/", func(c *seatbelt.Context) error {
+			return c.Render("index", nil)
+		})
+	}
+
+	if body := app1.Invoke(http.MethodGet, "/", nil).String(); body != "one" {
+		t.Fatalf("expected one but got %s", body)
+	}
+	if body := app2.Invoke(http.MethodGet, "/", nil).String(); body != "two" {
+		t.Fatalf("expected two but got %s", body)
+	}
+}
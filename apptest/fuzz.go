@@ -0,0 +1,152 @@
+package apptest
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// AddParamsCorpus adds seed inputs for fuzzing param binding to the given
+// fuzz target, as a content type, a raw query, and a body, i.e.,
+//
+//	func FuzzSignupParams(f *testing.F) {
+//		apptest.AddParamsCorpus(f)
+//		f.Fuzz(func(t *testing.T, contentType, query string, body []byte) {
+//			r := apptest.NewParamsRequest(contentType, query, body)
+//			var params SignupParams
+//			if err := handler.Params(httptest.NewRecorder(), r, nil, &params); err != nil {
+//				return
+//			}
+//			params.Validate()
+//		})
+//	}
+//
+// The seeds include malformed JSON, bodies that don't match their content
+// type, and huge keys and values.
+func AddParamsCorpus(f *testing.F) {
+	huge := strings.Repeat("k", 1<<16)
+
+	for _, seed := range []struct {
+		contentType string
+		query       string
+		body        string
+	}{
+		{"application/json", "", `{"name": "seatbelt", "age": 3}`},
+		{"application/json", "", `{"name": `},
+		{"application/json", "", `["name", "seatbelt"]`},
+		{"application/json", "", `{"name": {"first": ["sea", "belt"]}}`},
+		{"application/json", "", `{"age": 1e400}`},
+		{"application/json", "", `null`},
+		{"application/json", "", ""},
+		{"application/json", "name=seatbelt", "name=seatbelt"},
+		{"application/json", "", `{"` + huge + `": "` + huge + `"}`},
+		{"application/x-www-form-urlencoded", "", "name=seatbelt&age=3"},
+		{"application/x-www-form-urlencoded", "", "name=%zz&age"},
+		{"application/x-www-form-urlencoded", "", `{"name": "seatbelt"}`},
+		{"application/x-www-form-urlencoded", "", huge + "=" + huge},
+		{"application/x-www-form-urlencoded", "name=query", "name=body&name=again"},
+		{"multipart/form-data", "", "--boundary\r\n"},
+		{"multipart/form-data; boundary=boundary", "", "--boundary\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\nseatbelt\r\n--boundary--\r\n"},
+		{"text/plain", "name=seatbelt;age=3", "name=seatbelt"},
+		{"", "name=seatbelt&name=&age=x", ""},
+		{"", huge + "=1", ""},
+	} {
+		f.Add(seed.contentType, seed.query, []byte(seed.body))
+	}
+}
+
+// NewParamsRequest returns a POST request with the given content type, raw
+// query, and body, for use with the inputs of AddParamsCorpus.
+func NewParamsRequest(contentType, query string, body []byte) *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.URL.RawQuery = query
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// AddRoutingCorpus adds seed inputs for fuzzing an application's routes and
+// middleware to the given fuzz target, as a method and a request target, i.e.,
+//
+//	func FuzzRoutes(f *testing.F) {
+//		app := apptest.NewApp(f)
+//		routes(app)
+//
+//		apptest.AddRoutingCorpus(f, "/users/1", "/users/1/edit")
+//		f.Fuzz(func(t *testing.T, method, target string) {
+//			r, ok := apptest.NewRoutingRequest(method, target)
+//			if !ok {
+//				t.Skip()
+//			}
+//			w := httptest.NewRecorder()
+//			app.ServeHTTP(w, r)
+//			if w.Code >= 500 {
+//				t.Fatalf("%s %s responded with %d", method, target, w.Code)
+//			}
+//		})
+//	}
+//
+// The given paths are added with each common method, alongside seeds with
+// malformed escapes, dot segments, and huge paths.
+func AddRoutingCorpus(f *testing.F, paths ...string) {
+	targets := append([]string{
+		"/",
+		"//",
+		"/%zz",
+		"/%2F%2F",
+		"/./../..",
+		"/users/../../etc/passwd",
+		"/users/%00",
+		"/users/1?name=%zz&&=",
+		"/users/" + strings.Repeat("9", 64),
+		"/" + strings.Repeat("a/", 1<<10),
+		"*",
+	}, paths...)
+
+	for _, method := range []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodOptions,
+	} {
+		for _, target := range targets {
+			f.Add(method, target)
+		}
+	}
+	f.Add("get", "/")
+	f.Add("PROPFIND", "/")
+}
+
+// NewRoutingRequest returns a request with the given method and target, for
+// use with the inputs of AddRoutingCorpus. It returns false for inputs that
+// the HTTP server would reject before they reach the application, i.e., a
+// method that isn't a token, or a target that isn't a path.
+func NewRoutingRequest(method, target string) (*http.Request, bool) {
+	if method == "" || strings.IndexFunc(method, func(r rune) bool {
+		return r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+	}) != -1 {
+		return nil, false
+	}
+	if target != "*" && !strings.HasPrefix(target, "/") {
+		return nil, false
+	}
+
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, false
+	}
+
+	r, err := http.NewRequest(method, "/", nil)
+	if err != nil {
+		return nil, false
+	}
+	r.URL = u
+	r.RequestURI = target
+	return r, true
+}
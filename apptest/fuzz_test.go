@@ -0,0 +1,58 @@
+package apptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-seatbelt/seatbelt"
+)
+
+func FuzzRouting(f *testing.F) {
+	app := NewApp(f, Options{
+		Templates: map[string]string{
+			"layout.html":     `{{ yield }}`,
+			"users/show.html": `<h1>{{ .User.ID }} {{ .User.Name }}</h1>`,
+		},
+	})
+	app.Use(func(fn func(c *seatbelt.Context) error) func(*seatbelt.Context) error {
+		return func(c *seatbelt.Context) error {
+			c.Response().Header().Set("X-Path", c.Request().URL.Path)
+			return fn(c)
+		}
+	})
+
+	app.Get("/", func(c *seatbelt.Context) error {
+		return c.String(http.StatusOK, "home")
+	})
+	app.Get("/users/{id:int}", func(c *seatbelt.Context) error {
+		var user struct {
+			ID   int    `params:"id"`
+			Name string `params:"name"`
+		}
+		if err := c.Params(&user); err != nil {
+			return seatbelt.WrapError(err, http.StatusBadRequest, "")
+		}
+		return c.Render("users/show", map[string]interface{}{"User": user})
+	})
+	app.Post("/users/{id:int}", func(c *seatbelt.Context) error {
+		return c.NoContent()
+	})
+	app.Get("/files/*", func(c *seatbelt.Context) error {
+		return c.String(http.StatusOK, c.PathParam("*"))
+	})
+
+	AddRoutingCorpus(f, "/users/1", "/users/abc", "/users/1?name=seatbelt", "/files/a/b/c")
+
+	f.Fuzz(func(t *testing.T, method, target string) {
+		r, ok := NewRoutingRequest(method, target)
+		if !ok {
+			t.Skip()
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code >= 500 {
+			t.Fatalf("expected %s %s to succeed but got %d", method, target, w.Code)
+		}
+	})
+}
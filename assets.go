@@ -0,0 +1,281 @@
+package seatbelt
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AssetOptions configure the "javascriptTag" and "stylesheetTag" template
+// funcs, which reference the assets in the project's /public directory.
+type AssetOptions struct {
+	// Manifest is the path of a JSON file that maps the names of assets to
+	// their fingerprinted file names, as written by most bundlers, i.e.,
+	//
+	//	{"app.js": "app.3f2a9c1b.js"}
+	//
+	// An entry can also be an object with a "file" and an "integrity"
+	// field, i.e., to use the integrity hash computed at build time.
+	// Default is "", meaning assets are referenced by their name.
+	Manifest string
+
+	// Dir is the directory that the files of assets are read from, in order
+	// to compute their integrity hashes. Default is "public".
+	Dir string
+
+	// Host is the origin that assets are served from, i.e.,
+	// "https://cdn.example.com". Default is "", meaning assets are served
+	// by the application at /public.
+	Host string
+
+	// Defer adds the defer attribute to all script tags, unless a tag is
+	// given "defer=false". Default is false.
+	Defer bool
+
+	// Preload sends a preload hint for all assets in the Link header of the
+	// response, so that browsers fetch them before they parse the page,
+	// unless a tag is given "preload=false". Default is false.
+	Preload bool
+}
+
+// assetTagOptions are the options given to an asset tag template func.
+type assetTagOptions struct {
+	deferred bool
+	async    bool
+	module   bool
+	preload  bool
+}
+
+// parseAssetTagOptions parses the given options of an asset tag, i.e.,
+// "defer" or "preload=false", starting with the application's defaults.
+func (as *assets) parseAssetTagOptions(args []string) (assetTagOptions, error) {
+	o := assetTagOptions{deferred: as.opts.Defer, preload: as.opts.Preload}
+	for _, arg := range args {
+		key, value := arg, "true"
+		if i := strings.IndexByte(arg, '='); i != -1 {
+			key, value = arg[:i], arg[i+1:]
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return o, fmt.Errorf("seatbelt: invalid asset tag option %s: %w", arg, err)
+		}
+
+		switch key {
+		case "defer":
+			o.deferred = enabled
+		case "async":
+			o.async = enabled
+		case "module":
+			o.module = enabled
+		case "preload":
+			o.preload = enabled
+		default:
+			return o, fmt.Errorf("seatbelt: unknown asset tag option %s", key)
+		}
+	}
+	return o, nil
+}
+
+// An assetEntry is the fingerprinted file name of an asset, and its
+// subresource integrity hash.
+type assetEntry struct {
+	File      string `json:"file"`
+	Integrity string `json:"integrity"`
+}
+
+func (e *assetEntry) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &e.File)
+	}
+
+	type entry assetEntry
+	return json.Unmarshal(b, (*entry)(e))
+}
+
+// assets resolves the names of assets to their URLs and integrity hashes.
+type assets struct {
+	dir    string
+	opts   AssetOptions
+	reload bool
+
+	mu        sync.Mutex
+	manifest  map[string]assetEntry
+	integrity map[string]string
+}
+
+// newAssets returns the assets configured by the given options.
+func newAssets(opts AssetOptions, reload bool) *assets {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "public"
+	}
+	return &assets{dir: dir, opts: opts, reload: reload}
+}
+
+// load reads the manifest, if it hasn't been read yet, or on every call in
+// Reload mode.
+func (as *assets) load() error {
+	if as.manifest != nil && !as.reload {
+		return nil
+	}
+
+	as.manifest = make(map[string]assetEntry)
+	as.integrity = make(map[string]string)
+	if as.opts.Manifest == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(as.opts.Manifest)
+	if err != nil {
+		return fmt.Errorf("seatbelt: failed to read asset manifest: %w", err)
+	}
+	if err := json.Unmarshal(b, &as.manifest); err != nil {
+		return fmt.Errorf("seatbelt: failed to parse asset manifest %s: %w", as.opts.Manifest, err)
+	}
+	return nil
+}
+
+// resolve returns the URL and the subresource integrity hash of the asset
+// with the given name. A name without an extension, i.e., "app", is given the
+// extension ext. Hashes that aren't in the manifest are computed from the
+// file once, and then cached.
+func (as *assets) resolve(name, ext string) (string, string, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if err := as.load(); err != nil {
+		return "", "", err
+	}
+
+	if path.Ext(name) == "" {
+		if _, ok := as.manifest[name]; !ok {
+			name += ext
+		}
+	}
+	entry, ok := as.manifest[name]
+	if !ok {
+		entry.File = name
+	}
+	file := strings.TrimPrefix(entry.File, "/")
+
+	integrity := entry.Integrity
+	if integrity == "" {
+		if integrity, ok = as.integrity[file]; !ok {
+			var err error
+			if integrity, err = fileIntegrity(filepath.Join(as.dir, filepath.FromSlash(file))); err != nil {
+				return "", "", err
+			}
+			as.integrity[file] = integrity
+		}
+	}
+
+	return strings.TrimSuffix(as.opts.Host, "/") + path.Join("/public", file), integrity, nil
+}
+
+// fileIntegrity returns the subresource integrity hash of the file at the
+// given path.
+func fileIntegrity(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("seatbelt: failed to compute integrity of asset: %w", err)
+	}
+	defer f.Close()
+
+	h := sha512.New384()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha384-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// javascriptTag returns the script tag of the JavaScript asset with the
+// given name, with its integrity hash, i.e.,
+//
+//	{{ javascriptTag "app" "defer" "preload" }}
+//
+// The options are "defer", "async", "module", and "preload", and can be
+// turned off with "=false", i.e., "defer=false".
+func (as *assets) javascriptTag(w http.ResponseWriter, name string, args ...string) (template.HTML, error) {
+	o, err := as.parseAssetTagOptions(args)
+	if err != nil {
+		return "", err
+	}
+	src, integrity, err := as.resolve(name, ".js")
+	if err != nil {
+		return "", err
+	}
+
+	if o.preload {
+		if o.module {
+			preloadHint(w, src, "modulepreload", "", integrity)
+		} else {
+			preloadHint(w, src, "preload", "script", integrity)
+		}
+	}
+
+	var attrs string
+	if o.module {
+		attrs += ` type="module"`
+	}
+	if o.deferred && !o.module {
+		attrs += " defer"
+	}
+	if o.async {
+		attrs += " async"
+	}
+	return template.HTML(`<script src="` + template.HTMLEscapeString(src) + `"` + attrs + integrityAttrs(integrity) + `></script>`), nil
+}
+
+// stylesheetTag returns the link tag of the stylesheet asset with the given
+// name, with its integrity hash, i.e.,
+//
+//	{{ stylesheetTag "app" }}
+//
+// The only option is "preload".
+func (as *assets) stylesheetTag(w http.ResponseWriter, name string, args ...string) (template.HTML, error) {
+	o, err := as.parseAssetTagOptions(args)
+	if err != nil {
+		return "", err
+	}
+	href, integrity, err := as.resolve(name, ".css")
+	if err != nil {
+		return "", err
+	}
+
+	if o.preload {
+		preloadHint(w, href, "preload", "style", integrity)
+	}
+	return template.HTML(`<link rel="stylesheet" href="` + template.HTMLEscapeString(href) + `"` + integrityAttrs(integrity) + `>`), nil
+}
+
+// preloadHint adds a preload hint for the asset at the given URL to the Link
+// header of the response. Hints are ignored once the header is written, i.e.,
+// for streamed pages.
+func preloadHint(w http.ResponseWriter, url, rel, as, integrity string) {
+	if w == nil {
+		return
+	}
+	link := "<" + url + ">; rel=" + rel
+	if as != "" {
+		link += "; as=" + as
+	}
+	link += `; integrity="` + integrity + `"; crossorigin=anonymous`
+	w.Header().Add("Link", link)
+}
+
+// integrityAttrs returns the integrity and crossorigin attributes for the
+// given hash. Browsers only check the integrity of cross-origin assets
+// fetched with CORS.
+func integrityAttrs(integrity string) string {
+	return ` integrity="` + template.HTMLEscapeString(integrity) + `" crossorigin="anonymous"`
+}
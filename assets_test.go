@@ -0,0 +1,112 @@
+package seatbelt
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssetTags(t *testing.T) {
+	sum := sha512.Sum384([]byte("console.log(\"app\");\n"))
+	appIntegrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	as := newAssets(AssetOptions{
+		Manifest: filepath.Join("testdata", "assets", "manifest.json"),
+		Dir:      filepath.Join("testdata", "assets"),
+		Host:     "https://cdn.example.com/",
+	}, false)
+
+	t.Run("compute the integrity of fingerprinted assets", func(t *testing.T) {
+		tag, err := as.javascriptTag(nil, "app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `<script src="https://cdn.example.com/public/app.3f2a9c1b.js" integrity="` + appIntegrity + `" crossorigin="anonymous"></script>`
+		if string(tag) != expected {
+			t.Fatalf("expected %s but got %s", expected, tag)
+		}
+	})
+
+	t.Run("use the integrity from the manifest", func(t *testing.T) {
+		tag, err := as.javascriptTag(nil, "vendor.js")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `<script src="https://cdn.example.com/public/vendor.9e8d7c6b.js" integrity="sha384-fromthebuild" crossorigin="anonymous"></script>`
+		if string(tag) != expected {
+			t.Fatalf("expected %s but got %s", expected, tag)
+		}
+	})
+
+	t.Run("reference assets missing from the manifest by name", func(t *testing.T) {
+		tag, err := as.stylesheetTag(nil, "app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha512.Sum384([]byte("body { margin: 0; }\n"))
+		expected := `<link rel="stylesheet" href="https://cdn.example.com/public/app.css" integrity="sha384-` + base64.StdEncoding.EncodeToString(sum[:]) + `" crossorigin="anonymous">`
+		if string(tag) != expected {
+			t.Fatalf("expected %s but got %s", expected, tag)
+		}
+	})
+
+	t.Run("defer and preload scripts", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		tag, err := as.javascriptTag(rr, "app", "defer", "preload")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(tag), `<script src="https://cdn.example.com/public/app.3f2a9c1b.js" defer integrity=`) {
+			t.Fatalf("expected a deferred script but got %s", tag)
+		}
+		expected := `<https://cdn.example.com/public/app.3f2a9c1b.js>; rel=preload; as=script; integrity="` + appIntegrity + `"; crossorigin=anonymous`
+		if link := rr.Header().Get("Link"); link != expected {
+			t.Fatalf("expected %s but got %s", expected, link)
+		}
+	})
+
+	t.Run("override the default policies", func(t *testing.T) {
+		as := newAssets(AssetOptions{Dir: filepath.Join("testdata", "assets"), Defer: true, Preload: true}, false)
+		rr := httptest.NewRecorder()
+		tag, err := as.javascriptTag(rr, "app.3f2a9c1b.js", "defer=false", "preload=false", "async")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(tag), `app.3f2a9c1b.js" async integrity=`) || rr.Header().Get("Link") != "" {
+			t.Fatalf("expected an async script without a preload hint but got %s", tag)
+		}
+
+		if _, err := as.javascriptTag(rr, "app", "prefetch"); err == nil {
+			t.Fatalf("expected an error for an unknown option")
+		}
+	})
+
+	t.Run("missing asset", func(t *testing.T) {
+		if _, err := as.stylesheetTag(nil, "missing.css"); err == nil {
+			t.Fatalf("expected an error for a missing asset")
+		}
+	})
+}
+
+func TestAssetTagsRendered(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+		Assets:         AssetOptions{Dir: filepath.Join("testdata", "assets")},
+	})
+	app.Get("/", func(c *Context) error {
+		return c.Render("assets", nil)
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil)
+	if !strings.Contains(resp.String(), `<link rel="stylesheet" href="/public/app.css" integrity="sha384-`) {
+		t.Fatalf("expected the stylesheet tag but got %s", resp.String())
+	}
+	if link := resp.Header.Get("Link"); !strings.HasPrefix(link, "</public/app.css>; rel=preload; as=style") {
+		t.Fatalf("expected a preload hint but got %s", link)
+	}
+}
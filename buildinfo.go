@@ -0,0 +1,87 @@
+package seatbelt
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// The following variables are populated at link time, i.e.,
+//
+//	go build -ldflags "\
+//		-X github.com/go-seatbelt/seatbelt.buildVersion=v1.2.3 \
+//		-X github.com/go-seatbelt/seatbelt.buildCommit=$(git rev-parse HEAD) \
+//		-X github.com/go-seatbelt/seatbelt.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// The commit and build time fall back to the VCS information embedded by the
+// Go toolchain when they aren't set.
+var (
+	buildVersion string
+	buildCommit  string
+	buildTime    string
+)
+
+// Build describes the build of the running application.
+type Build struct {
+	// The version of the application, or "dev" if it isn't set.
+	Version string `json:"version"`
+
+	// The VCS revision the application was built from.
+	Commit string `json:"commit"`
+
+	// The time the application was built, or the time of the commit it was
+	// built from.
+	Time string `json:"time"`
+
+	// The Go version used to build the application.
+	GoVersion string `json:"go_version"`
+
+	// The version of Seatbelt.
+	Seatbelt string `json:"seatbelt"`
+}
+
+// String returns a short, human readable description of the build.
+func (b Build) String() string {
+	s := b.Version
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " (" + commit + ")"
+	}
+	return s
+}
+
+// BuildInfo returns information about the build of the running application.
+func BuildInfo() Build {
+	b := Build{
+		Version:   buildVersion,
+		Commit:    buildCommit,
+		Time:      buildTime,
+		GoVersion: runtime.Version(),
+		Seatbelt:  Version,
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = setting.Value
+				}
+			case "vcs.time":
+				if b.Time == "" {
+					b.Time = setting.Value
+				}
+			}
+		}
+		if b.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+	}
+
+	if b.Version == "" {
+		b.Version = "dev"
+	}
+	return b
+}
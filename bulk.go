@@ -0,0 +1,201 @@
+package seatbelt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// defaultBulkLimit is the default maximum number of items in a bulk action.
+const defaultBulkLimit = 1000
+
+// A BulkAction configures an endpoint that performs one of several actions on
+// a list of items at once, i.e., to archive or delete the users selected in a
+// table.
+type BulkAction struct {
+	// Actions maps the name of each action to the handler that performs it
+	// on a single item.
+	Actions map[string]func(c *Context, id string) error
+
+	// Authorize returns an error if the user may not perform the action on
+	// the item with the given ID, in which case the item is skipped. Default
+	// is nil, meaning every item is authorized.
+	Authorize func(c *Context, action, id string) error
+
+	// Template is the template the report is rendered with, without a
+	// layout, as "Report", i.e., "users/_bulk_report". Default is "",
+	// meaning the outcome is flashed and the user is redirected back.
+	// Requests that accept JSON are always responded to with the report as
+	// JSON.
+	Template string
+
+	// Limit is the maximum number of items in a request. Default is 1000.
+	Limit int
+}
+
+// A BulkReport is the outcome of a bulk action.
+type BulkReport struct {
+	Action    string       `json:"action"`
+	Results   []BulkResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+}
+
+// A BulkResult is the outcome of a bulk action for a single item. Error is
+// the message shown to the user, which is only the error's own message for
+// errors wrapped with WrapError.
+type BulkResult struct {
+	ID    string `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// bulkRequest is the action and item IDs submitted to a bulk action.
+type bulkRequest struct {
+	Action string   `json:"action"`
+	IDs    []bulkID `json:"ids"`
+}
+
+// A bulkID is an item ID given as a JSON string or number.
+type bulkID string
+
+func (id *bulkID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*id = bulkID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.New("seatbelt: bulk action IDs must be strings or numbers")
+	}
+	*id = bulkID(n)
+	return nil
+}
+
+// Bulk registers a POST route at the given path that performs an action on
+// each of the items submitted to it, i.e.,
+//
+//	app.Bulk("/users/bulk", seatbelt.BulkAction{
+//		Actions: map[string]func(c *seatbelt.Context, id string) error{
+//			"archive": archiveUser,
+//			"delete":  deleteUser,
+//		},
+//		Authorize: canManageUser,
+//		Template:  "users/_bulk_report",
+//	})
+//
+// The action and IDs are submitted as the form values "action" and "ids",
+// which can be repeated, or as a JSON object with the same keys. Each item is
+// authorized and handled independently, so that one failed item doesn't stop
+// the others, and the outcome of every item is reported.
+func (a *App) Bulk(path string, b BulkAction) *Route {
+	return a.Post(path, func(c *Context) error {
+		return a.bulk(c, b)
+	})
+}
+
+// bulk performs the bulk action submitted in the request.
+func (a *App) bulk(c *Context, b BulkAction) error {
+	req, err := parseBulkRequest(c.r)
+	if err != nil {
+		return WrapError(err, http.StatusBadRequest, "")
+	}
+
+	limit := b.Limit
+	if limit == 0 {
+		limit = defaultBulkLimit
+	}
+	if len(req.IDs) > limit {
+		return WrapError(fmt.Errorf("seatbelt: bulk action has %d items, more than the limit of %d", len(req.IDs), limit), http.StatusRequestEntityTooLarge, "")
+	}
+
+	handle, ok := b.Actions[req.Action]
+	if !ok {
+		return WrapError(fmt.Errorf("seatbelt: unknown bulk action '%s'", req.Action), http.StatusBadRequest, "")
+	}
+
+	report := BulkReport{Action: req.Action, Results: make([]BulkResult, 0, len(req.IDs))}
+	for _, raw := range req.IDs {
+		id := string(raw)
+		result := BulkResult{ID: id, OK: true}
+
+		var err error
+		if b.Authorize != nil {
+			if err = b.Authorize(c, req.Action, id); err != nil && !isError(err) {
+				err = WrapError(err, http.StatusForbidden, "")
+			}
+		}
+		if err == nil {
+			err = handle(c, id)
+		}
+		if err != nil {
+			log.Printf("seatbelt: bulk action %s failed for %s: %s", req.Action, id, a.Redact(causeChain(err)))
+			result.OK = false
+			result.Error = http.StatusText(http.StatusInternalServerError)
+			var e *Error
+			if errors.As(err, &e) {
+				result.Error = e.userMessage(c)
+			}
+		}
+
+		if result.OK {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	switch {
+	case acceptsJSON(c.r):
+		return c.JSON(http.StatusOK, report)
+	case b.Template != "":
+		return c.RenderPartial(b.Template, map[string]interface{}{"Report": report})
+	}
+
+	if report.Failed > 0 {
+		c.Flash.Alert(fmt.Sprintf("%d of %d items failed", report.Failed, len(report.Results)))
+	} else {
+		c.Flash.Notice(fmt.Sprintf("%d items updated", report.Succeeded))
+	}
+	from := c.r.Referer()
+	if from == "" {
+		from = "/"
+	}
+	return c.Redirect(from)
+}
+
+// isError returns true if the given error was wrapped with WrapError.
+func isError(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
+
+// parseBulkRequest reads the action and item IDs from the JSON body or form
+// values of the request.
+func parseBulkRequest(r *http.Request) (bulkRequest, error) {
+	var req bulkRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("seatbelt: invalid bulk action: %w", err)
+		}
+		return req, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return req, err
+	}
+	req.Action = r.PostForm.Get("action")
+	for _, key := range []string{"ids", "ids[]"} {
+		for _, id := range r.PostForm[key] {
+			if id = strings.TrimSpace(id); id != "" {
+				req.IDs = append(req.IDs, bulkID(id))
+			}
+		}
+	}
+	return req, nil
+}
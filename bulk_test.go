@@ -0,0 +1,84 @@
+package seatbelt
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBulk(t *testing.T) {
+	var archived []string
+	app := New(Option{SkipServeFiles: true})
+	app.Bulk("/users/bulk", BulkAction{
+		Actions: map[string]func(c *Context, id string) error{
+			"archive": func(c *Context, id string) error {
+				if id == "3" {
+					return errors.New("database is down")
+				}
+				archived = append(archived, id)
+				return nil
+			},
+		},
+		Authorize: func(c *Context, action, id string) error {
+			if id == "2" {
+				return errors.New("not the owner")
+			}
+			return nil
+		},
+	})
+
+	t.Run("report the outcome of each item as JSON", func(t *testing.T) {
+		archived = nil
+		resp := app.Invoke(http.MethodPost, "/users/bulk", strings.NewReader(`{"action": "archive", "ids": [1, "2", 3]}`), InvokeOptions{
+			Headers:  map[string]string{"Content-Type": "application/json", "Accept": "application/json"},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected %d but got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var report BulkReport
+		if err := resp.JSON(&report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Succeeded != 1 || report.Failed != 2 {
+			t.Fatalf("expected 1 item to succeed and 2 to fail but got %+v", report)
+		}
+		if r := report.Results[1]; r.ID != "2" || r.OK || r.Error != "Forbidden" {
+			t.Fatalf("expected item 2 to be forbidden but got %+v", r)
+		}
+		if r := report.Results[2]; r.Error != "Internal Server Error" {
+			t.Fatalf("expected the error of item 3 to be hidden but got %+v", r)
+		}
+		if len(archived) != 1 || archived[0] != "1" {
+			t.Fatalf("expected only item 1 to be archived but got %v", archived)
+		}
+	})
+
+	t.Run("bind repeated form values and redirect back", func(t *testing.T) {
+		archived = nil
+		form := url.Values{"action": {"archive"}, "ids[]": {"1", "4"}}
+		resp := app.Invoke(http.MethodPost, "/users/bulk", strings.NewReader(form.Encode()), InvokeOptions{
+			Headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded", "Referer": "/users"},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode != http.StatusFound && resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected a redirect but got %d", resp.StatusCode)
+		}
+		if len(archived) != 2 {
+			t.Fatalf("expected 2 items to be archived but got %v", archived)
+		}
+	})
+
+	t.Run("reject unknown actions", func(t *testing.T) {
+		resp := app.Invoke(http.MethodPost, "/users/bulk", strings.NewReader(`{"action": "delete", "ids": [1]}`), InvokeOptions{
+			Headers:  map[string]string{"Content-Type": "application/json", "Referer": "/users"},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode == http.StatusOK {
+			t.Fatalf("expected the unknown action to be rejected")
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Package captcha provides verification of CAPTCHA challenges issued by
+// hCaptcha, Cloudflare Turnstile, and Google reCAPTCHA.
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrVerificationFailed is returned by Verify when the provider rejects the
+// CAPTCHA response, or when the request does not contain one at all.
+var ErrVerificationFailed = errors.New("captcha: verification failed, please try again")
+
+// A Provider describes how to render and verify the widget of a CAPTCHA
+// service.
+type Provider struct {
+	// The URL of the JavaScript file that renders the widget.
+	ScriptURL string
+
+	// The CSS class of the element the script replaces with the widget.
+	WidgetClass string
+
+	// The name of the form field the widget submits its response in.
+	ResponseField string
+
+	// The URL the response is verified against.
+	VerifyURL string
+}
+
+var (
+	// HCaptcha is the provider for https://www.hcaptcha.com.
+	HCaptcha = Provider{
+		ScriptURL:     "https://js.hcaptcha.com/1/api.js",
+		WidgetClass:   "h-captcha",
+		ResponseField: "h-captcha-response",
+		VerifyURL:     "https://api.hcaptcha.com/siteverify",
+	}
+
+	// Turnstile is the provider for Cloudflare Turnstile.
+	Turnstile = Provider{
+		ScriptURL:     "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		WidgetClass:   "cf-turnstile",
+		ResponseField: "cf-turnstile-response",
+		VerifyURL:     "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	}
+
+	// ReCAPTCHA is the provider for Google reCAPTCHA v2.
+	ReCAPTCHA = Provider{
+		ScriptURL:     "https://www.google.com/recaptcha/api.js",
+		WidgetClass:   "g-recaptcha",
+		ResponseField: "g-recaptcha-response",
+		VerifyURL:     "https://www.google.com/recaptcha/api/siteverify",
+	}
+)
+
+// Options to configure a CAPTCHA verifier.
+type Options struct {
+	// The CAPTCHA service to use. Required.
+	Provider Provider
+
+	// The public site key rendered in the widget.
+	SiteKey string
+
+	// The secret key used to verify responses.
+	Secret string
+
+	// The HTTP client used to call the provider. Default is a client with a
+	// 10 second timeout.
+	Client *http.Client
+}
+
+// A Captcha renders CAPTCHA widgets and verifies their responses.
+type Captcha struct {
+	provider Provider
+	siteKey  string
+	secret   string
+	client   *http.Client
+}
+
+// New creates a new CAPTCHA verifier from the given options.
+func New(o Options) *Captcha {
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Captcha{
+		provider: o.Provider,
+		siteKey:  o.SiteKey,
+		secret:   o.Secret,
+		client:   o.Client,
+	}
+}
+
+// Widget returns the HTML that renders the CAPTCHA widget inside of a form.
+func (c *Captcha) Widget() template.HTML {
+	return template.HTML(fmt.Sprintf(
+		`<script src="%s" async defer></script><div class="%s" data-sitekey="%s"></div>`,
+		template.HTMLEscapeString(c.provider.ScriptURL),
+		template.HTMLEscapeString(c.provider.WidgetClass),
+		template.HTMLEscapeString(c.siteKey),
+	))
+}
+
+// verifyResponse is the response body shared by all supported providers.
+type verifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify verifies the CAPTCHA response submitted with the given request. The
+// remoteIP is optional, and is forwarded to the provider when given.
+//
+// If the provider rejects the response, ErrVerificationFailed is returned. Any
+// other error means that the provider could not be reached.
+func (c *Captcha) Verify(r *http.Request, remoteIP string) error {
+	response := r.FormValue(c.provider.ResponseField)
+	if response == "" {
+		return ErrVerificationFailed
+	}
+
+	form := url.Values{
+		"secret":   {c.secret},
+		"response": {response},
+		"sitekey":  {c.siteKey},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	resp, err := c.client.Post(c.provider.VerifyURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("captcha: failed to verify response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha: unexpected status code %d from provider", resp.StatusCode)
+	}
+
+	var v verifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return fmt.Errorf("captcha: failed to decode provider response: %w", err)
+	}
+	if !v.Success {
+		return ErrVerificationFailed
+	}
+	return nil
+}
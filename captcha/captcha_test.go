@@ -0,0 +1,83 @@
+package captcha
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") != "secret" {
+			t.Errorf("expected secret to be forwarded but got %s", r.FormValue("secret"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("response") == "solved" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer provider.Close()
+
+	p := Turnstile
+	p.VerifyURL = provider.URL
+
+	verifier := New(Options{
+		Provider: p,
+		SiteKey:  "sitekey",
+		Secret:   "secret",
+	})
+
+	cases := []struct {
+		name     string
+		response string
+		err      error
+	}{
+		{
+			name:     "a solved challenge should verify",
+			response: "solved",
+			err:      nil,
+		},
+		{
+			name:     "a rejected challenge should fail",
+			response: "rejected",
+			err:      ErrVerificationFailed,
+		},
+		{
+			name:     "a missing response should fail",
+			response: "",
+			err:      ErrVerificationFailed,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			form := url.Values{p.ResponseField: {c.response}}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if err := verifier.Verify(r, "127.0.0.1"); !errors.Is(err, c.err) {
+				t.Fatalf("expected %v but got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestWidget(t *testing.T) {
+	t.Parallel()
+
+	c := New(Options{Provider: HCaptcha, SiteKey: "sitekey"})
+
+	html := string(c.Widget())
+	contains := `<div class="h-captcha" data-sitekey="sitekey"></div>`
+	if !strings.Contains(html, contains) {
+		t.Fatalf("expected %s to contain %s", html, contains)
+	}
+}
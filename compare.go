@@ -0,0 +1,228 @@
+package seatbelt
+
+import (
+	"bytes"
+	stdcontext "context"
+	"html/template"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-seatbelt/seatbelt/render"
+	"github.com/gorilla/csrf"
+)
+
+// CompareOptions configure a render comparison started with CompareRender.
+type CompareOptions struct {
+	// SampleRate is the fraction of renders of the template that are
+	// compared, between 0 and 1, i.e., 0.05 to compare one in twenty.
+	// Default is 0, meaning all renders are compared.
+	SampleRate float64
+
+	// OnMismatch is called when the candidate's output differs from the
+	// template's, or when the candidate fails to render, instead of logging
+	// a warning, i.e., in order to forward mismatches to an error tracker.
+	OnMismatch func(m RenderMismatch)
+}
+
+// A RenderMismatch is a difference between the output of a template and that
+// of its candidate.
+type RenderMismatch struct {
+	// The names of the template and of its candidate.
+	Template  string
+	Candidate string
+
+	// The request that the template was rendered for.
+	Request *http.Request
+
+	// The number of the first line that differs, starting at 1, and that
+	// line in the output of the template and of the candidate.
+	Line     int
+	Expected string
+	Actual   string
+
+	// The error the candidate failed to render with, if any.
+	Err error
+}
+
+// comparisons holds the candidates of the templates whose renders are
+// compared, shared between all namespaces.
+type comparisons struct {
+	mu         sync.RWMutex
+	candidates map[string]comparison
+}
+
+// A comparison is the candidate of a template, and its options.
+type comparison struct {
+	candidate string
+	opts      CompareOptions
+}
+
+// sample returns the comparison of the template with the given name, if its
+// renders are compared and this render is sampled.
+func (cs *comparisons) sample(name string) (comparison, bool) {
+	if cs == nil {
+		return comparison{}, false
+	}
+
+	cs.mu.RLock()
+	cmp, ok := cs.candidates[name]
+	cs.mu.RUnlock()
+
+	if !ok || (cmp.opts.SampleRate > 0 && rand.Float64() >= cmp.opts.SampleRate) {
+		return comparison{}, false
+	}
+	return cmp, true
+}
+
+// CompareRender dark-launches a candidate for the template with the given
+// name, i.e., a refactored copy of a critical page. Whenever c.Render renders
+// the template, the candidate is rendered with the same data after the
+// response has been written. Its output is discarded, and a warning is
+// logged if it differs from the template's, i.e.,
+//
+//	app.CompareRender("checkout/show", "checkout/show_v2", seatbelt.CompareOptions{
+//		SampleRate: 0.1,
+//	})
+//
+// The candidate adds the time it takes to render to each compared request,
+// so use SampleRate for busy pages. The "csrf" and "csrfMetaTags" funcs
+// render the same CSRF token for the template and its candidate, so that
+// forms don't show up as mismatches. Other template funcs with side effects,
+// i.e., "flashes", return different results for the candidate.
+func (a *App) CompareRender(template, candidate string, opts ...CompareOptions) {
+	var o CompareOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	a.comparisons.mu.Lock()
+	defer a.comparisons.mu.Unlock()
+
+	if a.comparisons.candidates == nil {
+		a.comparisons.candidates = make(map[string]comparison)
+	}
+	a.comparisons.candidates[template] = comparison{candidate: candidate, opts: o}
+}
+
+// comparedCSRFKey is the context key of the CSRF token that is rendered by
+// both a template and its candidate.
+type comparedCSRFKey struct{}
+
+// A comparedCSRF is the CSRF field and token of a compared render.
+type comparedCSRF struct {
+	field template.HTML
+	token string
+}
+
+// csrfField returns the hidden form field of the request's CSRF token, or
+// that of the compared render.
+func csrfField(r *http.Request) template.HTML {
+	if v, ok := r.Context().Value(comparedCSRFKey{}).(comparedCSRF); ok {
+		return v.field
+	}
+	return csrf.TemplateField(r)
+}
+
+// csrfToken returns the request's CSRF token, or that of the compared
+// render.
+func csrfToken(r *http.Request) string {
+	if v, ok := r.Context().Value(comparedCSRFKey{}).(comparedCSRF); ok {
+		return v.token
+	}
+	return csrf.Token(r)
+}
+
+// renderCompared renders the template with the given name to the response,
+// and then its candidate to a buffer, and reports a mismatch of the two.
+//
+// Both are rendered with the CSRF token that was masked for the template, so
+// that forms match even if the token is masked anew on each call.
+func (c *context) renderCompared(cmp comparison, name string, data map[string]interface{}, o render.RenderOptions) error {
+	r := c.r.WithContext(stdcontext.WithValue(c.r.Context(), comparedCSRFKey{}, comparedCSRF{
+		field: csrf.TemplateField(c.r),
+		token: csrf.Token(c.r),
+	}))
+
+	tee := &teeResponseWriter{ResponseWriter: c.w}
+	if err := c.renderer.HTML(tee, r, name, data, o); err != nil {
+		return err
+	}
+	if tee.status == http.StatusNotModified {
+		// The client's copy is still fresh, so the page wasn't sent.
+		return nil
+	}
+
+	var candidate bytes.Buffer
+	rs := &responseStaller{header: c.w.Header().Clone(), w: &candidate}
+	err := c.renderer.HTML(rs, r, cmp.candidate, data, o)
+
+	m := RenderMismatch{Template: name, Candidate: cmp.candidate, Request: c.r, Err: err}
+	if err == nil {
+		var differs bool
+		if m.Line, m.Expected, m.Actual, differs = firstDifference(tee.buf.String(), candidate.String()); !differs {
+			return nil
+		}
+	}
+
+	if cmp.opts.OnMismatch != nil {
+		cmp.opts.OnMismatch(m)
+		return nil
+	}
+	if m.Err != nil {
+		log.Printf("[warning] seatbelt: candidate %s of %s failed to render: %v", m.Candidate, m.Template, m.Err)
+		return nil
+	}
+	log.Printf("[warning] seatbelt: candidate %s differs from %s at line %d:\n\t- %s\n\t+ %s", m.Candidate, m.Template, m.Line, m.Expected, m.Actual)
+	return nil
+}
+
+// firstDifference returns the number of the first line that differs between
+// the given outputs, and that line in each of them.
+func firstDifference(expected, actual string) (int, string, string, bool) {
+	if expected == actual {
+		return 0, "", "", false
+	}
+
+	el, al := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	for i := 0; ; i++ {
+		var e, a string
+		if i < len(el) {
+			e = el[i]
+		}
+		if i < len(al) {
+			a = al[i]
+		}
+		if e != a || i >= len(el) || i >= len(al) {
+			return i + 1, e, a, true
+		}
+	}
+}
+
+// teeResponseWriter copies the body written to the response into a buffer.
+type teeResponseWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+func (t *teeResponseWriter) WriteHeader(code int) {
+	if t.status == 0 {
+		t.status = code
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *teeResponseWriter) Write(b []byte) (int, error) {
+	t.buf.Write(b)
+	return t.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying response writer does.
+func (t *teeResponseWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,65 @@
+package seatbelt
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var csrfMetaToken = regexp.MustCompile(`<meta name="csrf-token" content="([^"]*)">`)
+
+func TestCompareRender(t *testing.T) {
+	for _, tt := range []struct {
+		candidate string
+		mismatch  bool
+		err       bool
+	}{
+		{"compare/page_same", false, false},
+		{"compare/page_v2", true, false},
+		{"compare/page_broken", true, true},
+	} {
+		t.Run(tt.candidate, func(t *testing.T) {
+			app := New(Option{
+				TemplateDir:    filepath.Join("testdata", "templates"),
+				SkipServeFiles: true,
+			})
+
+			var mismatches []RenderMismatch
+			app.CompareRender("compare/page", tt.candidate, CompareOptions{
+				OnMismatch: func(m RenderMismatch) {
+					mismatches = append(mismatches, m)
+				},
+			})
+			app.Get("/", func(c *Context) error {
+				return c.Render("compare/page", map[string]interface{}{"Title": "Order", "Total": 42})
+			})
+
+			resp := app.Invoke(http.MethodGet, "/", nil)
+			if body := resp.String(); !strings.Contains(body, "<p>Total: 42</p>") || strings.Contains(body, "Sum") {
+				t.Fatalf("expected only the current template to be sent but got %s", body)
+			}
+			if token := csrfMetaToken.FindStringSubmatch(resp.String()); token == nil || token[1] == "" {
+				t.Fatalf("expected the CSRF token to be rendered but got %s", resp.String())
+			}
+
+			if !tt.mismatch {
+				if len(mismatches) != 0 {
+					t.Fatalf("expected no mismatch but got %+v", mismatches)
+				}
+				return
+			}
+			if len(mismatches) != 1 {
+				t.Fatalf("expected a mismatch")
+			}
+			m := mismatches[0]
+			if (m.Err != nil) != tt.err {
+				t.Fatalf("expected the candidate's error to be reported but got %+v", m)
+			}
+			if !tt.err && (m.Line == 0 || m.Expected != "<p>Total: 42</p>" || m.Actual != "<p>Sum: 42</p>") {
+				t.Fatalf("expected the differing lines but got %+v", m)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package seatbelt
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	page := strings.Repeat("<p>Hello, world</p>", 100)
+
+	app := New(Option{SkipServeFiles: true, Compress: true})
+	app.Get("/page", func(c *Context) error {
+		return c.String(http.StatusOK, page)
+	})
+	app.Get("/image", func(c *Context) error {
+		c.Response().Header().Set("Content-Type", "image/png")
+		_, err := c.Response().Write([]byte(page))
+		return err
+	})
+	app.Get("/small", func(c *Context) error {
+		c.Response().Header().Set("Content-Length", "5")
+		return c.String(http.StatusOK, "Hello")
+	})
+
+	gzipped := InvokeOptions{Headers: map[string]string{"Accept-Encoding": "br, gzip;q=0.8"}}
+
+	t.Run("compress text", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/page", nil, gzipped)
+		if resp.Header.Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected the response to be compressed")
+		}
+		if resp.Header.Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("expected Vary: Accept-Encoding but got %s", resp.Header.Get("Vary"))
+		}
+
+		gz, err := gzip.NewReader(strings.NewReader(resp.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != page {
+			t.Fatalf("expected the page but got %s", body)
+		}
+	})
+
+	t.Run("skip clients that don't accept gzip", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/page", nil, InvokeOptions{Headers: map[string]string{"Accept-Encoding": "gzip;q=0, deflate"}})
+		if resp.Header.Get("Content-Encoding") != "" || resp.String() != page {
+			t.Fatalf("expected the response not to be compressed")
+		}
+	})
+
+	t.Run("skip compressed types", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/image", nil, gzipped)
+		if resp.Header.Get("Content-Encoding") != "" || resp.String() != page {
+			t.Fatalf("expected the image not to be compressed")
+		}
+	})
+
+	t.Run("skip small responses", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/small", nil, gzipped)
+		if resp.Header.Get("Content-Encoding") != "" || resp.String() != "Hello" {
+			t.Fatalf("expected the small response not to be compressed")
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package seatbelt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FreshWhen sets the ETag and Last-Modified headers of the response, and
+// returns true if the client's cached copy of the page is still fresh, in
+// which case 304 Not Modified has been written and the handler should return
+// without rendering, i.e.,
+//
+//	func ShowPost(c *seatbelt.Context) error {
+//		post := findPost(c.PathParam("id"))
+//		if c.FreshWhen(post.Version(), post.UpdatedAt) {
+//			return nil
+//		}
+//		return c.Render("posts/show", map[string]interface{}{"Post": post})
+//	}
+//
+// The ETag is quoted if it isn't already, and either value can be empty.
+// If-None-Match takes precedence over If-Modified-Since. Only GET and HEAD
+// requests can be fresh.
+func (c *context) FreshWhen(etag string, lastModified time.Time) bool {
+	h := c.w.Header()
+	if etag = quoteETag(etag); etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if c.r.Method != http.MethodGet && c.r.Method != http.MethodHead {
+		return false
+	}
+
+	fresh := false
+	if match := c.r.Header.Get("If-None-Match"); match != "" {
+		fresh = etag != "" && etagMatches(match, etag)
+	} else if since := c.r.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		// HTTP dates have a resolution of one second.
+		fresh = err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	if fresh {
+		c.w.WriteHeader(http.StatusNotModified)
+	}
+	return fresh
+}
+
+// A Versioned value has a version that changes whenever it's updated, which
+// is used as its ETag in place of its LockVersion and UpdatedAt fields.
+type Versioned interface {
+	Version() string
+}
+
+// VersionETag returns a strong ETag derived from the version of the given
+// model, or an empty string if it has no version. The version is the result
+// of its Version method if it implements Versioned, or else its ID,
+// LockVersion, and UpdatedAt fields, i.e.,
+//
+//	type Post struct {
+//		ID          int64
+//		LockVersion int
+//		UpdatedAt   time.Time
+//	}
+//
+// Models with neither a LockVersion nor an UpdatedAt field have no version.
+func VersionETag(v interface{}) string {
+	if versioned, ok := v.(Versioned); ok {
+		return quoteETag(versioned.Version())
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return ""
+	}
+
+	versioned := false
+	parts := []string{rv.Type().String()}
+	for _, name := range []string{"ID", "LockVersion", "UpdatedAt"} {
+		field := rv.FieldByName(name)
+		if !field.IsValid() || !field.CanInterface() {
+			continue
+		}
+		if name != "ID" {
+			versioned = true
+		}
+		if t, ok := field.Interface().(time.Time); ok {
+			// Times are hashed as integers, as their string includes
+			// the monotonic clock reading.
+			parts = append(parts, fmt.Sprint(t.UnixNano()))
+			continue
+		}
+		parts = append(parts, fmt.Sprint(field.Interface()))
+	}
+	if !versioned {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// VersionedJSON sends the given model as JSON with an ETag derived from its
+// version, responding with 304 Not Modified instead if the client's copy is
+// still fresh. See VersionETag.
+func (c *context) VersionedJSON(code int, v interface{}) error {
+	if etag := VersionETag(v); etag != "" && c.FreshWhen(etag, time.Time{}) {
+		return nil
+	}
+	return c.JSON(code, v)
+}
+
+// IfMatch returns true if the write to the given model may proceed, i.e., if
+// the request has no If-Match header, or if it matches the ETag of the
+// model's current version. Otherwise, the model was changed since the client
+// read it, and 412 Precondition Failed has been written, i.e.,
+//
+//	func UpdatePost(c *seatbelt.Context) error {
+//		post := findPost(c.PathParam("id"))
+//		if !c.IfMatch(post) {
+//			return nil
+//		}
+//		...
+//	}
+//
+// The model can also be given as its ETag string.
+func (c *context) IfMatch(v interface{}) bool {
+	etag, ok := v.(string)
+	if ok {
+		etag = quoteETag(etag)
+	} else {
+		etag = VersionETag(v)
+	}
+
+	header := c.r.Header.Get("If-Match")
+	if header == "" || etagMatchesStrong(header, etag) {
+		return true
+	}
+
+	c.Problem(http.StatusPreconditionFailed, "", "The resource was changed since it was read.")
+	return false
+}
+
+// quoteETag quotes the given ETag if it isn't already.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// etagMatchesStrong returns true if the given If-Match header matches the
+// given ETag, using the strong comparison. Weak ETags never match.
+func etagMatchesStrong(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && etag != "" {
+			return true
+		}
+		if etag != "" && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatches returns true if the given If-None-Match header matches the
+// given ETag, using the weak comparison.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package seatbelt
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFreshWhen(t *testing.T) {
+	updated := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	app := New(Option{SkipServeFiles: true})
+	var rendered bool
+	app.Get("/posts/1", func(c *Context) error {
+		rendered = false
+		if c.FreshWhen("post-1-v3", updated) {
+			return nil
+		}
+		rendered = true
+		return c.String(http.StatusOK, "post")
+	})
+
+	for _, tt := range []struct {
+		name    string
+		headers map[string]string
+		fresh   bool
+	}{
+		{"no conditional headers", nil, false},
+		{"matching ETag", map[string]string{"If-None-Match": `"other", W/"post-1-v3"`}, true},
+		{"stale ETag", map[string]string{"If-None-Match": `"post-1-v2"`, "If-Modified-Since": updated.Format(http.TimeFormat)}, false},
+		{"not modified since", map[string]string{"If-Modified-Since": updated.Add(time.Hour).Format(http.TimeFormat)}, true},
+		{"modified since", map[string]string{"If-Modified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := app.Invoke(http.MethodGet, "/posts/1", nil, InvokeOptions{Headers: tt.headers})
+			if tt.fresh && (resp.StatusCode != http.StatusNotModified || rendered) {
+				t.Fatalf("expected 304 without rendering but got %d", resp.StatusCode)
+			}
+			if !tt.fresh && (resp.StatusCode != http.StatusOK || !rendered) {
+				t.Fatalf("expected the post to be rendered but got %d", resp.StatusCode)
+			}
+			if etag := resp.Header.Get("ETag"); etag != `"post-1-v3"` {
+				t.Fatalf("expected the ETag to be set but got %s", etag)
+			}
+		})
+	}
+}
+
+func TestRenderETag(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+		RenderETag:     true,
+	})
+	app.Get("/", func(c *Context) error {
+		return c.Render("index", nil)
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected the page with an ETag but got %d %s", resp.StatusCode, etag)
+	}
+
+	resp = app.Invoke(http.MethodGet, "/", nil, InvokeOptions{Headers: map[string]string{"If-None-Match": etag}})
+	if resp.StatusCode != http.StatusNotModified || len(resp.Body) != 0 {
+		t.Fatalf("expected 304 without a body but got %d %s", resp.StatusCode, resp.String())
+	}
+}
+
+func TestVersionedJSON(t *testing.T) {
+	type post struct {
+		ID          int64
+		Title       string
+		LockVersion int
+	}
+
+	current := post{ID: 1, Title: "Hello", LockVersion: 3}
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/api/posts/1", func(c *Context) error {
+		return c.VersionedJSON(http.StatusOK, current)
+	})
+	app.Put("/api/posts/1", func(c *Context) error {
+		if !c.IfMatch(current) {
+			return nil
+		}
+		current.LockVersion++
+		return c.VersionedJSON(http.StatusOK, current)
+	})
+
+	resp := app.Invoke(http.MethodGet, "/api/posts/1", nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag != VersionETag(current) {
+		t.Fatalf("expected the post with its ETag but got %d %s", resp.StatusCode, etag)
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/api/posts/1", nil, InvokeOptions{Headers: map[string]string{"If-None-Match": etag}})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected %d but got %d", http.StatusNotModified, resp.StatusCode)
+		}
+	})
+
+	t.Run("write with the current version", func(t *testing.T) {
+		resp := app.Invoke(http.MethodPut, "/api/posts/1", nil, InvokeOptions{
+			Headers:  map[string]string{"If-Match": etag},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode != http.StatusOK || current.LockVersion != 4 {
+			t.Fatalf("expected the write to succeed but got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("ETag") == etag {
+			t.Fatalf("expected the ETag to change with the version")
+		}
+	})
+
+	t.Run("write with a stale version", func(t *testing.T) {
+		resp := app.Invoke(http.MethodPut, "/api/posts/1", nil, InvokeOptions{
+			Headers:  map[string]string{"If-Match": etag},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode != http.StatusPreconditionFailed || current.LockVersion != 4 {
+			t.Fatalf("expected %d but got %d", http.StatusPreconditionFailed, resp.StatusCode)
+		}
+	})
+}
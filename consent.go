@@ -0,0 +1,224 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConsentOptions configure cookie consent. Consent is enabled when at least
+// one category is configured.
+type ConsentOptions struct {
+	// Categories are the kinds of non-essential cookies a user can consent
+	// to, i.e., "analytics" and "experiments".
+	Categories []string
+
+	// Cookies maps the names of non-essential cookies to their category,
+	// i.e., "_ga" to "analytics". These cookies are removed from responses
+	// until the user has consented to their category.
+	Cookies map[string]string
+
+	// CookieName is the name of the essential cookie the user's choices are
+	// stored in. Default is "_consent".
+	CookieName string
+
+	// Path is the path of the endpoint that records the user's choices from
+	// a form POST with a "consent" value for each granted category, or
+	// "all". Default is "/consent".
+	Path string
+
+	// MaxAge is how long the user's choices are remembered. Default is one
+	// year.
+	MaxAge time.Duration
+}
+
+// consentNone is the cookie value for a user that declined every category.
+const consentNone = "-"
+
+// consentConfig is the consent configuration of an application.
+type consentConfig struct {
+	categories map[string]bool
+	cookies    map[string]string
+	cookieName string
+	maxAge     time.Duration
+}
+
+func newConsentConfig(o ConsentOptions) *consentConfig {
+	if len(o.Categories) == 0 {
+		return nil
+	}
+
+	cc := &consentConfig{
+		categories: make(map[string]bool),
+		cookies:    o.Cookies,
+		cookieName: o.CookieName,
+		maxAge:     o.MaxAge,
+	}
+	for _, category := range o.Categories {
+		cc.categories[category] = true
+	}
+	if cc.cookieName == "" {
+		cc.cookieName = "_consent"
+	}
+	if cc.maxAge == 0 {
+		cc.maxAge = 365 * 24 * time.Hour
+	}
+	return cc
+}
+
+// A Consent holds the cookie consent choices of the user making a request.
+type Consent struct {
+	config  *consentConfig
+	w       http.ResponseWriter
+	r       *http.Request
+	decided bool
+	granted map[string]bool
+}
+
+type consentCtxKeyType struct{}
+
+var consentCtxKey = consentCtxKeyType{}
+
+// withConsent reads the consent choices from the request's cookie, and
+// returns the request with them on its context.
+func (cc *consentConfig) withConsent(w http.ResponseWriter, r *http.Request) *http.Request {
+	consent := &Consent{config: cc, w: w, r: r, granted: make(map[string]bool)}
+	if cookie, err := r.Cookie(cc.cookieName); err == nil && cookie.Value != "" {
+		consent.decided = true
+		for _, category := range strings.Split(cookie.Value, ".") {
+			if cc.categories[category] {
+				consent.granted[category] = true
+			}
+		}
+	}
+	return r.WithContext(stdcontext.WithValue(r.Context(), consentCtxKey, consent))
+}
+
+// consentFromRequest returns the consent choices of the given request. If
+// consent is not configured, every category is granted.
+func consentFromRequest(r *http.Request) *Consent {
+	if consent, ok := r.Context().Value(consentCtxKey).(*Consent); ok {
+		return consent
+	}
+	return &Consent{}
+}
+
+// Consent returns the cookie consent choices of the user, i.e.,
+//
+//	if c.Consent().Granted("analytics") {
+//		http.SetCookie(c.Response(), analyticsCookie)
+//	}
+//
+// The "consent" template func returns the same, i.e., to show a banner:
+//
+//	{{ if not consent.Decided }}{{ partial "consent_banner" }}{{ end }}
+func (c *context) Consent() *Consent {
+	return consentFromRequest(c.r)
+}
+
+// Granted returns true if the user consented to the given category. If
+// consent is not configured, every category is granted.
+func (c *Consent) Granted(category string) bool {
+	if c.config == nil {
+		return true
+	}
+	return c.granted[category]
+}
+
+// Decided returns true if the user has made a choice, i.e., to hide the
+// consent banner. If consent is not configured, Decided always returns
+// true.
+func (c *Consent) Decided() bool {
+	return c.config == nil || c.decided
+}
+
+// Set records that the user consented to the given categories, and to no
+// others. Unknown categories are ignored. Non-essential cookies of
+// categories that are no longer granted are deleted.
+func (c *Consent) Set(categories ...string) {
+	if c.config == nil {
+		return
+	}
+
+	c.decided = true
+	c.granted = make(map[string]bool)
+	var values []string
+	for _, category := range categories {
+		if c.config.categories[category] && !c.granted[category] {
+			c.granted[category] = true
+			values = append(values, category)
+		}
+	}
+	value := strings.Join(values, ".")
+	if value == "" {
+		value = consentNone
+	}
+
+	http.SetCookie(c.w, &http.Cookie{
+		Name:     c.config.cookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(c.config.maxAge / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	for name, category := range c.config.cookies {
+		if _, err := c.r.Cookie(name); err == nil && !c.granted[category] {
+			http.SetCookie(c.w, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+		}
+	}
+}
+
+// filter removes the Set-Cookie headers of non-essential cookies whose
+// category the user hasn't consented to.
+func (c *Consent) filter(header http.Header) {
+	if c.config == nil || len(c.config.cookies) == 0 {
+		return
+	}
+
+	cookies := header.Values("Set-Cookie")
+	kept := cookies[:0]
+	for _, cookie := range cookies {
+		name, rest, _ := strings.Cut(cookie, "=")
+		category, ok := c.config.cookies[strings.TrimSpace(name)]
+		// Deleting a cookie doesn't need consent.
+		deleting := strings.Contains(rest, "Max-Age=0")
+		if ok && !c.granted[category] && !deleting {
+			continue
+		}
+		kept = append(kept, cookie)
+	}
+	header.Del("Set-Cookie")
+	for _, cookie := range kept {
+		header.Add("Set-Cookie", cookie)
+	}
+}
+
+// recordConsent records the user's choices submitted to the consent
+// endpoint, and redirects back to the page they were submitted from.
+func recordConsent(c *Context) error {
+	if err := c.r.ParseForm(); err != nil {
+		return err
+	}
+
+	consent := c.Consent()
+	categories := c.r.PostForm["consent"]
+	for _, category := range categories {
+		if category == "all" {
+			categories = categories[:0]
+			for category := range consent.config.categories {
+				categories = append(categories, category)
+			}
+			break
+		}
+	}
+	consent.Set(categories...)
+
+	from := c.r.Referer()
+	if from == "" {
+		from = "/"
+	}
+	return c.Redirect(from)
+}
@@ -0,0 +1,113 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestConsent(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles: true,
+		Consent: ConsentOptions{
+			Categories: []string{"analytics", "experiments"},
+			Cookies:    map[string]string{"_ga": "analytics", "_ab": "experiments"},
+		},
+	})
+
+	app.Get("/", func(c *Context) error {
+		http.SetCookie(c.Response(), &http.Cookie{Name: "_ga", Value: "1"})
+		http.SetCookie(c.Response(), &http.Cookie{Name: "_ab", Value: "b"})
+		http.SetCookie(c.Response(), &http.Cookie{Name: "theme", Value: "dark"})
+
+		consent := c.Consent()
+		return c.String(http.StatusOK, strings.Join([]string{
+			boolString(consent.Decided()),
+			boolString(consent.Granted("analytics")),
+			boolString(consent.Granted("experiments")),
+		}, " "))
+	})
+
+	cookieNames := func(resp *Response) string {
+		var names []string
+		for _, cookie := range resp.Cookies {
+			if cookie.MaxAge >= 0 && cookie.Name != "_gorilla_csrf" {
+				names = append(names, cookie.Name)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+
+	t.Run("non-essential cookies are suppressed until consent", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/", nil)
+		if body := resp.String(); body != "false false false" {
+			t.Fatalf("expected no consent but got %s", body)
+		}
+		if names := cookieNames(resp); names != "theme" {
+			t.Fatalf("expected only the essential cookie but got %s", names)
+		}
+	})
+
+	var choice *http.Cookie
+	t.Run("choices are recorded", func(t *testing.T) {
+		form := url.Values{"consent": {"analytics", "unknown"}}
+		resp := app.Invoke(http.MethodPost, "/consent", strings.NewReader(form.Encode()), InvokeOptions{
+			Headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded", "Referer": "/pricing"},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode != http.StatusFound && resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected a redirect but got %d", resp.StatusCode)
+		}
+		if loc := resp.Header.Get("Location"); loc != "/pricing" {
+			t.Fatalf("expected a redirect to /pricing but got %s", loc)
+		}
+		for _, cookie := range resp.Cookies {
+			if cookie.Name == "_consent" {
+				choice = cookie
+			}
+		}
+		if choice == nil || choice.Value != "analytics" {
+			t.Fatalf("expected the consent cookie to be analytics but got %v", choice)
+		}
+	})
+
+	t.Run("granted cookies are sent", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/", nil, InvokeOptions{Cookies: []*http.Cookie{choice}})
+		if body := resp.String(); body != "true true false" {
+			t.Fatalf("expected analytics consent but got %s", body)
+		}
+		if names := cookieNames(resp); names != "_ga,theme" {
+			t.Fatalf("expected the analytics and essential cookies but got %s", names)
+		}
+	})
+
+	t.Run("declining deletes existing cookies", func(t *testing.T) {
+		form := url.Values{}
+		resp := app.Invoke(http.MethodPost, "/consent", strings.NewReader(form.Encode()), InvokeOptions{
+			Headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			Cookies:  []*http.Cookie{choice, {Name: "_ga", Value: "1"}},
+			SkipCSRF: true,
+		})
+
+		var deleted bool
+		for _, cookie := range resp.Cookies {
+			if cookie.Name == "_consent" && cookie.Value != consentNone {
+				t.Fatalf("expected the consent cookie to be %s but got %s", consentNone, cookie.Value)
+			}
+			if cookie.Name == "_ga" && cookie.MaxAge < 0 {
+				deleted = true
+			}
+		}
+		if !deleted {
+			t.Fatalf("expected the analytics cookie to be deleted")
+		}
+	})
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
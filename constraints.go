@@ -0,0 +1,149 @@
+package seatbelt
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi"
+)
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// constraints holds the named path param constraints of an application.
+type constraints struct {
+	mu    sync.RWMutex
+	funcs map[string]func(value string) bool
+}
+
+// newConstraints returns the built-in path param constraints, "int" and
+// "uuid".
+func newConstraints() *constraints {
+	return &constraints{
+		funcs: map[string]func(value string) bool{
+			"int": func(value string) bool {
+				return value != "" && strings.Trim(value, "0123456789") == ""
+			},
+			"uuid": uuidRe.MatchString,
+		},
+	}
+}
+
+// paramEnd returns the index of the brace that closes the path param opened
+// at the given index, or -1 if it isn't closed. Regular expressions can
+// contain braces themselves, i.e., "{year:[0-9]{4}}".
+func paramEnd(path string, start int) int {
+	depth := 0
+	for i := start; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parse returns the given path with the named constraints of its path params
+// removed, and the constraint funcs of each param. Path params constrained
+// by a regular expression, i.e., "{id:[0-9]+}", are left to the router.
+func (cs *constraints) parse(path string) (string, map[string][]func(value string) bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	var params map[string][]func(value string) bool
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		if start == -1 {
+			b.WriteString(path)
+			break
+		}
+
+		end := paramEnd(path, start)
+		if end == -1 {
+			b.WriteString(path)
+			break
+		}
+
+		param := path[start+1 : end]
+		if i := strings.IndexByte(param, ':'); i != -1 {
+			if fn, ok := cs.funcs[param[i+1:]]; ok {
+				if params == nil {
+					params = make(map[string][]func(value string) bool)
+				}
+				params[param[:i]] = append(params[param[:i]], fn)
+				param = param[:i]
+			}
+		}
+
+		b.WriteString(path[:start] + "{" + param + "}")
+		path = path[end+1:]
+	}
+	return b.String(), params
+}
+
+// Constraint registers a named constraint on path params, which routes
+// registered afterwards can use in place of a regular expression, i.e.,
+//
+//	app.Constraint("slug", func(value string) bool {
+//		return slugRe.MatchString(value)
+//	})
+//	app.Get("/posts/{slug:slug}", showPost)
+//
+// Requests whose path params don't satisfy their constraints get a 404 Not
+// Found response, and the handler isn't called. The constraints "int" and
+// "uuid" are built in.
+func (a *App) Constraint(name string, fn func(value string) bool) {
+	a.routes.constraints.mu.Lock()
+	defer a.routes.constraints.mu.Unlock()
+
+	a.routes.constraints.funcs[name] = fn
+}
+
+// Where constrains the path param with the given name, so that requests
+// whose param doesn't satisfy the given func get a 404 Not Found response,
+// i.e.,
+//
+//	app.Get("/users/{id}", showUser).Where("id", isUUID)
+func (r *Route) Where(param string, fn func(value string) bool) *Route {
+	if r.params == nil {
+		r.params = make(map[string][]func(value string) bool)
+	}
+	r.params[param] = append(r.params[param], fn)
+	return r
+}
+
+// When constrains the route to requests for which the given func returns
+// true, so that other requests get a 404 Not Found response, i.e.,
+//
+//	app.Get("/", showAccount).When(func(r *http.Request) bool {
+//		return strings.Count(r.Host, ".") > 1
+//	})
+func (r *Route) When(fn func(r *http.Request) bool) *Route {
+	r.conditions = append(r.conditions, fn)
+	return r
+}
+
+// matches returns true if the given request satisfies all constraints of the
+// route.
+func (r *Route) matches(req *http.Request) bool {
+	for param, fns := range r.params {
+		value := chi.URLParam(req, param)
+		for _, fn := range fns {
+			if !fn(value) {
+				return false
+			}
+		}
+	}
+	for _, fn := range r.conditions {
+		if !fn(req) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package seatbelt
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouteConstraints(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Constraint("lower", func(value string) bool {
+		return strings.ToLower(value) == value
+	})
+	app.Get("/users/{id:int}", func(c *Context) error {
+		return c.String(http.StatusOK, "user "+c.PathParam("id"))
+	}).Name("user")
+	app.Get("/orders/{id:uuid}", func(c *Context) error {
+		return c.String(http.StatusOK, "order")
+	})
+	app.Get("/tags/{tag:lower}", func(c *Context) error {
+		return c.String(http.StatusOK, "tag")
+	})
+	app.Get("/posts/{id:[0-9]{2}}", func(c *Context) error {
+		return c.String(http.StatusOK, "post")
+	})
+	app.Get("/teams/{team}", func(c *Context) error {
+		return c.String(http.StatusOK, "team")
+	}).Where("team", func(value string) bool {
+		return len(value) > 2
+	}).When(func(r *http.Request) bool {
+		return strings.HasPrefix(r.Host, "app.")
+	})
+
+	for _, tt := range []struct {
+		path   string
+		host   string
+		status int
+	}{
+		{"/users/42", "", http.StatusOK},
+		{"/users/abc", "", http.StatusNotFound},
+		{"/orders/0b9c0a4e-6f8e-4d3a-9c1e-2f0b1d3c4a5b", "", http.StatusOK},
+		{"/orders/42", "", http.StatusNotFound},
+		{"/tags/go", "", http.StatusOK},
+		{"/tags/Go", "", http.StatusNotFound},
+		{"/posts/12", "", http.StatusOK},
+		{"/posts/123", "", http.StatusNotFound},
+		{"/teams/core", "app.example.com", http.StatusOK},
+		{"/teams/go", "app.example.com", http.StatusNotFound},
+		{"/teams/core", "example.com", http.StatusNotFound},
+	} {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			target := tt.path
+			if tt.host != "" {
+				target = "http://" + tt.host + tt.path
+			}
+			resp := app.Invoke(http.MethodGet, target, nil)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d but got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+
+	if url, err := app.URLFor("user", "id", 42); err != nil || url != "/users/42" {
+		t.Fatalf("expected /users/42 but got %s (%v)", url, err)
+	}
+}
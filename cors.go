@@ -0,0 +1,196 @@
+package seatbelt
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configure the cross-origin requests allowed by CORS.
+type CORSOptions struct {
+	// Origins are the origins allowed to make cross-origin requests, i.e.,
+	// "https://app.example.com". An origin can start with a wildcard
+	// subdomain, i.e., "https://*.example.com", and "*" allows any origin.
+	// Default is nil, meaning no cross-origin requests are allowed.
+	Origins []string
+
+	// Methods are the HTTP methods allowed in cross-origin requests.
+	// Default is GET, HEAD, POST, PUT, PATCH, and DELETE.
+	Methods []string
+
+	// Headers are the request headers allowed in cross-origin requests.
+	// Default is Accept, Content-Type, X-CSRF-Token, and X-Requested-With.
+	Headers []string
+
+	// Credentials allows cross-origin requests to include cookies. It can't
+	// be combined with the origin "*", as that would let any site read the
+	// responses of signed in users. Default is false.
+	Credentials bool
+
+	// MaxAge is how long browsers may cache the response to a preflight
+	// request, rounded down to whole seconds. Default is 0, meaning the
+	// browser's default is used.
+	MaxAge time.Duration
+}
+
+// cors is the CORS configuration with its defaults applied.
+type cors struct {
+	opts    CORSOptions
+	methods string
+	headers map[string]bool
+}
+
+// allowsOrigin reports whether the given origin may make cross-origin
+// requests.
+func (co *cors) allowsOrigin(origin string) bool {
+	for _, allowed := range co.opts.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if i := strings.Index(allowed, "*."); i != -1 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsMethod reports whether the given method may be used in cross-origin
+// requests.
+func (co *cors) allowsMethod(method string) bool {
+	for _, allowed := range co.opts.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether all headers in the given
+// Access-Control-Request-Headers value may be sent in cross-origin requests.
+func (co *cors) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !co.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// allowOrigin sets the headers that allow the given origin to read the
+// response.
+func (co *cors) allowOrigin(h http.Header, origin string) {
+	// Credentials are never allowed for "*", see CORS.
+	if len(co.opts.Origins) == 1 && co.opts.Origins[0] == "*" {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if co.opts.Credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// CORS returns middleware that allows cross-origin requests from the given
+// origins, i.e., for a JSON API used by a single-page application on another
+// host:
+//
+//	app.Namespace("/api", func(app *seatbelt.App) {
+//		app.UseStd(seatbelt.CORS(seatbelt.CORSOptions{
+//			Origins:     []string{"https://app.example.com"},
+//			Credentials: true,
+//		}))
+//		app.SkipCSRF()
+//		app.Post("/posts", createPost)
+//	})
+//
+// Preflight requests are answered by the middleware, and never reach the
+// application's routes. To allow cross-origin requests to every route, set
+// Option.CORS instead, which also adds the CORS headers to responses that
+// fail CSRF validation.
+//
+// CORS panics if Credentials is combined with the origin "*".
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	if opts.Credentials {
+		for _, origin := range opts.Origins {
+			if origin == "*" {
+				panic("seatbelt: CORS can't allow credentials for any origin, list the allowed origins instead of \"*\"")
+			}
+		}
+	}
+
+	co := &cors{opts: opts}
+	if len(co.opts.Methods) == 0 {
+		co.opts.Methods = []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		}
+	}
+	if len(co.opts.Headers) == 0 {
+		co.opts.Headers = []string{"Accept", "Content-Type", CSRFHeader, "X-Requested-With"}
+	}
+	co.methods = strings.Join(co.opts.Methods, ", ")
+	co.headers = make(map[string]bool, len(co.opts.Headers))
+	for _, header := range co.opts.Headers {
+		co.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			h := w.Header()
+			if preflight {
+				addVary(h, "Origin")
+				addVary(h, "Access-Control-Request-Method")
+				addVary(h, "Access-Control-Request-Headers")
+
+				// Preflight requests that aren't allowed are answered
+				// without CORS headers, which fails them in the browser.
+				if origin != "" && co.allowsOrigin(origin) &&
+					co.allowsMethod(r.Header.Get("Access-Control-Request-Method")) &&
+					co.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+					co.allowOrigin(h, origin)
+					h.Set("Access-Control-Allow-Methods", co.methods)
+					h.Set("Access-Control-Allow-Headers", strings.Join(co.opts.Headers, ", "))
+					if seconds := int(co.opts.MaxAge / time.Second); seconds > 0 {
+						h.Set("Access-Control-Max-Age", strconv.Itoa(seconds))
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			addVary(h, "Origin")
+			if origin != "" && co.allowsOrigin(origin) {
+				co.allowOrigin(h, origin)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// addVary adds the given header to the Vary header, unless it's already
+// there, i.e., because the middleware ran twice for a request that didn't
+// match a route.
+func addVary(h http.Header, header string) {
+	for _, v := range h.Values("Vary") {
+		for _, existing := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), header) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", header)
+}
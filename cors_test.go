@@ -0,0 +1,136 @@
+package seatbelt
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	preflight := func(origin, method, headers string) InvokeOptions {
+		return InvokeOptions{Headers: map[string]string{
+			"Origin":                         origin,
+			"Access-Control-Request-Method":  method,
+			"Access-Control-Request-Headers": headers,
+		}}
+	}
+
+	t.Run("global", func(t *testing.T) {
+		app := New(Option{
+			SkipServeFiles: true,
+			CORS: &CORSOptions{
+				Origins:     []string{"https://app.example.com", "https://*.example.org"},
+				Credentials: true,
+				MaxAge:      10 * time.Minute,
+			},
+		})
+		app.Post("/posts", func(c *Context) error {
+			return c.String(http.StatusCreated, "created")
+		})
+
+		resp := app.Invoke(http.MethodOptions, "/posts", nil, preflight("https://app.example.com", "POST", "content-type, x-csrf-token"))
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected %d but got %d", http.StatusNoContent, resp.StatusCode)
+		}
+		for header, expected := range map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.com",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Allow-Methods":     "GET, HEAD, POST, PUT, PATCH, DELETE",
+			"Access-Control-Allow-Headers":     "Accept, Content-Type, X-CSRF-Token, X-Requested-With",
+			"Access-Control-Max-Age":           "600",
+		} {
+			if actual := resp.Header.Get(header); actual != expected {
+				t.Fatalf("expected %s to be %s but got %s", header, expected, actual)
+			}
+		}
+
+		resp = app.Invoke(http.MethodOptions, "/posts", nil, preflight("https://api.example.org", "PUT", ""))
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "https://api.example.org" {
+			t.Fatalf("expected the wildcard subdomain to be allowed but got %s", origin)
+		}
+
+		for _, tt := range []struct {
+			name    string
+			options InvokeOptions
+		}{
+			{"origin", preflight("https://evil.example.com", "POST", "")},
+			{"method", preflight("https://app.example.com", "PROPFIND", "")},
+			{"headers", preflight("https://app.example.com", "POST", "X-Secret")},
+		} {
+			resp := app.Invoke(http.MethodOptions, "/posts", nil, tt.options)
+			if resp.StatusCode != http.StatusNoContent {
+				t.Fatalf("expected %d but got %d", http.StatusNoContent, resp.StatusCode)
+			}
+			if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "" {
+				t.Fatalf("expected the disallowed %s to fail the preflight but got %s", tt.name, origin)
+			}
+		}
+
+		// Requests that fail CSRF validation can still be read.
+		resp = app.Invoke(http.MethodPost, "/posts", nil, InvokeOptions{
+			Headers: map[string]string{"Origin": "https://app.example.com"},
+		})
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected %d but got %d", http.StatusForbidden, resp.StatusCode)
+		}
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+			t.Fatalf("expected https://app.example.com but got %s", origin)
+		}
+
+		resp = app.Invoke(http.MethodGet, "/missing", nil)
+		if vary := strings.Join(resp.Header.Values("Vary"), ", "); strings.Count(vary, "Origin") != 1 {
+			t.Fatalf("expected Vary to contain Origin once but got %s", vary)
+		}
+	})
+
+	t.Run("namespace", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+		app.Namespace("/api", func(app *App) {
+			app.UseStd(CORS(CORSOptions{Origins: []string{"*"}}))
+			app.SkipCSRF()
+			app.Post("/posts", func(c *Context) error {
+				return c.String(http.StatusCreated, "created")
+			})
+		})
+		app.Post("/posts", func(c *Context) error {
+			return c.String(http.StatusCreated, "created")
+		})
+
+		resp := app.Invoke(http.MethodOptions, "/api/posts", nil, preflight("https://app.example.com", "POST", ""))
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "*" {
+			t.Fatalf("expected * but got %s", origin)
+		}
+		if credentials := resp.Header.Get("Access-Control-Allow-Credentials"); credentials != "" {
+			t.Fatalf("expected no credentials for any origin but got %s", credentials)
+		}
+
+		resp = app.Invoke(http.MethodPost, "/api/posts", strings.NewReader(""), InvokeOptions{
+			Headers: map[string]string{"Origin": "https://app.example.com"},
+		})
+		if resp.StatusCode != http.StatusCreated || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+			t.Fatalf("expected a cross-origin response but got %d %v", resp.StatusCode, resp.Header)
+		}
+
+		resp = app.Invoke(http.MethodOptions, "/posts", nil, preflight("https://app.example.com", "POST", ""))
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Fatalf("expected routes outside the namespace to not allow cross-origin requests but got %s", origin)
+		}
+	})
+
+	t.Run("credentials for any origin should panic", func(t *testing.T) {
+		for _, opts := range []CORSOptions{
+			{Origins: []string{"*"}, Credentials: true},
+			{Origins: []string{"https://app.example.com", "*"}, Credentials: true},
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatalf("expected panic for %v", opts.Origins)
+					}
+				}()
+				New(Option{SkipServeFiles: true, CORS: &opts})
+			}()
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package seatbelt
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/csrf"
+)
+
+// CSRFHeader is the request header that the CSRF token is read from when it
+// isn't submitted as a form field, i.e., by fetch or XHR requests.
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFOptions configure the CSRF protection applied to all unsafe requests.
+type CSRFOptions struct {
+	// TrustedOrigins are the hosts, i.e., "api.example.com", that are allowed
+	// to make cross-origin requests in addition to the request's own host.
+	// Default is nil.
+	TrustedOrigins []string
+
+	// CookieName is the name of the cookie holding the CSRF token. Default
+	// is "_gorilla_csrf".
+	CookieName string
+
+	// SameSite sets the SameSite attribute of the CSRF cookie. Default is
+	// 0, meaning the attribute is not set.
+	SameSite http.SameSite
+
+	// ServeToken serves the request's CSRF token as JSON at "/csrf" when set
+	// to true, i.e., {"token": "..."}, so that JavaScript clients can fetch
+	// it and send it back in the X-CSRF-Token header. Default is false.
+	ServeToken bool
+
+	// OnFailure is called to respond to requests that fail CSRF validation,
+	// after the application's middleware has run, i.e., to render an error
+	// page. The reason for the failure is passed as err. If it doesn't
+	// write a response, a plain 403 Forbidden response is sent. Default is
+	// nil, meaning a plain 403 Forbidden response is sent.
+	OnFailure func(c *Context, err error) error
+}
+
+// csrfProtect returns the CSRF protection middleware configured with opt.
+func (a *App) csrfProtect(opt CSRFOptions) func(http.Handler) http.Handler {
+	options := []csrf.Option{csrf.Path("/"), csrf.RequestHeader(CSRFHeader)}
+
+	if opt.TrustedOrigins != nil {
+		options = append(options, csrf.TrustedOrigins(opt.TrustedOrigins))
+	}
+	if opt.CookieName != "" {
+		options = append(options, csrf.CookieName(opt.CookieName))
+	}
+	switch opt.SameSite {
+	case http.SameSiteDefaultMode:
+		options = append(options, csrf.SameSite(csrf.SameSiteDefaultMode))
+	case http.SameSiteLaxMode:
+		options = append(options, csrf.SameSite(csrf.SameSiteLaxMode))
+	case http.SameSiteStrictMode:
+		options = append(options, csrf.SameSite(csrf.SameSiteStrictMode))
+	case http.SameSiteNoneMode:
+		options = append(options, csrf.SameSite(csrf.SameSiteNoneMode))
+	}
+	if opt.OnFailure != nil {
+		options = append(options, csrf.ErrorHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a.serveContext(w, r, func(c *Context) error {
+				if err := opt.OnFailure(c, csrf.FailureReason(r)); err != nil {
+					return err
+				}
+				// The request must never look like it succeeded.
+				if c.ResponseStats().Status == 0 {
+					http.Error(c.w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				}
+				return nil
+			})
+		})))
+	}
+
+	return csrf.Protect(a.signingKey, options...)
+}
+
+// CSRFToken returns the CSRF token for the request. JavaScript clients that
+// don't submit forms can send it back in the X-CSRF-Token header.
+func (c *context) CSRFToken() string {
+	return csrf.Token(c.r)
+}
+
+// SkipCSRF exempts the route from CSRF validation, i.e., for a webhook that
+// is called by another service:
+//
+//	app.Post("/webhooks/stripe", handleStripe).SkipCSRF()
+func (r *Route) SkipCSRF() *Route {
+	r.app.routes.mu.Lock()
+	defer r.app.routes.mu.Unlock()
+
+	r.skipCSRF = true
+	return r
+}
+
+// SkipCSRF exempts all routes of the application, including those of its
+// namespaces and groups, from CSRF validation, i.e., for a JSON API:
+//
+//	app.Namespace("/api", func(app *seatbelt.App) {
+//		app.SkipCSRF()
+//		app.Post("/posts", createPost)
+//	})
+//
+// Unlike Option.SkipCSRFPaths, the exemption follows the routes if the
+// namespace's path changes.
+func (a *App) SkipCSRF() {
+	a.routes.mu.Lock()
+	defer a.routes.mu.Unlock()
+
+	a.skipCSRF = true
+}
+
+// csrfExempt reports whether the given route, or any of the applications it
+// was registered on, skips CSRF validation.
+func (a *App) csrfExempt(route *Route) bool {
+	a.routes.mu.RLock()
+	defer a.routes.mu.RUnlock()
+
+	if route.skipCSRF {
+		return true
+	}
+	for app := route.app; app != nil; app = app.parent {
+		if app.skipCSRF {
+			return true
+		}
+	}
+	return false
+}
+
+// skipCSRFRoutes is the middleware that marks requests to routes exempted
+// with SkipCSRF, so that they aren't validated by the CSRF middleware.
+func (a *App) skipCSRFRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux, host := a.mux, ""
+		for _, hr := range a.hosts {
+			if _, ok := hr.match(r.Host); ok {
+				mux, host = hr.app.mux, hr.pattern
+				break
+			}
+		}
+
+		path := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+			path = rctx.RoutePath
+		}
+
+		rctx := chi.NewRouteContext()
+		if mux.Match(rctx, r.Method, path) {
+			if route := a.routes.lookup(host, r.Method, rctx.RoutePattern()); route != nil && a.csrfExempt(route) {
+				r = csrf.UnsafeSkipCheck(r)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,163 @@
+package seatbelt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File sends the file at the given path, to be displayed inline by the
+// browser, i.e., an image or a PDF. The Content-Type is detected from the
+// file's extension or contents, and range requests and conditional requests
+// are supported. If the file doesn't exist, File returns an error with the
+// status 404 Not Found.
+func (c *context) File(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return WrapError(err, http.StatusNotFound, "")
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return WrapError(errors.New("seatbelt: "+path+" is a directory"), http.StatusNotFound, "")
+	}
+
+	c.w.Header().Set("Content-Disposition", contentDisposition("inline", filepath.Base(path)))
+	http.ServeContent(c.w, c.r, info.Name(), info.ModTime(), f)
+	return nil
+}
+
+// Attachment sends the contents of the given reader as a download with the
+// given file name, i.e., a generated CSV export.
+//
+//	func ExportUsers(c *seatbelt.Context) error {
+//		var buf bytes.Buffer
+//		writeUsersCSV(&buf)
+//		return c.Attachment(bytes.NewReader(buf.Bytes()), "users.csv")
+//	}
+//
+// The Content-Type is detected from the file name's extension, or else from
+// the contents. Range requests are supported if the reader is an
+// io.ReadSeeker. Otherwise, the contents are streamed without a
+// Content-Length.
+func (c *context) Attachment(r io.Reader, filename string) error {
+	h := c.w.Header()
+	h.Set("Content-Disposition", contentDisposition("attachment", filename))
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(c.w, c.r, filename, time.Time{}, rs)
+		return nil
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	br := bufio.NewReaderSize(r, 512)
+	if contentType == "" {
+		// The error is returned by the copy below.
+		sniffed, _ := br.Peek(512)
+		contentType = http.DetectContentType(sniffed)
+	}
+	h.Set("Content-Type", contentType)
+	c.w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(c.w, br)
+	return err
+}
+
+// contentDisposition returns a Content-Disposition header with the given
+// disposition type and file name. Non-ASCII file names are encoded as
+// specified by RFC 6266.
+func contentDisposition(disposition, filename string) string {
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); header != "" {
+		return header
+	}
+	return disposition
+}
+
+// Stream sends the contents of the given reader with the given status code
+// and content type, flushing each chunk to the client as soon as it's read,
+// i.e., for a large export or a proxied response.
+//
+//	func Logs(c *seatbelt.Context) error {
+//		resp, err := http.Get(logsURL)
+//		if err != nil {
+//			return err
+//		}
+//		defer resp.Body.Close()
+//		return c.Stream(http.StatusOK, "text/plain", resp.Body)
+//	}
+//
+// With Option.RequestTimeout, exempt the route with Route.NoTimeout, as the
+// stream is otherwise cut off at the deadline.
+//
+// The response has no Content-Length. If the client disconnects, Stream
+// stops and returns nil, as there's no one left to respond to. It also
+// closes the reader if it's an io.Closer, so that a blocked read returns.
+// As the header has already been written, errors reading from the reader or
+// writing to the client can't be sent as an error page, so they're logged,
+// and Stream returns nil.
+func (c *context) Stream(code int, contentType string, r io.Reader) error {
+	ctx := c.r.Context()
+	var closeOnce sync.Once
+	closeReader := func() {
+		if closer, ok := r.(io.Closer); ok {
+			closeOnce.Do(func() { closer.Close() })
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeReader()
+		case <-done:
+		}
+	}()
+
+	c.w.Header().Set("Content-Type", contentType)
+	c.w.Header().Set("X-Content-Type-Options", "nosniff")
+	c.w.WriteHeader(code)
+
+	flusher, _ := c.w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := c.w.Write(buf[:n]); werr != nil {
+				closeReader()
+				log.Printf("[warning] seatbelt: failed to write stream: %v", werr)
+				return nil
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if ctx.Err() != nil {
+			closeReader()
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			closeReader()
+			log.Printf("[warning] seatbelt: failed to read stream: %v", err)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package seatbelt
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFile(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/files/{name}", func(c *Context) error {
+		return c.File(filepath.Join("testdata", "files", c.PathParam("name")))
+	})
+
+	t.Run("send the file inline", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/files/hello.txt", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected %d but got %d", http.StatusOK, resp.StatusCode)
+		}
+		if disposition := resp.Header.Get("Content-Disposition"); disposition != `inline; filename=hello.txt` {
+			t.Fatalf("expected an inline disposition but got %s", disposition)
+		}
+		if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+			t.Fatalf("expected text/plain but got %s", contentType)
+		}
+	})
+
+	t.Run("send a range", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/files/hello.txt", nil, InvokeOptions{Headers: map[string]string{"Range": "bytes=0-4"}})
+		if resp.StatusCode != http.StatusPartialContent || resp.String() != "Hello" {
+			t.Fatalf("expected the first 5 bytes but got %d %s", resp.StatusCode, resp.String())
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/files/missing.txt", nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected %d but got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+}
+
+func TestAttachment(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/export", func(c *Context) error {
+		return c.Attachment(strings.NewReader("id,name\n1,Ada\n"), "Übersicht.csv")
+	})
+	app.Get("/stream", func(c *Context) error {
+		r, w := io.Pipe()
+		go func() {
+			w.Write([]byte("%PDF-1.7\n"))
+			w.Close()
+		}()
+		return c.Attachment(r, "report")
+	})
+
+	resp := app.Invoke(http.MethodGet, "/export", nil)
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != `attachment; filename*=utf-8''%C3%9Cbersicht.csv` {
+		t.Fatalf("expected an encoded file name but got %s", disposition)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Fatalf("expected text/csv but got %s", contentType)
+	}
+	if resp.String() != "id,name\n1,Ada\n" {
+		t.Fatalf("expected the CSV but got %s", resp.String())
+	}
+
+	resp = app.Invoke(http.MethodGet, "/stream", nil)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/pdf" {
+		t.Fatalf("expected a sniffed content type but got %s", contentType)
+	}
+	if resp.String() != "%PDF-1.7\n" {
+		t.Fatalf("expected the PDF but got %s", resp.String())
+	}
+}
+
+func TestStream(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/export", func(c *Context) error {
+		return c.Stream(http.StatusOK, "text/csv", strings.NewReader("id,name\n1,Ada\n"))
+	})
+
+	resp := app.Invoke(http.MethodGet, "/export", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected a CSV but got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp.String() != "id,name\n1,Ada\n" {
+		t.Fatalf("expected the CSV but got %s", resp.String())
+	}
+
+	t.Run("stop when the client disconnects", func(t *testing.T) {
+		r, w := io.Pipe()
+		ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+		done := make(chan error)
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			c := &Context{context: context{r: req, w: httptest.NewRecorder()}}
+			done <- c.Stream(http.StatusOK, "text/plain", r)
+		}()
+
+		w.Write([]byte("first chunk"))
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("expected the stream to stop without an error but got %v", err)
+		}
+		if _, err := w.Write([]byte("second chunk")); err == nil {
+			t.Fatalf("expected the reader to be closed")
+		}
+	})
+
+	t.Run("log errors once the header is written", func(t *testing.T) {
+		var logs bytes.Buffer
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(&logs)
+
+		app.Get("/broken", func(c *Context) error {
+			r := io.MultiReader(strings.NewReader("id,name\n"), iotest.ErrReader(errors.New("connection reset")))
+			return c.Stream(http.StatusOK, "text/csv", r)
+		})
+
+		resp := app.Invoke(http.MethodGet, "/broken", nil)
+		if resp.StatusCode != http.StatusOK || resp.String() != "id,name\n" {
+			t.Fatalf("expected the partial stream but got %d %s", resp.StatusCode, resp.String())
+		}
+		if !strings.Contains(logs.String(), "failed to read stream: connection reset") {
+			t.Fatalf("expected the error to be logged but got %q", logs.String())
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// drainPollInterval is how often Drain checks whether all in-flight
+// requests have finished. It matches the interval used by
+// http.Server.Shutdown.
+const drainPollInterval = 500 * time.Millisecond
+
+// DrainOptions configure how an application drains with Drain.
+type DrainOptions struct {
+	// RetryAfter rejects requests that arrive while draining with 503
+	// Service Unavailable and a Retry-After header of the given duration,
+	// rounded up to whole seconds. Default is 0, meaning new requests are
+	// still served.
+	RetryAfter time.Duration
+}
+
+// drainer tracks the in-flight requests of an application, and whether it
+// is draining.
+type drainer struct {
+	inFlight int64
+	draining int32
+
+	mu         sync.Mutex
+	retryAfter time.Duration
+	hooks      []func(ctx stdcontext.Context) error
+}
+
+// serve serves the given request with next, keeping track of in-flight
+// requests, and refusing keep-alive reuse while draining.
+func (d *drainer) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&d.draining) == 1 {
+		// Close the connection after the response, so that clients
+		// reconnect to an instance that isn't shutting down.
+		w.Header().Set("Connection", "close")
+
+		d.mu.Lock()
+		retryAfter := d.retryAfter
+		d.mu.Unlock()
+
+		if retryAfter > 0 {
+			seconds := int((retryAfter + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	atomic.AddInt64(&d.inFlight, 1)
+	defer atomic.AddInt64(&d.inFlight, -1)
+
+	next.ServeHTTP(w, r)
+}
+
+// InFlight returns the number of requests the application is currently
+// serving.
+func (a *App) InFlight() int {
+	return int(atomic.LoadInt64(&a.drainer.inFlight))
+}
+
+// Draining returns true once Drain has been called, i.e., so that a health
+// check can tell the load balancer to stop sending requests.
+func (a *App) Draining() bool {
+	return atomic.LoadInt32(&a.drainer.draining) == 1
+}
+
+// OnDrain registers a hook that is called when the application starts
+// draining, i.e., to stop a job runner before the in-flight requests that
+// enqueue jobs have finished. Hooks are called in the order in which they
+// were registered.
+func (a *App) OnDrain(fn func(ctx stdcontext.Context) error) {
+	a.drainer.mu.Lock()
+	defer a.drainer.mu.Unlock()
+
+	a.drainer.hooks = append(a.drainer.hooks, fn)
+}
+
+// Drain prepares the application for shutdown. It marks the application as
+// draining, so that responses close their connection instead of keeping it
+// alive, calls the hooks registered with OnDrain, and then waits until all
+// in-flight requests have finished or the context is done, i.e.,
+//
+//	app.Drain(ctx, seatbelt.DrainOptions{RetryAfter: 5 * time.Second})
+//	srv.Shutdown(ctx)
+//
+// Drain returns the first error returned by a hook, or the context's error
+// if requests are still in flight when it is done.
+func (a *App) Drain(ctx stdcontext.Context, opts ...DrainOptions) error {
+	var opt DrainOptions
+	for _, o := range opts {
+		opt = o
+	}
+
+	a.drainer.mu.Lock()
+	a.drainer.retryAfter = opt.RetryAfter
+	hooks := a.drainer.hooks
+	a.drainer.mu.Unlock()
+	atomic.StoreInt32(&a.drainer.draining, 1)
+
+	var first error
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for a.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return first
+}
+
+// every calls fn at the given interval in the background until the
+// application drains.
+func (a *App) every(interval time.Duration, fn func()) {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	a.OnDrain(func(ctx stdcontext.Context) error {
+		once.Do(func() { close(stop) })
+		return nil
+	})
+}
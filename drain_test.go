@@ -0,0 +1,96 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	app.Get("/slow", func(c *Context) error {
+		close(started)
+		<-release
+		return c.NoContent()
+	})
+	app.Get("/", func(c *Context) error {
+		return c.NoContent()
+	})
+
+	var hooked bool
+	app.OnDrain(func(ctx stdcontext.Context) error {
+		hooked = true
+		return nil
+	})
+
+	go app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	if n := app.InFlight(); n != 1 {
+		t.Fatalf("expected 1 request in flight but got %d", n)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- app.Drain(stdcontext.Background(), DrainOptions{RetryAfter: 1500 * time.Millisecond})
+	}()
+
+	// Wait for Drain to mark the application as draining.
+	for !app.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Run("new requests are rejected while draining", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected %d but got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		if v := w.Header().Get("Retry-After"); v != "2" {
+			t.Fatalf("expected Retry-After 2 but got %s", v)
+		}
+		if v := w.Header().Get("Connection"); v != "close" {
+			t.Fatalf("expected Connection close but got %s", v)
+		}
+	})
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !hooked {
+		t.Fatalf("expected the drain hook to be called")
+	}
+	if n := app.InFlight(); n != 0 {
+		t.Fatalf("expected no requests in flight but got %d", n)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	app.Get("/slow", func(c *Context) error {
+		close(started)
+		<-release
+		return c.NoContent()
+	})
+
+	go app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := app.Drain(ctx); err != stdcontext.DeadlineExceeded {
+		t.Fatalf("expected %v but got %v", stdcontext.DeadlineExceeded, err)
+	}
+}
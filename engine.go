@@ -0,0 +1,66 @@
+package seatbelt
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// An Engine is a self-contained, reusable feature, i.e., a blog or an admin
+// panel, that bundles its own routes, templates, locales, and assets, and
+// can be mounted on any application with MountEngine.
+type Engine struct {
+	// Name is the directory prefix that the engine's templates are named
+	// under, i.e., a template "posts/index.html" of an engine named "blog"
+	// is rendered with c.Render("blog/posts/index", data). Required.
+	Name string
+
+	// Routes registers the engine's routes on the namespace the engine is
+	// mounted at. Default is nil.
+	Routes func(app *App)
+
+	// Templates holds the engine's HTML templates. An application can
+	// override a template by adding one with the same name to its own
+	// template directory, i.e., "templates/blog/posts/index.html". Default
+	// is nil.
+	Templates fs.FS
+
+	// Locales holds the engine's translation files. The application's own
+	// translations take precedence. Default is nil.
+	Locales fs.FS
+
+	// Assets holds static files, which are served at "/assets" under the
+	// path the engine is mounted at. Default is nil.
+	Assets fs.FS
+
+	// AssetOptions configure the caching headers sent for the engine's
+	// assets.
+	AssetOptions FileServerOptions
+}
+
+// MountEngine mounts the given engine at the given path, i.e.,
+//
+//	app.MountEngine("/blog", blogengine.New())
+//
+// The engine's routes are registered in a namespace at the path, which is
+// returned, and inherit the application's middleware.
+func (a *App) MountEngine(path string, e *Engine) *App {
+	if e == nil || e.Name == "" {
+		panic(fmt.Sprintf("seatbelt: attempting to MountEngine() an engine without a name on '%s'", path))
+	}
+
+	if e.Templates != nil {
+		a.renderer.Mount(e.Name, e.Templates)
+	}
+	if e.Locales != nil {
+		a.i18n.AddFS(e.Locales)
+	}
+
+	return a.Namespace(path, func(app *App) {
+		if e.Assets != nil {
+			app.FileServerFS("/assets", e.Assets, e.AssetOptions)
+		}
+		if e.Routes != nil {
+			e.Routes(app)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package seatbelt
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountEngine(t *testing.T) {
+	blog := &Engine{
+		Name: "blog",
+		Routes: func(app *App) {
+			app.Get("/posts", func(c *Context) error {
+				return c.Render("blog/posts/index", nil)
+			})
+			app.Get("/posts/{id}", func(c *Context) error {
+				return c.Render("blog/posts/show", nil)
+			})
+		},
+		Templates: fstest.MapFS{
+			"posts/index.html": {Data: []byte(`<h1>{{ t "BlogTitle" nil }}</h1>`)},
+			"posts/show.html":  {Data: []byte(`<p>engine</p>`)},
+		},
+		Locales: fstest.MapFS{
+			"active.en.json": {Data: []byte(`{"BlogTitle": "Blog"}`)},
+		},
+		Assets: fstest.MapFS{
+			"app.css": {Data: []byte(`body {}`)},
+		},
+	}
+
+	app := New(Option{
+		SkipServeFiles: true,
+		TemplateDir:    filepath.Join("testdata", "templates"),
+	})
+	app.MountEngine("/blog", blog)
+
+	cases := []struct {
+		name     string
+		path     string
+		contains string
+	}{
+		{name: "engine templates and locales are used", path: "/blog/posts", contains: "<h1>Blog</h1>"},
+		{name: "application templates override engine templates", path: "/blog/posts/1", contains: "<p>overridden</p>"},
+		{name: "engine assets are served", path: "/blog/assets/app.css", contains: "body {}"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := app.Invoke(http.MethodGet, c.path, nil)
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected %d but got %d", http.StatusOK, resp.StatusCode)
+			}
+			if !strings.Contains(resp.String(), c.contains) {
+				t.Fatalf("expected %s but got %s", c.contains, resp.String())
+			}
+		})
+	}
+}
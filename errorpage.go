@@ -0,0 +1,230 @@
+package seatbelt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-seatbelt/seatbelt/render"
+
+	"github.com/go-chi/chi"
+)
+
+// An ErrorPage is passed to error templates as "Error". Its Causes, Stack,
+// Route, Params, and Logs are only set in development.
+type ErrorPage struct {
+	// The HTTP status code of the response, and its text.
+	Status     int
+	StatusText string
+
+	// The message to show the user.
+	Message string
+
+	// Whether the application is running in development.
+	Development bool
+
+	// The chain of wrapped errors, starting with the returned error, with
+	// sensitive values redacted.
+	Causes []ErrorCause
+
+	// The stack trace of the goroutine, if the handler panicked.
+	Stack string
+
+	// The request, the pattern of the route that handled it, and its
+	// params, with sensitive values redacted.
+	Method string
+	Path   string
+	Route  string
+	Params map[string][]string
+
+	// The most recently logged lines.
+	Logs []string
+}
+
+// An ErrorCause is an error in the chain of wrapped errors.
+type ErrorCause struct {
+	Type    string
+	Message string
+}
+
+// errorCauses returns the chain of wrapped errors, starting with err.
+func errorCauses(err error) []ErrorCause {
+	var causes []ErrorCause
+	for ; err != nil; err = errors.Unwrap(err) {
+		causes = append(causes, ErrorCause{Type: fmt.Sprintf("%T", err), Message: err.Error()})
+	}
+	return causes
+}
+
+// requestParams returns the form and path params of the request.
+func requestParams(r *http.Request) map[string][]string {
+	// The error page is only rendered for requests without a body, so this
+	// only parses the query.
+	r.ParseForm()
+
+	params := make(map[string][]string)
+	for k, v := range r.Form {
+		params[k] = v
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		for i, k := range rctx.URLParams.Keys {
+			params[k] = []string{rctx.URLParams.Values[i]}
+		}
+	}
+	return params
+}
+
+// errorTemplate returns the name of the first of the templates
+// "errors/<status>" and "errors/error" that exists, or "" if neither does.
+func (a *App) errorTemplate(status int) string {
+	for _, name := range []string{"errors/" + strconv.Itoa(status), "errors/error"} {
+		if a.renderer.Exists(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// statusHandler returns a handler that renders the error page for the given
+// status, i.e., for requests that don't match a route. If the application
+// has no error template for the status, the given fallback handler is used.
+func (a *App) statusHandler(status int, fallback http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.errorTemplate(status) == "" {
+			fallback(w, r)
+			return
+		}
+		a.serveContext(w, r, func(c *Context) error {
+			a.renderErrorPage(c, status, http.StatusText(status), nil)
+			return nil
+		})
+	}
+}
+
+// renderErrorPage responds with an error page for the given error. The first
+// of the templates "errors/<status>" and "errors/error" that exists is
+// rendered, falling back to a built-in page.
+func (a *App) renderErrorPage(c *Context, status int, message string, err error) {
+	page := ErrorPage{
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		Message:     message,
+		Development: a.development,
+	}
+	if a.development {
+		page.Causes = errorCauses(err)
+		for i := range page.Causes {
+			page.Causes[i].Message = a.Redact(page.Causes[i].Message)
+		}
+		var p *PanicError
+		if errors.As(err, &p) {
+			page.Stack = string(p.Stack)
+		}
+		page.Method = c.r.Method
+		page.Path = c.r.URL.Path
+		if rctx := chi.RouteContext(c.r.Context()); rctx != nil {
+			page.Route = rctx.RoutePattern()
+		}
+		page.Params = a.RedactParams(requestParams(c.r))
+		page.Logs = recentLogs.lines()
+		for i := range page.Logs {
+			page.Logs[i] = a.Redact(page.Logs[i])
+		}
+	}
+
+	if name := a.errorTemplate(status); name != "" {
+		err := c.Render(name, map[string]interface{}{"Error": page}, render.RenderOptions{StatusCode: status})
+		if err == nil {
+			return
+		}
+		log.Printf("seatbelt: failed to render error page: %v", err)
+	}
+
+	tpl := productionErrorTemplate
+	if a.development {
+		tpl = developmentErrorTemplate
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, page); err != nil {
+		log.Printf("seatbelt: failed to render error page: %v", err)
+		c.String(status, page.StatusText)
+		return
+	}
+	c.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.w.WriteHeader(status)
+	buf.WriteTo(c.w)
+}
+
+var productionErrorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>{{ .StatusText }}</title></head>
+<body>
+  <h1>{{ .StatusText }}</h1>
+  <p>{{ .Message }}</p>
+</body>
+</html>
+`))
+
+var developmentErrorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>{{ .Status }} {{ .StatusText }}</title></head>
+<body>
+  <h1>{{ .Status }} {{ .StatusText }}</h1>
+  <p>{{ .Method }} {{ .Path }}{{ with .Route }} ({{ . }}){{ end }}</p>
+  <h2>Causes</h2>
+  <ol>{{ range .Causes }}<li><code>{{ .Type }}</code> {{ .Message }}</li>{{ end }}</ol>
+  {{ with .Stack }}<h2>Stack</h2>
+  <pre>{{ . }}</pre>{{ end }}
+  {{ with .Params }}<h2>Params</h2>
+  <dl>{{ range $k, $v := . }}<dt>{{ $k }}</dt><dd>{{ range $v }}{{ . }} {{ end }}</dd>{{ end }}</dl>{{ end }}
+  {{ with .Logs }}<h2>Recent logs</h2>
+  <pre>{{ range . }}{{ . }}
+{{ end }}</pre>{{ end }}
+</body>
+</html>
+`))
+
+// maxRecentLogs is the number of log lines kept for development error pages.
+const maxRecentLogs = 50
+
+// recentLogs keeps the most recently logged lines in development.
+var recentLogs = &logRing{}
+
+// logRing is an io.Writer that keeps the last lines written to it.
+type logRing struct {
+	once sync.Once
+	mu   sync.Mutex
+	buf  []string
+}
+
+// capture tees the standard logger's output into the ring.
+func (l *logRing) capture() {
+	l.once.Do(func() {
+		log.SetOutput(io.MultiWriter(log.Writer(), l))
+	})
+}
+
+func (l *logRing) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.buf = append(l.buf, line)
+	}
+	if n := len(l.buf); n > maxRecentLogs {
+		l.buf = append([]string(nil), l.buf[n-maxRecentLogs:]...)
+	}
+	return len(p), nil
+}
+
+func (l *logRing) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.buf...)
+}
@@ -0,0 +1,106 @@
+package seatbelt
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// An Error is an error returned from a handler with the HTTP status code to
+// respond with, and the i18n message key of the message to show the user in
+// place of the error's own message.
+type Error struct {
+	Err        error
+	Code       int
+	MessageKey string
+
+	// The untranslated message shown when there's no message key, for
+	// errors that Seatbelt wraps itself.
+	message string
+}
+
+// WrapError attaches an HTTP status code and an i18n message key to the
+// given error, i.e.,
+//
+//	if err := payments.Charge(order); err != nil {
+//		return seatbelt.WrapError(err, http.StatusBadGateway, "errors.payment_unavailable")
+//	}
+//
+// The default error handler responds with the translated message at the
+// given status, while logging the full cause chain. WrapError returns nil if
+// err is nil.
+func WrapError(err error, code int, userMessageKey string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err, Code: code, MessageKey: userMessageKey}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// A PanicError is a panic recovered from a handler, which is handled like a
+// returned error.
+type PanicError struct {
+	// The value the handler panicked with.
+	Value interface{}
+
+	// The stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("seatbelt: handler panicked: %v", e.Value)
+}
+
+// Unwrap returns the value the handler panicked with, if it's an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverHandler calls the given handler, recovering a panic as a
+// *PanicError. Panics with http.ErrAbortHandler are left to the server, as
+// they abort the response on purpose.
+func recoverHandler(handle func(c *Context) error, c *Context) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return handle(c)
+}
+
+// userMessage returns the translated message to show the user for the
+// error, or the status text if it has no message key.
+func (e *Error) userMessage(c *Context) string {
+	if e.MessageKey == "" {
+		if e.message != "" {
+			return e.message
+		}
+		return http.StatusText(e.Code)
+	}
+	return c.I18N.T(e.MessageKey, nil)
+}
+
+// causeChain formats each error in the chain of wrapped errors on its own
+// line, along with its type, so that the root cause of an error is logged.
+func causeChain(err error) string {
+	var b strings.Builder
+	for i, cause := range errorCauses(err) {
+		if i > 0 {
+			b.WriteString("\n\tcaused by: ")
+		}
+		fmt.Fprintf(&b, "(%s) %s", cause.Type, cause.Message)
+	}
+	return b.String()
+}
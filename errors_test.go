@@ -0,0 +1,224 @@
+package seatbelt
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-seatbelt/seatbelt/captcha"
+)
+
+func TestWrapError(t *testing.T) {
+	app := New(Option{
+		LocaleDir:      filepath.Join("testdata", "locales"),
+		SkipServeFiles: true,
+	})
+
+	cause := errors.New("connection refused")
+	app.Get("/translated", func(c *Context) error {
+		return WrapError(fmt.Errorf("charging order: %w", cause), http.StatusBadGateway, "errors.unavailable")
+	})
+	app.Get("/untranslated", func(c *Context) error {
+		return WrapError(cause, http.StatusServiceUnavailable, "")
+	})
+
+	cases := []struct {
+		path    string
+		status  int
+		message string
+	}{
+		{"/translated", http.StatusBadGateway, "The payment service is unavailable, please try again."},
+		{"/untranslated", http.StatusServiceUnavailable, "Service Unavailable"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			resp := app.Invoke(http.MethodGet, c.path, nil)
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d but got %d", c.status, resp.StatusCode)
+			}
+			if body := resp.String(); !strings.Contains(body, c.message) {
+				t.Fatalf("expected %s but got %s", c.message, body)
+			}
+		})
+	}
+
+	t.Run("the cause is preserved", func(t *testing.T) {
+		err := WrapError(fmt.Errorf("charging order: %w", cause), http.StatusBadGateway, "")
+		if !errors.Is(err, cause) {
+			t.Fatalf("expected the wrapped error to match its cause")
+		}
+		if chain := causeChain(err); !strings.Contains(chain, "caused by: (*errors.errorString) connection refused") {
+			t.Fatalf("expected the cause chain to include the cause but got %s", chain)
+		}
+	})
+
+	t.Run("wrapping nil returns nil", func(t *testing.T) {
+		if err := WrapError(nil, http.StatusBadGateway, ""); err != nil {
+			t.Fatalf("expected nil but got %v", err)
+		}
+	})
+}
+
+func TestVerifyCaptchaFlash(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success": false}`))
+	}))
+	defer provider.Close()
+
+	app := New(Option{
+		SkipServeFiles: true,
+		Captcha: &captcha.Options{
+			Provider: captcha.Provider{ResponseField: "h-captcha-response", VerifyURL: provider.URL},
+		},
+	})
+	app.Get("/signup", func(c *Context) error {
+		var b strings.Builder
+		for _, f := range c.Flash.List() {
+			b.WriteString(f.Message)
+		}
+		return c.String(http.StatusOK, b.String())
+	})
+	app.Post("/signup", func(c *Context) error {
+		if err := c.VerifyCaptcha(); err != nil {
+			if !errors.Is(err, captcha.ErrVerificationFailed) {
+				t.Errorf("expected the error to wrap ErrVerificationFailed but got %v", err)
+			}
+			return err
+		}
+		return c.Redirect("/")
+	})
+
+	resp := app.Invoke(http.MethodPost, "/signup", strings.NewReader("h-captcha-response=wrong"), InvokeOptions{
+		SkipCSRF: true,
+		Headers: map[string]string{
+			"Content-Type": "application/x-www-form-urlencoded",
+			"Referer":      "/signup",
+		},
+	})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/signup" {
+		t.Fatalf("expected a redirect back to the form but got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp = app.Invoke(http.MethodGet, "/signup", nil, InvokeOptions{Cookies: resp.Cookies})
+	if expected := "Please solve the CAPTCHA and try again."; resp.String() != expected {
+		t.Fatalf("expected %s but got %s", expected, resp.String())
+	}
+}
+
+func TestErrorPage(t *testing.T) {
+	handler := func(c *Context) error {
+		return fmt.Errorf("loading user: %w", errors.New("database is down"))
+	}
+
+	t.Run("production pages don't expose the error", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+		app.Get("/users/{id}", handler)
+
+		resp := app.Invoke(http.MethodGet, "/users/1", nil)
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("expected %d but got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+		if body := resp.String(); strings.Contains(body, "database is down") || !strings.Contains(body, "Internal Server Error") {
+			t.Fatalf("expected a generic error page but got %s", body)
+		}
+	})
+
+	t.Run("development pages show the cause chain and redacted params", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true, Development: true})
+		app.Get("/users/{id}", handler)
+
+		body := app.Invoke(http.MethodGet, "/users/1?api_token=hunter2&q=cats", nil).String()
+		for _, s := range []string{"loading user: database is down", "*errors.errorString", "/users/{id}", "cats", "[REDACTED]"} {
+			if !strings.Contains(body, s) {
+				t.Fatalf("expected the error page to contain %s but got %s", s, body)
+			}
+		}
+		if strings.Contains(body, "hunter2") {
+			t.Fatalf("expected the token to be redacted but got %s", body)
+		}
+	})
+
+	t.Run("error templates are rendered", func(t *testing.T) {
+		app := New(Option{
+			TemplateDir:    filepath.Join("testdata", "templates"),
+			SkipServeFiles: true,
+		})
+		app.Get("/users/{id}", handler)
+
+		resp := app.Invoke(http.MethodGet, "/users/1", nil)
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("expected %d but got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+		if body := resp.String(); !strings.Contains(body, `<p class="error">500 Internal Server Error</p>`) {
+			t.Fatalf("expected the error template but got %s", body)
+		}
+	})
+
+	t.Run("unmatched routes render the 404 template in the layout", func(t *testing.T) {
+		app := New(Option{
+			TemplateDir:    filepath.Join("testdata", "templates"),
+			SkipServeFiles: true,
+		})
+
+		resp := app.Invoke(http.MethodGet, "/missing", nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected %d but got %d", http.StatusNotFound, resp.StatusCode)
+		}
+		body := resp.String()
+		if !strings.Contains(body, `<p class="not-found">404 Not Found</p>`) || !strings.Contains(body, "<!DOCTYPE html>") {
+			t.Fatalf("expected the 404 template in the layout but got %s", body)
+		}
+	})
+
+	t.Run("unmatched routes without templates use the default response", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+
+		resp := app.Invoke(http.MethodGet, "/missing", nil)
+		if resp.StatusCode != http.StatusNotFound || !strings.Contains(resp.String(), "404 page not found") {
+			t.Fatalf("expected the default 404 response but got %d %s", resp.StatusCode, resp.String())
+		}
+	})
+}
+
+func TestPanicRecovery(t *testing.T) {
+	handler := func(c *Context) error {
+		panic("nil map")
+	}
+
+	t.Run("panics render the error page", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true, Development: true})
+		app.Get("/", handler)
+
+		resp := app.Invoke(http.MethodGet, "/", nil)
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("expected %d but got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+		if body := resp.String(); !strings.Contains(body, "handler panicked: nil map") || !strings.Contains(body, "<h2>Stack</h2>") {
+			t.Fatalf("expected the panic and its stack trace but got %s", body)
+		}
+	})
+
+	t.Run("panics are passed to the custom error handler", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+		app.Get("/", handler)
+
+		var recovered *PanicError
+		app.SetErrorHandler(func(c *Context, err error) {
+			errors.As(err, &recovered)
+			c.String(http.StatusTeapot, "recovered")
+		})
+
+		resp := app.Invoke(http.MethodGet, "/", nil)
+		if resp.StatusCode != http.StatusTeapot {
+			t.Fatalf("expected %d but got %d", http.StatusTeapot, resp.StatusCode)
+		}
+		if recovered == nil || recovered.Value != "nil map" || len(recovered.Stack) == 0 {
+			t.Fatalf("expected a *PanicError with a stack trace but got %v", recovered)
+		}
+	})
+}
@@ -58,7 +58,7 @@ func main() {
 			c.Session.Set("session", v.Session)
 		}
 		if v.Flash != "" {
-			c.Flash.Add("notice", v.Flash)
+			c.Flash.Notice(v.Flash)
 		}
 
 		return c.Redirect("/session")
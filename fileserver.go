@@ -0,0 +1,128 @@
+package seatbelt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// immutableCacheControl is the Cache-Control header sent for fingerprinted
+// assets. One year is the longest max-age that caches are expected to honour.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// FileServerOptions configure the caching headers sent by FileServer.
+type FileServerOptions struct {
+	// CacheControl is the Cache-Control header sent with each file, i.e.,
+	// "public, max-age=3600". Default is "", meaning no header is sent.
+	CacheControl string
+
+	// ETag sends a strong ETag computed from each file's contents, so that
+	// conditional requests are answered with 304 Not Modified. ETags are
+	// cached until the file's size or modification time changes. Default is
+	// false.
+	ETag bool
+
+	// Immutable reports whether the file at the given request path is
+	// fingerprinted, in which case it's sent with a long-lived immutable
+	// Cache-Control header instead of CacheControl, i.e., Fingerprinted.
+	// Default is nil, meaning no files are treated as immutable.
+	Immutable func(path string) bool
+}
+
+// fingerprint matches a hex digest of at least 8 characters separated by a
+// dot or dash before the file extension, i.e., "app.3f2a9c1b.css".
+var fingerprint = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[^./]+$`)
+
+// Fingerprinted reports whether the file name in the given path contains a
+// content hash, i.e., "/public/app.3f2a9c1b.css" or "/js/main-8e1f0d2ac9.js".
+// It can be used as FileServerOptions.Immutable.
+func Fingerprinted(p string) bool {
+	return fingerprint.MatchString(path.Base(p))
+}
+
+// etagEntry is a cached ETag for a file, valid while its size and
+// modification time are unchanged.
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// etagCache computes and caches ETags for the files of a FileServer.
+type etagCache struct {
+	fs      http.FileSystem
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+// etag returns the ETag of the file with the given name, or "" if it doesn't
+// exist or is a directory.
+func (c *etagCache) etag(name string) string {
+	f, err := c.fs.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return ""
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.etag
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	entry = etagEntry{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		etag:    `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`,
+	}
+
+	c.mu.Lock()
+	c.entries[name] = entry
+	c.mu.Unlock()
+
+	return entry.etag
+}
+
+// cacheHeaders wraps the given file server handler, which must be mounted
+// after the prefix is stripped, to set the caching headers configured in opt.
+func cacheHeaders(fs http.FileSystem, next http.Handler, opt FileServerOptions) http.Handler {
+	cache := &etagCache{fs: fs, entries: make(map[string]etagEntry)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+
+		switch {
+		case opt.Immutable != nil && opt.Immutable(name):
+			w.Header().Set("Cache-Control", immutableCacheControl)
+		case opt.CacheControl != "":
+			w.Header().Set("Cache-Control", opt.CacheControl)
+		}
+
+		if opt.ETag {
+			if etag := cache.etag(path.Clean(name)); etag != "" {
+				w.Header().Set("ETag", etag)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,99 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileServerCaching(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.css":          "body {}",
+		"app.3f2a9c1b.css": "body {}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := New(Option{SkipServeFiles: true})
+	app.FileServer("/assets", dir, FileServerOptions{
+		CacheControl: "public, max-age=60",
+		ETag:         true,
+		Immutable:    Fingerprinted,
+	})
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("cache control", func(t *testing.T) {
+		w := get("/assets/app.css", "")
+		if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+			t.Fatalf("expected %s but got %s", "public, max-age=60", cc)
+		}
+	})
+
+	t.Run("immutable", func(t *testing.T) {
+		w := get("/assets/app.3f2a9c1b.css", "")
+		if cc := w.Header().Get("Cache-Control"); cc != immutableCacheControl {
+			t.Fatalf("expected %s but got %s", immutableCacheControl, cc)
+		}
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		w := get("/assets/app.css", "")
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("expected an ETag")
+		}
+
+		w = get("/assets/app.css", etag)
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("expected %d but got %d", http.StatusNotModified, w.Code)
+		}
+	})
+}
+
+func TestFileServerNamespaced(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := New(Option{SkipServeFiles: true})
+	app.Namespace("/engine", func(app *App) {
+		app.FileServer("/assets", dir)
+	})
+
+	resp := app.Invoke(http.MethodGet, "/engine/assets", nil)
+	if location := resp.Header.Get("Location"); resp.StatusCode != http.StatusMovedPermanently || location != "/engine/assets/" {
+		t.Fatalf("expected a redirect to /engine/assets/ but got %d %s", resp.StatusCode, location)
+	}
+	if resp := app.Invoke(http.MethodGet, "/engine/assets/app.css", nil); resp.String() != "body {}" {
+		t.Fatalf("expected the file but got %d %s", resp.StatusCode, resp.String())
+	}
+}
+
+func TestFingerprinted(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"/public/app.3f2a9c1b.css":  true,
+		"/js/main-8e1f0d2ac9.js":    true,
+		"/public/app.css":           false,
+		"/public/app.min.css":       false,
+		"/3f2a9c1b3f2a9c1b/app.css": false,
+	} {
+		if actual := Fingerprinted(path); actual != expected {
+			t.Fatalf("expected %v for %s but got %v", expected, path, actual)
+		}
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/go-chi/chi v1.5.4
 	github.com/gorilla/csrf v1.7.1
 	github.com/gorilla/securecookie v1.1.1
+	github.com/gorilla/websocket v1.5.0
 	github.com/mitchellh/mapstructure v1.4.3
 	github.com/nicksnyder/go-i18n/v2 v2.2.1
 	github.com/unrolled/render v1.5.0
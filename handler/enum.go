@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// An EnumOption is a single allowed value of an Enum, and the label used to
+// display it, i.e., in a select element.
+type EnumOption struct {
+	Value string
+	Label string
+}
+
+// An Enum is a fixed set of allowed values, each with a label.
+type Enum struct {
+	options []EnumOption
+}
+
+// NewEnum returns an Enum from the given value and label pairs, i.e.,
+//
+//	handler.NewEnum("admin", "Administrator", "member", "Member")
+//
+// NewEnum panics if pairs has an odd length.
+func NewEnum(pairs ...string) *Enum {
+	if len(pairs)%2 != 0 {
+		panic("seatbelt: NewEnum requires value and label pairs")
+	}
+
+	e := &Enum{options: make([]EnumOption, 0, len(pairs)/2)}
+	for i := 0; i < len(pairs); i += 2 {
+		e.options = append(e.options, EnumOption{Value: pairs[i], Label: pairs[i+1]})
+	}
+	return e
+}
+
+// Options returns the allowed values and their labels in the order they were
+// given, so that templates can range over them to render a select.
+func (e *Enum) Options() []EnumOption {
+	return e.options
+}
+
+// Values returns the allowed values in the order they were given.
+func (e *Enum) Values() []string {
+	values := make([]string, len(e.options))
+	for i, o := range e.options {
+		values[i] = o.Value
+	}
+	return values
+}
+
+// Contains reports whether v is one of the allowed values.
+func (e *Enum) Contains(v string) bool {
+	for _, o := range e.options {
+		if o.Value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Label returns the label for v, or v itself if it isn't an allowed value.
+func (e *Enum) Label(v string) string {
+	for _, o := range e.options {
+		if o.Value == v {
+			return o.Label
+		}
+	}
+	return v
+}
+
+// An Enumerable is a type whose values are restricted to an Enum. Struct
+// fields of an Enumerable type are validated by Params, i.e.,
+//
+//	type Role string
+//
+//	var roles = handler.NewEnum("admin", "Administrator", "member", "Member")
+//
+//	func (Role) Enum() *handler.Enum { return roles }
+type Enumerable interface {
+	Enum() *Enum
+}
+
+var enumerableType = reflect.TypeOf((*Enumerable)(nil)).Elem()
+
+// enumHook is a mapstructure decode hook that rejects values that aren't
+// allowed by the Enum of the target type. Empty values are left to decode as
+// the zero value.
+func enumHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	// Optional fields are pointers, whose zero value is nil, so the Enum is
+	// taken from the type they point to.
+	for to.Kind() == reflect.Ptr {
+		to = to.Elem()
+	}
+
+	// Fields of an interface type have no Enum until they hold a value.
+	var e Enumerable
+	switch {
+	case to.Kind() == reflect.Interface:
+		return data, nil
+	case to.Implements(enumerableType):
+		e = reflect.Zero(to).Interface().(Enumerable)
+	case reflect.PtrTo(to).Implements(enumerableType):
+		e = reflect.New(to).Interface().(Enumerable)
+	default:
+		return data, nil
+	}
+
+	v := fmt.Sprint(data)
+	if v == "" {
+		return data, nil
+	}
+
+	enum := e.Enum()
+	if !enum.Contains(v) {
+		return nil, fmt.Errorf("%q is not one of %s", v, strings.Join(enum.Values(), ", "))
+	}
+	return data, nil
+}
@@ -0,0 +1,32 @@
+package handler_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-seatbelt/seatbelt/apptest"
+	"github.com/go-seatbelt/seatbelt/handler"
+)
+
+func FuzzParams(f *testing.F) {
+	apptest.AddParamsCorpus(f)
+
+	f.Fuzz(func(t *testing.T, contentType, query string, body []byte) {
+		s := struct {
+			Name   string            `params:"name"`
+			Age    int               `params:"age"`
+			Admin  bool              `params:"admin"`
+			Role   role              `params:"role"`
+			Tags   []string          `params:"tags"`
+			Fields map[string]string `params:"fields"`
+		}{}
+		r := apptest.NewParamsRequest(contentType, query, body)
+		handler.Params(httptest.NewRecorder(), r, nil, &s, handler.ParamsOptions{
+			FieldNaming: handler.SnakeCase,
+		})
+
+		m := make(map[string]interface{})
+		r = apptest.NewParamsRequest(contentType, query, body)
+		handler.Params(httptest.NewRecorder(), r, nil, &m)
+	})
+}
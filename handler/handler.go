@@ -3,10 +3,13 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -40,18 +43,119 @@ func Redirect(w http.ResponseWriter, r *http.Request, url string) {
 	http.Redirect(w, r, url, code)
 }
 
+// A FieldNaming converts the name of a Go struct field to the name used for
+// it in params and JSON, i.e., SnakeCase. It must only depend on the given
+// name, as the names of each struct type are cached.
+type FieldNaming func(name string) string
+
+// SnakeCase converts a Go identifier to snake case, i.e., "FirstName" becomes
+// "first_name", and "UserID" becomes "user_id". Names that are already in
+// snake case are returned unchanged.
+func SnakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' {
+				prev := runes[i-1]
+				nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// A JSONEncoder encodes values as JSON to a writer, i.e., a *json.Encoder or
+// the encoder of a faster JSON library.
+type JSONEncoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+	SetEscapeHTML(on bool)
+}
+
+// JSONOptions configure how JSON responses are encoded.
+type JSONOptions struct {
+	// FieldNaming names the struct fields without a json tag in the
+	// response, i.e., SnakeCase to serialize a field FirstName as
+	// "first_name". Tagged fields, map keys, and types that implement
+	// json.Marshaler are encoded as they are. Default is nil, meaning the
+	// encoding/json defaults are used.
+	FieldNaming FieldNaming
+
+	// Indent pretty prints the response, indenting each level with the
+	// given string, i.e., "  ". Default is "", meaning the response is
+	// compact.
+	Indent string
+
+	// SkipEscapeHTML leaves the characters <, >, and & in strings as they
+	// are, instead of escaping them as \u003c, \u003e, and \u0026. Default is
+	// false.
+	SkipEscapeHTML bool
+
+	// Stream encodes the response directly to the response writer, instead
+	// of encoding it into a buffer first, i.e., for large responses. The
+	// response is sent without a Content-Length, and if encoding fails part
+	// way through, the response is cut short. Default is false.
+	Stream bool
+
+	// NewEncoder returns the encoder to use, i.e., that of a faster JSON
+	// library such as jsoniter. Default is nil, meaning json.NewEncoder is
+	// used.
+	NewEncoder func(w io.Writer) JSONEncoder
+}
+
+// encode encodes the given value to w, with the options' encoder.
+func (o JSONOptions) encode(w io.Writer, v interface{}) error {
+	var enc JSONEncoder
+	if o.NewEncoder != nil {
+		enc = o.NewEncoder(w)
+	} else {
+		enc = json.NewEncoder(w)
+	}
+	enc.SetIndent("", o.Indent)
+	enc.SetEscapeHTML(!o.SkipEscapeHTML)
+	return enc.Encode(v)
+}
+
 // JSON sends a JSON response with the given status code.
-func JSON(w http.ResponseWriter, code int, v interface{}) error {
-	data, err := json.Marshal(v)
-	if err != nil {
-		return err
+func JSON(w http.ResponseWriter, code int, v interface{}, opts ...JSONOptions) error {
+	var o JSONOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	if o.FieldNaming != nil {
+		named, err := o.encodeNamed(v)
+		if err != nil {
+			return err
+		}
+		v = named
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if o.Stream {
+		w.WriteHeader(code)
+		return o.encode(w, v)
+	}
+
+	var buf bytes.Buffer
+	if err := o.encode(&buf, v); err != nil {
+		return err
+	}
+	// Encoders end each value with a newline, which json.Marshal doesn't.
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
 	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.WriteHeader(code)
 
-	_, err = w.Write(data)
+	_, err := w.Write(data)
 	return err
 }
 
@@ -75,6 +179,15 @@ func JSON(w http.ResponseWriter, code int, v interface{}) error {
 // as an argument in the Params function.
 type PathParamFunc func(r *http.Request, v map[string]interface{})
 
+// ParamsOptions configure how params are assigned in Params.
+type ParamsOptions struct {
+	// FieldNaming allows struct fields without a params tag to be assigned
+	// from params named with the given naming, i.e., with SnakeCase, a field
+	// FirstName is assigned from "first_name". Fields can still be assigned
+	// by their case-insensitive Go name. Default is nil.
+	FieldNaming FieldNaming
+}
+
 // Params mass-assigns query, path, and form parameters to the given struct or
 // map, similar to how Rails mass-assignment works.
 //
@@ -91,8 +204,16 @@ type PathParamFunc func(r *http.Request, v map[string]interface{})
 // For POST, PUT, PATCH, and DELETE requests, the body will be read. For any
 // other request, it will not.
 //
+// Fields whose type implements Enumerable must be assigned one of the values
+// of their Enum, or Params returns an error.
+//
 // See also the GoDoc string for PathParamFunc.
-func Params(w http.ResponseWriter, r *http.Request, pathParamFunc PathParamFunc, v interface{}) error {
+func Params(w http.ResponseWriter, r *http.Request, pathParamFunc PathParamFunc, v interface{}, opts ...ParamsOptions) error {
+	var o ParamsOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
 	var err error
 	if r.Header.Get("Content-Type") == "multipart/form-data" {
 		err = r.ParseMultipartForm(defaultMaxMemory)
@@ -131,6 +252,11 @@ func Params(w http.ResponseWriter, r *http.Request, pathParamFunc PathParamFunc,
 		pathParamFunc(r, values)
 	}
 
+	return decode(values, v, o)
+}
+
+// decode assigns the given values to the given struct or map.
+func decode(values map[string]interface{}, v interface{}, o ParamsOptions) error {
 	// The config below is the same as mapstructure's `WeakDecode`, but with
 	// the tag name "params" instead of "mapstructure".
 	config := &mapstructure.DecoderConfig{
@@ -138,6 +264,12 @@ func Params(w http.ResponseWriter, r *http.Request, pathParamFunc PathParamFunc,
 		Result:           v,
 		WeaklyTypedInput: true,
 		TagName:          "params",
+		DecodeHook:       enumHook,
+	}
+	if o.FieldNaming != nil {
+		config.MatchName = func(mapKey, fieldName string) bool {
+			return strings.EqualFold(mapKey, fieldName) || mapKey == o.FieldNaming(fieldName)
+		}
 	}
 
 	decoder, err := mapstructure.NewDecoder(config)
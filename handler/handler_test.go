@@ -3,10 +3,13 @@ package handler_test
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/go-seatbelt/seatbelt/handler"
 )
@@ -115,3 +118,248 @@ func TestParamsURLAndJSONOverwrite(t *testing.T) {
 
 	expectEqual(t, s.Name, "test")
 }
+
+func TestSnakeCase(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"Name":       "name",
+		"FirstName":  "first_name",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"Address2":   "address2",
+		"first_name": "first_name",
+	}
+
+	for in, expected := range cases {
+		expectEqual(t, expected, handler.SnakeCase(in))
+	}
+}
+
+func TestParamsFieldNaming(t *testing.T) {
+	t.Parallel()
+
+	s := &struct {
+		FirstName string
+		LastName  string `params:"surname"`
+	}{}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?first_name=test&surname=test2", nil)
+
+	if err := handler.Params(w, r, nil, s, handler.ParamsOptions{FieldNaming: handler.SnakeCase}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEqual(t, "test", s.FirstName)
+	expectEqual(t, "test2", s.LastName)
+}
+
+func TestJSONFieldNaming(t *testing.T) {
+	t.Parallel()
+
+	v := struct {
+		FirstName string
+		Pets      []struct{ PetName string }
+		Age       int `json:"age"`
+	}{
+		FirstName: "test",
+		Pets:      []struct{ PetName string }{{PetName: "cat"}},
+		Age:       3,
+	}
+
+	w := httptest.NewRecorder()
+	if err := handler.JSON(w, http.StatusOK, v, handler.JSONOptions{FieldNaming: handler.SnakeCase}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEqual(t, `{"first_name":"test","pets":[{"pet_name":"cat"}],"age":3}`, w.Body.String())
+}
+
+func TestJSONFieldNamingLeavesTagsAndMaps(t *testing.T) {
+	t.Parallel()
+
+	type Base struct {
+		CreatedAt time.Time
+	}
+	v := struct {
+		Base
+		UserID     int                    `json:"userId"`
+		Nickname   string                 `json:",omitempty"`
+		Attributes map[string]interface{} `json:"attributes"`
+		Metadata   map[string]struct{ ExternalKey string }
+		Raw        json.RawMessage
+	}{
+		Base:       Base{CreatedAt: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		UserID:     1,
+		Attributes: map[string]interface{}{"ExternalKey": "a", "Nested": map[string]int{"InnerKey": 1}},
+		Metadata:   map[string]struct{ ExternalKey string }{"FirstKey": {ExternalKey: "b"}},
+		Raw:        json.RawMessage(`{"KeptAsIs":true}`),
+	}
+
+	w := httptest.NewRecorder()
+	if err := handler.JSON(w, http.StatusOK, v, handler.JSONOptions{FieldNaming: handler.SnakeCase}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEqual(t, `{"created_at":"2000-01-01T00:00:00Z","userId":1,"attributes":{"ExternalKey":"a","Nested":{"InnerKey":1}},"metadata":{"FirstKey":{"external_key":"b"}},"raw":{"KeptAsIs":true}}`, w.Body.String())
+}
+
+func TestJSONOptions(t *testing.T) {
+	t.Parallel()
+
+	v := map[string]interface{}{"html": "<b>", "n": 1}
+
+	t.Run("indent and skip escaping HTML", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if err := handler.JSON(w, http.StatusOK, v, handler.JSONOptions{Indent: "  ", SkipEscapeHTML: true}); err != nil {
+			t.Fatal(err)
+		}
+		expectEqual(t, "{\n  \"html\": \"<b>\",\n  \"n\": 1\n}", w.Body.String())
+		expectEqual(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+	})
+
+	t.Run("stream without a Content-Length", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if err := handler.JSON(w, http.StatusCreated, v, handler.JSONOptions{Stream: true}); err != nil {
+			t.Fatal(err)
+		}
+		expectEqual(t, `{"html":"\u003cb\u003e","n":1}`+"\n", w.Body.String())
+		expectEqual(t, "", w.Header().Get("Content-Length"))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected %d but got %d", http.StatusCreated, w.Code)
+		}
+	})
+
+	t.Run("custom encoder", func(t *testing.T) {
+		var encoded bool
+		w := httptest.NewRecorder()
+		err := handler.JSON(w, http.StatusOK, v, handler.JSONOptions{
+			NewEncoder: func(w io.Writer) handler.JSONEncoder {
+				encoded = true
+				return json.NewEncoder(w)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !encoded {
+			t.Fatalf("expected the custom encoder to be used")
+		}
+		expectEqual(t, `{"html":"\u003cb\u003e","n":1}`, w.Body.String())
+	})
+}
+
+type role string
+
+var roles = handler.NewEnum("admin", "Administrator", "member", "Member")
+
+func (role) Enum() *handler.Enum { return roles }
+
+func TestParamsEnum(t *testing.T) {
+	t.Parallel()
+
+	t.Run("allowed value", func(t *testing.T) {
+		s := &struct {
+			Role role `params:"role"`
+		}{}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/?role=admin", nil)
+
+		if err := handler.Params(w, r, nil, s); err != nil {
+			t.Fatal(err)
+		}
+		expectEqual(t, role("admin"), s.Role)
+	})
+
+	t.Run("unknown value", func(t *testing.T) {
+		s := &struct {
+			Role role `params:"role"`
+		}{}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/?role=owner", nil)
+
+		if err := handler.Params(w, r, nil, s); err == nil {
+			t.Fatalf("expected an error for an unknown enum value")
+		}
+	})
+
+	t.Run("optional value", func(t *testing.T) {
+		s := &struct {
+			Role *role `params:"role"`
+		}{}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/?role=member", nil)
+
+		if err := handler.Params(w, r, nil, s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Role == nil {
+			t.Fatalf("expected the optional role to be set")
+		}
+		expectEqual(t, role("member"), *s.Role)
+
+		r = httptest.NewRequest(http.MethodGet, "/?role=owner", nil)
+		if err := handler.Params(w, r, nil, s); err == nil {
+			t.Fatalf("expected an error for an unknown optional enum value")
+		}
+	})
+
+	t.Run("interface value", func(t *testing.T) {
+		s := &struct {
+			Role handler.Enumerable `params:"role"`
+		}{}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/?role=admin", nil)
+
+		// The value can't be decoded into an interface, but it must not
+		// panic.
+		handler.Params(w, r, nil, s)
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		expectEqual(t, "Administrator", roles.Label("admin"))
+		expectEqual(t, []string{"admin", "member"}, roles.Values())
+	})
+}
+
+type userSearch struct {
+	Query    string   `params:"q"`
+	Roles    []string `params:"role"`
+	SortBy   string
+	Page     int `params:"page"`
+	internal string
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	s := &userSearch{SortBy: "name", Page: 1}
+	r := httptest.NewRequest(http.MethodPost, "/?q=ada&role=admin&role=member&page=3", bytes.NewBufferString(`{"q": "body"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	if err := handler.Query(r, s, handler.ParamsOptions{FieldNaming: handler.SnakeCase}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEqual(t, "ada", s.Query)
+	expectEqual(t, []string{"admin", "member"}, s.Roles)
+	expectEqual(t, "name", s.SortBy)
+	expectEqual(t, 3, s.Page)
+}
+
+func TestQueryValues(t *testing.T) {
+	t.Parallel()
+
+	s := userSearch{Roles: []string{"admin", "member"}, SortBy: "created_at", Page: 2, internal: "x"}
+
+	values := handler.QueryValues(s, handler.ParamsOptions{FieldNaming: handler.SnakeCase})
+	expectEqual(t, "page=2&role=admin&role=member&sort_by=created_at", values.Encode())
+
+	values = handler.QueryValues(&s)
+	expectEqual(t, "page=2&role=admin&role=member&sortby=created_at", values.Encode())
+}
@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// maxNamingDepth is the depth at which a value is assumed to contain a
+// cycle, as encoding/json does.
+const maxNamingDepth = 1000
+
+// A namedField is a struct field as it is encoded by encoding/json, with the
+// key given by its json tag, or by the field naming if it has none.
+type namedField struct {
+	index     []int
+	key       string
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+// namedFieldsKey identifies the fields of a struct type under a field
+// naming. Funcs can't be compared, so namings are told apart by their code
+// pointer, which is why a FieldNaming must only depend on the name.
+type namedFieldsKey struct {
+	t      reflect.Type
+	naming uintptr
+}
+
+var namedFieldsCache sync.Map // map[namedFieldsKey][]namedField
+
+// namedFields returns the encoded fields of the given struct type, in the
+// order encoding/json encodes them, including the fields promoted from
+// embedded structs.
+func namedFields(t reflect.Type, naming FieldNaming) []namedField {
+	key := namedFieldsKey{t: t, naming: reflect.ValueOf(naming).Pointer()}
+	if fields, ok := namedFieldsCache.Load(key); ok {
+		return fields.([]namedField)
+	}
+
+	var fields []namedField
+	collectFields(t, nil, naming, &fields)
+
+	// As with encoding/json, the shallowest field with a key wins, then
+	// the tagged one, and fields that still conflict are all dropped.
+	byKey := make(map[string][]int)
+	for i, f := range fields {
+		byKey[f.key] = append(byKey[f.key], i)
+	}
+	dominant := fields[:0:0]
+	for i, f := range fields {
+		candidates := byKey[f.key]
+		if len(candidates) > 1 && !dominates(fields, i, candidates) {
+			continue
+		}
+		dominant = append(dominant, f)
+	}
+
+	namedFieldsCache.Store(key, dominant)
+	return dominant
+}
+
+// dominates reports whether the field at i wins over the other fields with
+// the same key.
+func dominates(fields []namedField, i int, candidates []int) bool {
+	f := fields[i]
+	for _, j := range candidates {
+		if j == i {
+			continue
+		}
+		g := fields[j]
+		switch {
+		case len(g.index) < len(f.index):
+			return false
+		case len(g.index) > len(f.index):
+		case g.tagged == f.tagged:
+			return false
+		case g.tagged:
+			return false
+		}
+	}
+	return true
+}
+
+// collectFields appends the encoded fields of the given struct type, whose
+// values are at the given index within the outermost struct.
+func collectFields(t reflect.Type, index []int, naming FieldNaming, fields *[]namedField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		if f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if name == "" && ft.Kind() == reflect.Struct {
+				collectFields(ft, fieldIndex, naming, fields)
+				continue
+			}
+			// The exported fields of unexported embedded structs are
+			// promoted, but the structs themselves can't be encoded.
+			if !f.IsExported() {
+				continue
+			}
+		} else if !f.IsExported() {
+			continue
+		}
+
+		nf := namedField{index: fieldIndex, key: name, tagged: name != ""}
+		if nf.key == "" {
+			nf.key = naming(f.Name)
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				nf.omitEmpty = true
+			case "string":
+				switch f.Type.Kind() {
+				case reflect.Bool, reflect.String,
+					reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+					reflect.Float32, reflect.Float64:
+					nf.quoted = true
+				}
+			}
+		}
+		*fields = append(*fields, nf)
+	}
+}
+
+// fieldByIndex returns the field at the given index, or false if it's
+// promoted from an embedded struct pointer that is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty as defined by the omitempty
+// option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+	return false
+}
+
+// namingEncoder encodes values as JSON with the keys of struct fields without
+// a json tag given by a field naming. Everything else, i.e., tagged fields,
+// map keys, and types that marshal themselves, is encoded by the options'
+// encoder as it is.
+type namingEncoder struct {
+	opts JSONOptions
+	buf  bytes.Buffer
+}
+
+// encodeNamed returns the given value encoded as JSON with the options'
+// field naming.
+func (o JSONOptions) encodeNamed(v interface{}) (json.RawMessage, error) {
+	e := &namingEncoder{opts: o}
+	if err := e.value(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// leaf encodes the given value with the options' encoder.
+func (e *namingEncoder) leaf(v interface{}) error {
+	var buf bytes.Buffer
+	if err := e.opts.encode(&buf, v); err != nil {
+		return err
+	}
+	e.buf.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return nil
+}
+
+func (e *namingEncoder) value(v reflect.Value, depth int) error {
+	if depth > maxNamingDepth {
+		return errors.New("json: unsupported value: encountered a cycle")
+	}
+	if !v.IsValid() {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	t := v.Type()
+	if t.Kind() != reflect.Interface && (t.Implements(marshalerType) || t.Implements(textMarshalerType)) {
+		return e.leaf(v.Interface())
+	}
+	if v.CanAddr() && (reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) {
+		return e.leaf(v.Addr().Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		return e.value(v.Elem(), depth+1)
+
+	case reflect.Struct:
+		e.buf.WriteByte('{')
+		first := true
+		for _, f := range namedFields(t, e.opts.FieldNaming) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			if !first {
+				e.buf.WriteByte(',')
+			}
+			first = false
+
+			if err := e.leaf(f.key); err != nil {
+				return err
+			}
+			e.buf.WriteByte(':')
+			if f.quoted {
+				var quoted bytes.Buffer
+				if err := e.opts.encode(&quoted, fv.Interface()); err != nil {
+					return err
+				}
+				if err := e.leaf(string(bytes.TrimSuffix(quoted.Bytes(), []byte("\n")))); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := e.value(fv, depth+1); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte('}')
+		return nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+
+		type entry struct {
+			key string
+			v   reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := iter.Key()
+			var key string
+			switch k.Kind() {
+			case reflect.String:
+				key = k.String()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				key = strconv.FormatInt(k.Int(), 10)
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				key = strconv.FormatUint(k.Uint(), 10)
+			default:
+				// Other keys are left to the encoder, along with their
+				// values.
+				return e.leaf(v.Interface())
+			}
+			entries = append(entries, entry{key: key, v: iter.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+		e.buf.WriteByte('{')
+		for i, en := range entries {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.leaf(en.key); err != nil {
+				return err
+			}
+			e.buf.WriteByte(':')
+			if err := e.value(en.v, depth+1); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte('}')
+		return nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64.
+			return e.leaf(v.Interface())
+		}
+		fallthrough
+
+	case reflect.Array:
+		e.buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.value(v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+		return nil
+	}
+
+	return e.leaf(v.Interface())
+}
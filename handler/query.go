@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Query assigns the query parameters of the request to the given struct or
+// map, i.e., the filters, sort order, and page of a list page. Fields whose
+// parameter isn't in the query keep their value, so defaults can be set
+// before calling Query. Parameters given more than once are assigned to
+// slice fields as a whole.
+//
+// Unlike Params, the request body and path params are ignored.
+func Query(r *http.Request, v interface{}, opts ...ParamsOptions) error {
+	var o ParamsOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	values := make(map[string]interface{})
+	for key, val := range r.URL.Query() {
+		if len(val) == 1 {
+			values[key] = val[0]
+		} else {
+			values[key] = val
+		}
+	}
+	return decode(values, v, o)
+}
+
+// QueryValues returns the fields of the given struct as query parameters,
+// the reverse of Query. Fields with their zero value are omitted, so that
+// url.Values.Encode returns the same canonical query string for the same
+// params. Fields are named by their params tag, or else by the given field
+// naming, or else by their lowercase Go name.
+func QueryValues(v interface{}, opts ...ParamsOptions) url.Values {
+	var o ParamsOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	values := make(url.Values)
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return values
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("params"), ",")[0]
+		switch {
+		case name == "-":
+			continue
+		case name != "":
+		case o.FieldNaming != nil:
+			name = o.FieldNaming(field.Name)
+		default:
+			name = strings.ToLower(field.Name)
+		}
+
+		fv := rv.Field(i)
+		if fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(name, fmt.Sprint(fv.Index(j).Interface()))
+			}
+			continue
+		}
+		values.Set(name, fmt.Sprint(fv.Interface()))
+	}
+	return values
+}
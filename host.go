@@ -0,0 +1,113 @@
+package seatbelt
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// A hostRoute is an application constrained to requests whose host matches
+// a pattern.
+type hostRoute struct {
+	pattern string
+	labels  []string
+	app     *App
+}
+
+// match returns the params of the given host if it matches the pattern.
+func (hr *hostRoute) match(host string) (map[string]string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	labels := strings.Split(strings.ToLower(host), ".")
+	if len(labels) != len(hr.labels) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, label := range hr.labels {
+		if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+			if labels[i] == "" {
+				return nil, false
+			}
+			params[label[1:len(label)-1]] = labels[i]
+			continue
+		}
+		if label != labels[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Host creates a new *seatbelt.App that handles all requests whose host
+// matches the given pattern. Each label of the pattern is either matched
+// literally, or is a `{param}` that matches any single label, and is
+// available to handlers with c.PathParam, i.e.,
+//
+//	app.Host("{account}.example.com", func(app *seatbelt.App) {
+//		app.Get("/", func(c *seatbelt.Context) error {
+//			return c.String(200, "Welcome, "+c.PathParam("account"))
+//		})
+//	})
+//
+// Requests to a matching host are routed exclusively to the host's
+// application, and patterns are matched in the order in which they were
+// registered. The host's application inherits the application's middleware.
+//
+// Host can only be called on the application returned by New.
+func (a *App) Host(pattern string, fn func(app *App)) *App {
+	if fn == nil {
+		panic(fmt.Sprintf("seatbelt: attempting to Host() a nil sub-app on '%s'", pattern))
+	}
+	if a.parent != nil {
+		panic(fmt.Sprintf("seatbelt: Host() on '%s' must be called on the root application", pattern))
+	}
+
+	hostApp := a.child(chi.NewRouter(), "")
+	hostApp.host = pattern
+	fn(hostApp)
+
+	a.hosts = append(a.hosts, &hostRoute{
+		pattern: pattern,
+		labels:  strings.Split(strings.ToLower(pattern), "."),
+		app:     hostApp,
+	})
+
+	return hostApp
+}
+
+// routeHosts is the middleware that routes requests to the application of
+// the first host pattern that matches the request's host.
+func (a *App) routeHosts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, hr := range a.hosts {
+			params, ok := hr.match(r.Host)
+			if !ok {
+				continue
+			}
+
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				for k, v := range params {
+					rctx.URLParams.Add(k, v)
+				}
+			}
+
+			// The standard middleware registered on the application runs
+			// after this middleware, so it has to be applied to the host's
+			// application explicitly.
+			var h http.Handler = hr.app.mux
+			for i := len(a.std) - 1; i >= 0; i-- {
+				h = a.std[i](h)
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
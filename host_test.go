@@ -0,0 +1,44 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Get("/", func(c *Context) error {
+		return c.String(200, "apex")
+	})
+	app.Host("{account}.example.com", func(app *App) {
+		app.Get("/", func(c *Context) error {
+			return c.String(200, "account "+c.PathParam("account"))
+		})
+	})
+
+	cases := []struct {
+		host string
+		body string
+	}{
+		{host: "example.com", body: "apex"},
+		{host: "acme.example.com", body: "account acme"},
+		{host: "acme.example.com:3000", body: "account acme"},
+		{host: "a.b.example.com", body: "apex"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.host, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = c.host
+			rr := httptest.NewRecorder()
+
+			app.ServeHTTP(rr, r)
+
+			if body := rr.Body.String(); body != c.body {
+				t.Fatalf("expected %s but got %s", c.body, body)
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package i18n
 
 import (
 	"encoding/json"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"golang.org/x/text/language"
@@ -14,6 +16,9 @@ type Translator struct {
 	path          string
 	bundle        *i18n.Bundle
 	isDevelopment bool
+
+	mu  sync.Mutex
+	fss []fs.FS
 }
 
 // New creates a new instance of a translator from the given file path.
@@ -31,7 +36,40 @@ func New(path string, isDevelopment bool) *Translator {
 	return translator
 }
 
+// AddFS adds the translation files in fsys, i.e., those of a reusable
+// package. Translations in the translator's path take precedence over those
+// with the same ID in fsys.
+func (t *Translator) AddFS(fsys fs.FS) {
+	t.mu.Lock()
+	t.fss = append(t.fss, fsys)
+	t.mu.Unlock()
+
+	t.parseTranslationFiles()
+}
+
 func (t *Translator) parseTranslationFiles() {
+	// Translations from added file systems are loaded first, so that the
+	// translations in the path override them.
+	t.mu.Lock()
+	fss := t.fss
+	t.mu.Unlock()
+
+	for _, fsys := range fss {
+		if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			buf, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			_, err = t.bundle.ParseMessageFileBytes(buf, path)
+			return err
+		}); err != nil {
+			panic(err)
+		}
+	}
+
 	// If the path is an empty string, we'll fall back to the default bundle,
 	// which will output the "translation missing" error for every string.
 	// Otherwise, load the translation data from the given filepath.
@@ -77,6 +115,23 @@ func (t *Translator) T(r *http.Request, id string, data map[string]interface{},
 	return text
 }
 
+// Locale returns the language of the translations for the given request,
+// i.e., "fr", matched from its "locale" query param and its Accept-Language
+// header. It returns the default language, English, if neither matches.
+func (t *Translator) Locale(r *http.Request) string {
+	var requested []language.Tag
+	if tag, err := language.Parse(r.URL.Query().Get("locale")); err == nil {
+		requested = append(requested, tag)
+	}
+	if tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil {
+		requested = append(requested, tags...)
+	}
+
+	supported := t.bundle.LanguageTags()
+	_, i, _ := language.NewMatcher(supported).Match(requested...)
+	return supported[i].String()
+}
+
 // TODO Make this work the exact same as i18n.NewLocalizer
 func guessLang(langs ...string) string {
 	defaultLang := language.English.String()
@@ -21,3 +21,24 @@ func TestTranslator(t *testing.T) {
 		t.Fatalf("expected %s but got %s", expected, s)
 	}
 }
+
+func TestLocale(t *testing.T) {
+	translator := New("testdata", false)
+
+	for _, tt := range []struct {
+		target   string
+		accept   string
+		expected string
+	}{
+		{"/", "", "en"},
+		{"/?locale=fr", "", "fr"},
+		{"/", "de-DE, fr-CA;q=0.8", "fr"},
+		{"/", "de-DE", "en"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Accept-Language", tt.accept)
+		if locale := translator.Locale(req); locale != tt.expected {
+			t.Fatalf("expected %s but got %s", tt.expected, locale)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package seatbelt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ICSMediaType is the content type of iCalendar feeds.
+const ICSMediaType = "text/calendar"
+
+// A CalendarEvent is an event in an iCalendar feed rendered with c.ICS.
+type CalendarEvent struct {
+	uid         string
+	summary     string
+	description string
+	location    string
+	url         string
+	start       time.Time
+	end         time.Time
+	allDay      bool
+	updated     time.Time
+}
+
+// NewCalendarEvent returns a new event with the given unique ID, i.e.,
+// "meeting-42@example.com", and summary. The ID must not change between
+// requests, so that calendar clients update the event instead of adding a
+// copy of it, i.e.,
+//
+//	event := seatbelt.NewCalendarEvent("meeting-42@example.com", "Standup").
+//		At(start, start.Add(15*time.Minute)).
+//		In(berlin).
+//		WithLocation("Room 1")
+func NewCalendarEvent(uid, summary string) *CalendarEvent {
+	return &CalendarEvent{uid: uid, summary: summary}
+}
+
+// At sets the start and end time of the event. The event is in the time
+// zone of start, unless one is set with In.
+func (e *CalendarEvent) At(start, end time.Time) *CalendarEvent {
+	e.start, e.end, e.allDay = start, end, false
+	return e
+}
+
+// AllDay makes the event last the whole of the given day, regardless of the
+// time zone of the calendar client.
+func (e *CalendarEvent) AllDay(day time.Time) *CalendarEvent {
+	e.start = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	e.end = e.start.AddDate(0, 0, 1)
+	e.allDay = true
+	return e
+}
+
+// In sets the time zone of the event, i.e., so that a weekly meeting at 9am
+// stays at 9am across daylight saving time changes. The instants set with
+// At are unchanged.
+func (e *CalendarEvent) In(loc *time.Location) *CalendarEvent {
+	if !e.allDay {
+		e.start, e.end = e.start.In(loc), e.end.In(loc)
+	}
+	return e
+}
+
+// WithDescription sets the description of the event.
+func (e *CalendarEvent) WithDescription(description string) *CalendarEvent {
+	e.description = description
+	return e
+}
+
+// WithLocation sets the location of the event.
+func (e *CalendarEvent) WithLocation(location string) *CalendarEvent {
+	e.location = location
+	return e
+}
+
+// WithURL sets the URL of the event, i.e., its page in the application.
+func (e *CalendarEvent) WithURL(url string) *CalendarEvent {
+	e.url = url
+	return e
+}
+
+// UpdatedAt sets when the event was last changed. Default is the time the
+// feed is rendered.
+func (e *CalendarEvent) UpdatedAt(t time.Time) *CalendarEvent {
+	e.updated = t
+	return e
+}
+
+// ICS renders the given events as an RFC 5545 iCalendar feed, i.e., for
+// calendar clients to subscribe to. Time zones used by the events are
+// included in the feed.
+//
+// The feed is sent with an ETag, and clients must revalidate it on each
+// request, so that unchanged feeds are answered with 304 Not Modified.
+func (c *context) ICS(code int, events []*CalendarEvent) error {
+	body := renderICS(events, time.Now())
+
+	h := c.w.Header()
+	h.Set("Content-Type", ICSMediaType+"; charset=utf-8")
+	h.Set("Cache-Control", "private, no-cache")
+	if code == http.StatusOK {
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+		if match := c.r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+			c.w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	c.w.WriteHeader(code)
+	_, err := c.w.Write(body)
+	return err
+}
+
+// icsWriter writes the content lines of an iCalendar feed.
+type icsWriter struct {
+	bytes.Buffer
+}
+
+// line writes a content line, folding it into lines of at most 75 octets
+// without splitting UTF-8 characters.
+func (w *icsWriter) line(s string) {
+	for len(s) > 75 {
+		n := 75
+		for n > 0 && s[n]&0xC0 == 0x80 {
+			n--
+		}
+		w.WriteString(s[:n])
+		w.WriteString("\r\n ")
+		s = s[n:]
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// text writes a content line with the given property and text value, if the
+// value is set.
+func (w *icsWriter) text(property, value string) {
+	if value == "" {
+		return
+	}
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	w.line(property + ":" + r.Replace(value))
+}
+
+// datetime writes a content line with the given property and date-time value.
+func (w *icsWriter) datetime(property string, t time.Time, allDay bool) {
+	switch {
+	case allDay:
+		w.line(property + ";VALUE=DATE:" + t.Format("20060102"))
+	case t.Location() == time.UTC || t.Location() == time.Local:
+		w.line(property + ":" + t.UTC().Format("20060102T150405Z"))
+	default:
+		w.line(property + ";TZID=" + t.Location().String() + ":" + t.Format("20060102T150405"))
+	}
+}
+
+// renderICS renders the given events as an iCalendar feed.
+func renderICS(events []*CalendarEvent, now time.Time) []byte {
+	w := &icsWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Seatbelt//Seatbelt//EN")
+	w.line("CALSCALE:GREGORIAN")
+
+	// Each time zone is described once, for the years its events are in.
+	zones := make(map[string]*timezone)
+	var names []string
+	for _, e := range events {
+		if e.allDay {
+			continue
+		}
+		for _, t := range []time.Time{e.start, e.end} {
+			loc := t.Location()
+			if t.IsZero() || loc == time.UTC || loc == time.Local {
+				continue
+			}
+			tz, ok := zones[loc.String()]
+			if !ok {
+				tz = &timezone{loc: loc, from: t.Year(), to: t.Year()}
+				zones[loc.String()] = tz
+				names = append(names, loc.String())
+			}
+			if t.Year() < tz.from {
+				tz.from = t.Year()
+			}
+			if t.Year() > tz.to {
+				tz.to = t.Year()
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		zones[name].write(w)
+	}
+
+	for _, e := range events {
+		updated := e.updated
+		if updated.IsZero() {
+			updated = now
+		}
+
+		w.line("BEGIN:VEVENT")
+		w.text("UID", e.uid)
+		w.line("DTSTAMP:" + updated.UTC().Format("20060102T150405Z"))
+		w.datetime("DTSTART", e.start, e.allDay)
+		if !e.end.IsZero() {
+			w.datetime("DTEND", e.end, e.allDay)
+		}
+		w.text("SUMMARY", e.summary)
+		w.text("DESCRIPTION", e.description)
+		w.text("LOCATION", e.location)
+		if e.url != "" {
+			w.line("URL:" + e.url)
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return w.Bytes()
+}
+
+// A timezone is a VTIMEZONE component for the given years.
+type timezone struct {
+	loc      *time.Location
+	from, to int
+}
+
+// write writes the time zone as an observance for its offset at the start
+// of the first year, followed by one for each offset change until the end of
+// the last year.
+func (tz *timezone) write(w *icsWriter) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + tz.loc.String())
+
+	t := time.Date(tz.from, time.January, 1, 0, 0, 0, 0, tz.loc)
+	end := time.Date(tz.to+1, time.January, 1, 0, 0, 0, 0, tz.loc)
+	_, offset := t.Zone()
+	observance(w, t, offset)
+
+	for t.Before(end) {
+		next := t.Add(24 * time.Hour)
+		if _, o := next.Zone(); o == offset {
+			t = next
+			continue
+		}
+
+		// Find the second the offset changes at.
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		observance(w, hi, offset)
+		_, offset = hi.Zone()
+		t = hi
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+// observance writes the STANDARD or DAYLIGHT component for the offset that
+// starts at the given time, changing from the given offset.
+func observance(w *icsWriter, t time.Time, from int) {
+	name, to := t.Zone()
+	kind := "STANDARD"
+	if t.IsDST() {
+		kind = "DAYLIGHT"
+	}
+
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + t.In(time.FixedZone("", from)).Format("20060102T150405"))
+	w.line("TZOFFSETFROM:" + formatOffset(from))
+	w.line("TZOFFSETTO:" + formatOffset(to))
+	w.line("TZNAME:" + name)
+	w.line("END:" + kind)
+}
+
+// formatOffset formats the given UTC offset in seconds, i.e., "+0130".
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return string(sign) + time.Date(0, 1, 1, 0, 0, offset, 0, time.UTC).Format("1504")
+}
@@ -0,0 +1,80 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICS(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database is not available: %v", err)
+	}
+	start := time.Date(2026, time.March, 30, 9, 0, 0, 0, berlin)
+	updated := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/calendar.ics", func(c *Context) error {
+		return c.ICS(http.StatusOK, []*CalendarEvent{
+			NewCalendarEvent("standup@example.com", "Standup; daily, short").
+				At(start, start.Add(15*time.Minute)).
+				UpdatedAt(updated),
+			NewCalendarEvent("holiday@example.com", "Easter Monday").
+				AllDay(time.Date(2026, time.April, 6, 0, 0, 0, 0, berlin)).
+				UpdatedAt(updated),
+		})
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, ICSMediaType) {
+		t.Fatalf("expected %s but got %s", ICSMediaType, ct)
+	}
+
+	body := w.Body.String()
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"TZID:Europe/Berlin\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20260329T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20261025T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\n",
+		"DTSTART;TZID=Europe/Berlin:20260330T090000\r\n",
+		"DTEND;TZID=Europe/Berlin:20260330T091500\r\n",
+		`SUMMARY:Standup\; daily\, short` + "\r\n",
+		"DTSTART;VALUE=DATE:20260406\r\nDTEND;VALUE=DATE:20260407\r\n",
+		"DTSTAMP:20260301T120000Z\r\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected the feed to contain %q but got %s", expected, body)
+		}
+	}
+
+	t.Run("respond to a matching ETag with 304", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+		r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		rr := httptest.NewRecorder()
+		app.ServeHTTP(rr, r)
+
+		if rr.Code != http.StatusNotModified {
+			t.Fatalf("expected %d but got %d", http.StatusNotModified, rr.Code)
+		}
+	})
+}
+
+func TestICSLineFolding(t *testing.T) {
+	w := &icsWriter{}
+	w.text("DESCRIPTION", strings.Repeat("ü", 50))
+
+	for _, line := range strings.Split(strings.TrimSuffix(w.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("expected lines of at most 75 octets but got %d", len(line))
+		}
+	}
+	if unfolded := strings.ReplaceAll(w.String(), "\r\n ", ""); unfolded != "DESCRIPTION:"+strings.Repeat("ü", 50)+"\r\n" {
+		t.Fatalf("expected the folded line to unfold to the value but got %s", unfolded)
+	}
+}
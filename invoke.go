@@ -0,0 +1,91 @@
+package seatbelt
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/csrf"
+)
+
+// InvokeOptions configures a request made with App.Invoke.
+type InvokeOptions struct {
+	// Headers to set on the request.
+	Headers map[string]string
+
+	// Cookies to send with the request, for example, the cookies of a
+	// previous Response in order to carry the session over.
+	Cookies []*http.Cookie
+
+	// SkipCSRF disables CSRF validation for the request, so that POST, PUT,
+	// PATCH, and DELETE requests can be made without first fetching a token.
+	SkipCSRF bool
+}
+
+// A Response is the result of a request made with App.Invoke.
+type Response struct {
+	// The HTTP status code of the response.
+	StatusCode int
+
+	// The response headers.
+	Header http.Header
+
+	// The cookies set by the response.
+	Cookies []*http.Cookie
+
+	// The response body.
+	Body []byte
+}
+
+// String returns the response body as a string.
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// JSON decodes the JSON response body into v.
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Invoke runs a request with the given method and path through the full
+// middleware, routing, and render stack of the application in-process,
+// without opening a network connection. The body is optional, and can be nil.
+//
+// Invoke is intended for tests, as it's considerably faster than starting an
+// httptest.Server for each test, i.e.,
+//
+//	resp := app.Invoke("GET", "/users/1", nil)
+//	if resp.StatusCode != 200 {
+//		t.Fatalf("expected 200 but got %d", resp.StatusCode)
+//	}
+func (a *App) Invoke(method, path string, body io.Reader, opts ...InvokeOptions) *Response {
+	var o InvokeOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	r := httptest.NewRequest(method, path, body)
+	for k, v := range o.Headers {
+		r.Header.Set(k, v)
+	}
+	for _, cookie := range o.Cookies {
+		r.AddCookie(cookie)
+	}
+	if o.SkipCSRF {
+		r = csrf.UnsafeSkipCheck(r)
+	}
+
+	rr := httptest.NewRecorder()
+	a.ServeHTTP(rr, r)
+
+	result := rr.Result()
+	defer result.Body.Close()
+
+	return &Response{
+		StatusCode: result.StatusCode,
+		Header:     result.Header,
+		Cookies:    result.Cookies(),
+		Body:       rr.Body.Bytes(),
+	}
+}
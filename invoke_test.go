@@ -0,0 +1,61 @@
+package seatbelt
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestInvoke(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Get("/", func(c *Context) error {
+		return c.String(200, "home")
+	})
+	app.Post("/session", func(c *Context) error {
+		c.Session.Set("name", c.FormValue("name"))
+		return c.NoContent()
+	})
+	app.Get("/session", func(c *Context) error {
+		return c.JSON(200, map[string]interface{}{"name": c.Session.Get("name")})
+	})
+
+	t.Run("GET /", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/", nil)
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected 200 but got %d", resp.StatusCode)
+		}
+		if resp.String() != "home" {
+			t.Fatalf("expected home but got %s", resp.String())
+		}
+	})
+
+	t.Run("POST without a CSRF token is rejected", func(t *testing.T) {
+		resp := app.Invoke(http.MethodPost, "/session", nil)
+
+		if resp.StatusCode != 403 {
+			t.Fatalf("expected 403 but got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("the session carries over between requests", func(t *testing.T) {
+		resp := app.Invoke(http.MethodPost, "/session", strings.NewReader("name=seatbelt"), InvokeOptions{
+			Headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			SkipCSRF: true,
+		})
+		if resp.StatusCode != 204 {
+			t.Fatalf("expected 204 but got %d", resp.StatusCode)
+		}
+
+		resp = app.Invoke(http.MethodGet, "/session", nil, InvokeOptions{Cookies: resp.Cookies})
+
+		var v struct{ Name string }
+		if err := resp.JSON(&v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Name != "seatbelt" {
+			t.Fatalf("expected seatbelt but got %s", v.Name)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Listener configures one of the addresses an application is served on with
+// StartListeners.
+type Listener struct {
+	// Addr is the TCP address to listen on, i.e., ":443".
+	Addr string
+
+	// CertFile and KeyFile serve the listener over HTTPS when both are set.
+	CertFile string
+	KeyFile  string
+
+	// RedirectHTTPS redirects every request to the same URL over HTTPS,
+	// i.e., for a plaintext listener on port 80. No routes are served.
+	RedirectHTTPS bool
+
+	// Namespaces restricts the listener to requests whose path is within
+	// one of the given path prefixes, i.e., "/admin" for a private port.
+	// Default is to serve every route.
+	Namespaces []string
+
+	// ProxyProtocol reads the PROXY protocol header sent by the load
+	// balancer in front of the listener. See ProxyProtocolListener.
+	ProxyProtocol bool
+
+	// Exclude responds with 404 Not Found to requests whose path is within
+	// one of the given path prefixes, i.e., to hide "/admin" from the
+	// public port.
+	Exclude []string
+}
+
+// servers tracks the servers started with StartListeners, so that they can
+// be shut down with Shutdown.
+type servers struct {
+	mu   sync.Mutex
+	list []*http.Server
+}
+
+func (s *servers) add(srv *http.Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, srv)
+}
+
+// shutdown gracefully shuts down all tracked servers, returning the first
+// error.
+func (s *servers) shutdown(ctx stdcontext.Context) error {
+	s.mu.Lock()
+	list := s.list
+	s.list = nil
+	s.mu.Unlock()
+
+	var first error
+	for _, srv := range list {
+		if err := srv.Shutdown(ctx); err != nil && first == nil {
+			first = fmt.Errorf("seatbelt: failed to shut down listener on %s: %w", srv.Addr, err)
+		}
+	}
+	return first
+}
+
+// StartListeners is like Start, but serves the application on each of the
+// given listeners at once, i.e.,
+//
+//	app.StartListeners(
+//		seatbelt.Listener{Addr: ":443", CertFile: "cert.pem", KeyFile: "key.pem", Exclude: []string{"/admin"}},
+//		seatbelt.Listener{Addr: ":80", RedirectHTTPS: true},
+//		seatbelt.Listener{Addr: "127.0.0.1:9000", Namespaces: []string{"/admin"}},
+//	)
+//
+// It blocks until a listener fails, at which point the remaining listeners
+// are closed and the error is returned. When the listeners are shut down with
+// Shutdown, it returns nil.
+//
+// As with Start, production applications should create their own
+// *http.Server for each listener, using the handler returned by
+// ListenerHandler.
+func (a *App) StartListeners(listeners ...Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("seatbelt: no listeners given to StartListeners")
+	}
+
+	errs := make(chan error, len(listeners))
+	started := make([]*http.Server, 0, len(listeners))
+	for _, l := range listeners {
+		srv := &http.Server{Addr: l.Addr, Handler: a.ListenerHandler(l)}
+		a.servers.add(srv)
+		started = append(started, srv)
+
+		go func(l Listener) {
+			errs <- serveListener(srv, l)
+		}(l)
+	}
+
+	var first error
+	for range listeners {
+		err := <-errs
+		if errors.Is(err, http.ErrServerClosed) {
+			continue
+		}
+		if first == nil {
+			first = err
+			for _, srv := range started {
+				srv.Close()
+			}
+		}
+	}
+	return first
+}
+
+// serveListener serves the given server on the address of the given
+// listener, over HTTPS if it has a certificate.
+func serveListener(srv *http.Server, l Listener) error {
+	tls := l.CertFile != "" && l.KeyFile != ""
+
+	addr := l.Addr
+	if addr == "" {
+		addr = ":http"
+		if tls {
+			addr = ":https"
+		}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if l.ProxyProtocol {
+		ln = ProxyProtocolListener(ln)
+	}
+
+	if tls {
+		log.Printf("seatbelt: starting %s on %s (https)", BuildInfo(), addr)
+		return srv.ServeTLS(ln, l.CertFile, l.KeyFile)
+	}
+	log.Printf("seatbelt: starting %s on %s", BuildInfo(), addr)
+	return srv.Serve(ln)
+}
+
+// ListenerHandler returns the handler that serves the application on the
+// given listener, applying its redirect and route filtering.
+func (a *App) ListenerHandler(l Listener) http.Handler {
+	if l.RedirectHTTPS {
+		return http.HandlerFunc(redirectHTTPS)
+	}
+	if len(l.Namespaces) == 0 && len(l.Exclude) == 0 {
+		return a
+	}
+
+	// Paths are filtered as they are routed, so that a case-insensitive
+	// application doesn't serve "/ADMIN" on a listener that excludes
+	// "/admin". Trailing slashes are already within their prefix.
+	namespaces, exclude := l.Namespaces, l.Exclude
+	if a.normalization.caseInsensitive {
+		namespaces, exclude = lowerAll(namespaces), lowerAll(exclude)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if a.normalization.caseInsensitive {
+			path = strings.ToLower(path)
+		}
+
+		if len(namespaces) > 0 && !withinAny(path, namespaces) {
+			http.NotFound(w, r)
+			return
+		}
+		if withinAny(path, exclude) {
+			http.NotFound(w, r)
+			return
+		}
+		a.ServeHTTP(w, r)
+	})
+}
+
+// redirectHTTPS permanently redirects the request to the same host and path
+// over HTTPS on the default port.
+func redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	u := *r.URL
+	u.Scheme = "https"
+	u.Host = hostname(r.Host)
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
+
+// withinAny returns true if the given path is equal to, or below, any of the
+// given path prefixes.
+func withinAny(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// lowerAll returns the given strings in lower case.
+func lowerAll(list []string) []string {
+	lower := make([]string, len(list))
+	for i, s := range list {
+		lower[i] = strings.ToLower(s)
+	}
+	return lower
+}
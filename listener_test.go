@@ -0,0 +1,113 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListenerHandler(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, "home")
+	})
+	app.Namespace("/admin", func(app *App) {
+		app.Get("/health", func(c *Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+	})
+
+	public := app.ListenerHandler(Listener{Exclude: []string{"/admin"}})
+	private := app.ListenerHandler(Listener{Namespaces: []string{"/admin"}})
+	redirect := app.ListenerHandler(Listener{RedirectHTTPS: true})
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		target   string
+		code     int
+		location string
+	}{
+		{"public serves routes", public, "/", http.StatusOK, ""},
+		{"public hides excluded namespaces", public, "/admin/health", http.StatusNotFound, ""},
+		{"private serves its namespaces", private, "/admin/health", http.StatusOK, ""},
+		{"private hides other routes", private, "/", http.StatusNotFound, ""},
+		{"redirect to https", redirect, "http://example.com:80/admin/health?a=1", http.StatusMovedPermanently, "https://example.com/admin/health?a=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.code {
+				t.Fatalf("expected %d but got %d", tt.code, w.Code)
+			}
+			if v := w.Header().Get("Location"); v != tt.location {
+				t.Fatalf("expected Location %s but got %s", tt.location, v)
+			}
+		})
+	}
+}
+
+func TestListenerHandlerNormalizedPaths(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles:       true,
+		CaseInsensitivePaths: true,
+		MatchTrailingSlash:   true,
+	})
+	app.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, "home")
+	})
+	app.Namespace("/admin", func(app *App) {
+		app.Get("/health", func(c *Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+	})
+
+	public := app.ListenerHandler(Listener{Exclude: []string{"/admin"}})
+	private := app.ListenerHandler(Listener{Namespaces: []string{"/Admin"}})
+
+	for _, target := range []string{"/admin/health", "/ADMIN/health", "/Admin/Health/", "/admin/health/"} {
+		w := httptest.NewRecorder()
+		public.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %s to be excluded but got %d %s", target, w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		private.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %s to be served but got %d", target, w.Code)
+		}
+	}
+}
+
+func TestStartListeners(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	done := make(chan error)
+	go func() {
+		done <- app.StartListeners(Listener{Addr: "127.0.0.1:0"}, Listener{Addr: "127.0.0.1:0"})
+	}()
+
+	// Wait for both servers to be tracked before shutting down.
+	for {
+		app.servers.mu.Lock()
+		n := len(app.servers.list)
+		app.servers.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := app.Shutdown(stdcontext.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error after shutdown but got %v", err)
+	}
+}
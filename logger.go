@@ -0,0 +1,156 @@
+package seatbelt
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// RequestLoggerOptions configure the middleware returned by RequestLogger.
+type RequestLoggerOptions struct {
+	// JSON writes each request as a single line JSON object, i.e., for a
+	// log aggregator. Default is false, meaning requests are logged as text
+	// with the standard logger.
+	JSON bool
+
+	// Output is the writer that each request is written to as a line.
+	// It must be safe for concurrent use. Default is the standard logger,
+	// or its output for JSON.
+	Output io.Writer
+
+	// Format formats each request as a line of text, overriding the default
+	// text format. It is ignored when JSON is true.
+	Format func(e RequestLogEntry) string
+
+	// SkipPaths skips logging requests whose path is within one of the
+	// given path prefixes, i.e., "/public" or "/health".
+	SkipPaths []string
+}
+
+// A RequestLogEntry describes a request that was served.
+type RequestLogEntry struct {
+	// The time the request was received.
+	Time time.Time `json:"time"`
+
+	// The HTTP method and path of the request.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+
+	// The route pattern that matched the request, i.e., "/users/{id}", or
+	// an empty string if no route matched.
+	Route string `json:"route,omitempty"`
+
+	// The status code and the number of body bytes of the response.
+	Status int   `json:"status"`
+	Bytes  int64 `json:"bytes"`
+
+	// How long the request took, in nanoseconds in JSON.
+	Duration time.Duration `json:"duration"`
+
+	// The address and user agent of the client.
+	RemoteAddr string `json:"remote_addr"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// String returns a single line description of the request, i.e.,
+//
+//	GET /users/1 (/users/{id}) 200 512B in 1.2ms from 10.0.0.1:5123 "curl/8.0"
+func (e RequestLogEntry) String() string {
+	s := e.Method + " " + e.Path
+	if e.Route != "" && e.Route != e.Path {
+		s += " (" + e.Route + ")"
+	}
+	s += " " + strconv.Itoa(e.Status) + " " + strconv.FormatInt(e.Bytes, 10) + "B in " + e.Duration.String()
+	s += " from " + e.RemoteAddr
+	if e.UserAgent != "" {
+		s += " " + strconv.Quote(e.UserAgent)
+	}
+	return s
+}
+
+// RequestLogger returns middleware that logs each request after it has been
+// served, with its status, duration, size, and route pattern, i.e.,
+//
+//	app.UseStd(app.RequestLogger(seatbelt.RequestLoggerOptions{
+//		JSON:      true,
+//		SkipPaths: []string{"/public"},
+//	}))
+//
+// As with all standard middleware, it must be registered before any routes.
+// Only the path of the request is logged, so sensitive query params are
+// never written to the log, and the path is redacted with the app's
+// redaction patterns, see Option.Redact.
+func (a *App) RequestLogger(opts ...RequestLoggerOptions) func(http.Handler) http.Handler {
+	var o RequestLoggerOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if withinAny(r.URL.Path, o.SkipPaths) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			e := RequestLogEntry{
+				Time:       start,
+				Method:     r.Method,
+				Path:       a.Redact(r.URL.Path),
+				Status:     rw.status,
+				Bytes:      rw.written,
+				Duration:   time.Since(start),
+				RemoteAddr: r.RemoteAddr,
+				UserAgent:  r.UserAgent(),
+			}
+			if e.Status == 0 {
+				// Nothing was written, so the server responds with 200.
+				e.Status = http.StatusOK
+			}
+			// chi fills in the route pattern of the shared route context
+			// while routing, so it's only known once the request is served.
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				e.Route = rctx.RoutePattern()
+			}
+			o.log(e)
+		})
+	}
+}
+
+// log writes the given entry in the configured format.
+func (o RequestLoggerOptions) log(e RequestLogEntry) {
+	var line string
+	switch {
+	case o.JSON:
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("seatbelt: failed to encode request log entry: %v", err)
+			return
+		}
+		line = string(b)
+	case o.Format != nil:
+		line = o.Format(e)
+	default:
+		line = e.String()
+	}
+
+	switch {
+	case o.Output != nil:
+		io.WriteString(o.Output, line+"\n")
+	case o.JSON:
+		// JSON lines are written without the standard logger's prefix,
+		// so that each line can be parsed.
+		io.WriteString(log.Writer(), line+"\n")
+	default:
+		log.Print(line)
+	}
+}
@@ -0,0 +1,95 @@
+package seatbelt
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestLogger(t *testing.T) {
+	newApp := func(o RequestLoggerOptions) *App {
+		app := New(Option{SkipServeFiles: true})
+		app.UseStd(app.RequestLogger(o))
+		app.Get("/users/{id}", func(c *Context) error {
+			return c.String(http.StatusOK, "user "+c.PathParam("id"))
+		})
+		app.Get("/health", func(c *Context) error {
+			return c.NoContent()
+		})
+		app.Get("/cards/{number}", func(c *Context) error {
+			return c.NoContent()
+		})
+		return app
+	}
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		app := newApp(RequestLoggerOptions{Output: &buf})
+		app.Invoke(http.MethodGet, "/users/1", nil, InvokeOptions{
+			Headers: map[string]string{"User-Agent": "curl/8.0"},
+		})
+
+		line := buf.String()
+		for _, expected := range []string{"GET /users/1 (/users/{id}) 200 6B in ", `"curl/8.0"`} {
+			if !strings.Contains(line, expected) {
+				t.Fatalf("expected %q to contain %q", line, expected)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		app := newApp(RequestLoggerOptions{JSON: true, Output: &buf})
+		app.Invoke(http.MethodGet, "/users/1", nil)
+		app.Invoke(http.MethodGet, "/missing", nil)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected %d lines but got %d", 2, len(lines))
+		}
+
+		var e RequestLogEntry
+		if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Method != http.MethodGet || e.Path != "/users/1" || e.Route != "/users/{id}" || e.Status != http.StatusOK || e.Bytes != 6 {
+			t.Fatalf("expected the request to /users/1 but got %+v", e)
+		}
+
+		if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Status != http.StatusNotFound {
+			t.Fatalf("expected %d but got %d", http.StatusNotFound, e.Status)
+		}
+	})
+
+	t.Run("custom format and skipped paths", func(t *testing.T) {
+		var buf bytes.Buffer
+		app := newApp(RequestLoggerOptions{
+			Output:    &buf,
+			SkipPaths: []string{"/health"},
+			Format: func(e RequestLogEntry) string {
+				return e.Route + " " + http.StatusText(e.Status)
+			},
+		})
+		app.Invoke(http.MethodGet, "/health", nil)
+		app.Invoke(http.MethodGet, "/users/2", nil)
+
+		if expected := "/users/{id} OK\n"; buf.String() != expected {
+			t.Fatalf("expected %q but got %q", expected, buf.String())
+		}
+	})
+	t.Run("redacted paths", func(t *testing.T) {
+		var buf bytes.Buffer
+		app := newApp(RequestLoggerOptions{Output: &buf})
+		app.Invoke(http.MethodGet, "/cards/4242424242424242", nil)
+
+		line := buf.String()
+		if strings.Contains(line, "4242424242424242") || !strings.Contains(line, "GET /cards/"+RedactedValue+" (/cards/{number})") {
+			t.Fatalf("expected the card number to be redacted but got %q", line)
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// defaultMetricsBuckets are the upper bounds of the latency histograms, in
+// seconds, and match the defaults of the Prometheus client libraries.
+var defaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsOptions configure the metrics of an application, which are served in
+// the Prometheus text format.
+type MetricsOptions struct {
+	// Path is the path the metrics are served at. The metrics aren't meant
+	// for the public, so exclude the path from public listeners, see
+	// Listener.Exclude. Default is "/metrics".
+	Path string
+
+	// Buckets are the upper bounds of the latency histograms, in seconds.
+	// Default is the Prometheus client default, from 5ms to 10s.
+	Buckets []float64
+}
+
+// A histogram counts observations in cumulative buckets.
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+// observe records the given value in each bucket whose bound it is within.
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]int64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// requestLabels are the labels of the request metrics. Requests are labeled
+// by their route pattern instead of their path, so that the number of series
+// is bounded by the number of routes.
+type requestLabels struct {
+	method string
+	route  string
+	status string
+}
+
+// metrics collects the request, render, and session timings of an
+// application, shared between all namespaces.
+type metrics struct {
+	buckets  []float64
+	inFlight func() int
+
+	mu        sync.Mutex
+	requests  map[requestLabels]int64
+	durations map[requestLabels]*histogram
+	renders   map[string]*histogram
+	sessions  histogram
+}
+
+// newMetrics returns the metrics configured by the given options, or nil if
+// metrics are disabled.
+func newMetrics(opts *MetricsOptions, inFlight func() int) *metrics {
+	if opts == nil {
+		return nil
+	}
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultMetricsBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &metrics{
+		buckets:   buckets,
+		inFlight:  inFlight,
+		requests:  make(map[requestLabels]int64),
+		durations: make(map[requestLabels]*histogram),
+		renders:   make(map[string]*histogram),
+	}
+}
+
+// metricsCtxKeyType is the context key of requests whose metrics are being
+// recorded.
+type metricsCtxKeyType struct{}
+
+// middleware records the count and latency of every request, including those
+// that don't match a route.
+func (m *metrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// chi wraps the not found handler in the middleware too, so
+		// requests that don't match a route pass through twice.
+		if r.Context().Value(metricsCtxKeyType{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(stdcontext.WithValue(r.Context(), metricsCtxKeyType{}, true))
+
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		l := requestLabels{method: metricsMethod(r.Method), status: strconv.Itoa(rw.status)}
+		if rw.status == 0 {
+			l.status = "200"
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			l.route = rctx.RoutePattern()
+		}
+		if l.route == "" {
+			// Paths that don't match a route are unbounded.
+			l.route = "unmatched"
+		}
+		m.observeRequest(l, time.Since(start))
+	})
+}
+
+// metricsMethod returns the label of the given HTTP method. Methods that no
+// route can match are labeled "OTHER", as clients can send any token.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+func (m *metrics) observeRequest(l requestLabels, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[l]++
+	h, ok := m.durations[l]
+	if !ok {
+		h = &histogram{}
+		m.durations[l] = h
+	}
+	h.observe(m.buckets, d.Seconds())
+}
+
+func (m *metrics) observeRender(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.renders[name]
+	if !ok {
+		h = &histogram{}
+		m.renders[name] = h
+	}
+	h.observe(m.buckets, d.Seconds())
+}
+
+// observeSession records the duration of saving a session started at the
+// given time.
+func (m *metrics) observeSession(start time.Time) {
+	d := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions.observe(m.buckets, d.Seconds())
+}
+
+// write writes the metrics to w in the Prometheus text format.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	fmt.Fprintln(w, "# HELP seatbelt_http_requests_total The number of HTTP requests served.")
+	fmt.Fprintln(w, "# TYPE seatbelt_http_requests_total counter")
+	for _, l := range labels {
+		fmt.Fprintf(w, "seatbelt_http_requests_total{%s} %d\n", l, m.requests[l])
+	}
+
+	fmt.Fprintln(w, "# HELP seatbelt_http_request_duration_seconds The latency of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE seatbelt_http_request_duration_seconds histogram")
+	for _, l := range labels {
+		m.writeHistogram(w, "seatbelt_http_request_duration_seconds", l.String(), m.durations[l])
+	}
+
+	fmt.Fprintln(w, "# HELP seatbelt_http_requests_in_flight The number of HTTP requests being served.")
+	fmt.Fprintln(w, "# TYPE seatbelt_http_requests_in_flight gauge")
+	fmt.Fprintf(w, "seatbelt_http_requests_in_flight %d\n", m.inFlight())
+
+	names := make([]string, 0, len(m.renders))
+	for name := range m.renders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP seatbelt_render_duration_seconds The duration of template renders.")
+	fmt.Fprintln(w, "# TYPE seatbelt_render_duration_seconds histogram")
+	for _, name := range names {
+		m.writeHistogram(w, "seatbelt_render_duration_seconds", `template="`+escapeLabel(name)+`"`, m.renders[name])
+	}
+
+	fmt.Fprintln(w, "# HELP seatbelt_session_save_duration_seconds The duration of saving sessions.")
+	fmt.Fprintln(w, "# TYPE seatbelt_session_save_duration_seconds histogram")
+	m.writeHistogram(w, "seatbelt_session_save_duration_seconds", "", &m.sessions)
+}
+
+// writeHistogram writes the buckets, sum, and count of the given histogram.
+func (m *metrics) writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, bound := range m.buckets {
+		var count int64
+		if h.counts != nil {
+			count = h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, strconv.FormatFloat(bound, 'g', -1, 64), count)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+// String returns the labels in the Prometheus text format.
+func (l requestLabels) String() string {
+	return `method="` + escapeLabel(l.method) + `",route="` + escapeLabel(l.route) + `",status="` + l.status + `"`
+}
+
+// escapeLabel escapes the given label value for the Prometheus text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// serve serves the metrics in the Prometheus text format.
+func (m *metrics) serve(c *Context) error {
+	c.w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.w.WriteHeader(http.StatusOK)
+	m.write(c.w)
+	return nil
+}
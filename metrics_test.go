@@ -0,0 +1,61 @@
+package seatbelt
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    "testdata/templates",
+		SkipServeFiles: true,
+		Metrics:        &MetricsOptions{Buckets: []float64{1, 0.1}},
+	})
+	app.Get("/users/{id}", func(c *Context) error {
+		return c.Render("index", nil)
+	})
+	app.Post("/users", func(c *Context) error {
+		return c.String(http.StatusUnprocessableEntity, "Invalid user")
+	}).SkipCSRF()
+
+	app.Invoke(http.MethodGet, "/users/1", nil)
+	app.Invoke(http.MethodGet, "/users/2", nil)
+	app.Invoke(http.MethodPost, "/users", nil)
+	app.Invoke(http.MethodGet, "/no/such/page", nil)
+	app.Invoke("PROPFIND", "/users/1", nil)
+
+	resp := app.Invoke(http.MethodGet, "/metrics", nil)
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Fatalf("expected the Prometheus text format but got %s", contentType)
+	}
+
+	body := string(resp.Body)
+	for _, expected := range []string{
+		`seatbelt_http_requests_total{method="GET",route="/users/{id}",status="200"} 2`,
+		`seatbelt_http_requests_total{method="POST",route="/users",status="422"} 1`,
+		`seatbelt_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`seatbelt_http_requests_total{method="OTHER",route="unmatched",status="403"} 1`,
+		`seatbelt_http_request_duration_seconds_bucket{method="GET",route="/users/{id}",status="200",le="0.1"} 2`,
+		`seatbelt_http_request_duration_seconds_bucket{method="GET",route="/users/{id}",status="200",le="+Inf"} 2`,
+		`seatbelt_http_request_duration_seconds_count{method="GET",route="/users/{id}",status="200"} 2`,
+		`seatbelt_http_requests_in_flight 1`,
+		`seatbelt_render_duration_seconds_count{template="index"} 2`,
+		`seatbelt_session_save_duration_seconds_count 5`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected metrics to contain %s but got\n%s", expected, body)
+		}
+	}
+
+	// Buckets are sorted.
+	if strings.Index(body, `le="0.1"`) > strings.Index(body, `le="1"`) {
+		t.Fatalf("expected buckets to be sorted but got\n%s", body)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if actual, expected := escapeLabel("a\\b\"c\nd"), `a\\b\"c\nd`; actual != expected {
+		t.Fatalf("expected %s but got %s", expected, actual)
+	}
+}
@@ -0,0 +1,99 @@
+package seatbelt
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// pathNormalization configures how request paths that don't match a route
+// are normalized.
+type pathNormalization struct {
+	redirectTrailingSlash bool
+	matchTrailingSlash    bool
+	caseInsensitive       bool
+}
+
+// enabled returns true if any normalization is enabled.
+func (pn pathNormalization) enabled() bool {
+	return pn.redirectTrailingSlash || pn.matchTrailingSlash || pn.caseInsensitive
+}
+
+// candidates returns the alternative paths to try for the given path, in
+// order of preference.
+func (pn pathNormalization) candidates(path string) []string {
+	var paths []string
+	if pn.redirectTrailingSlash || pn.matchTrailingSlash {
+		paths = append(paths, toggleTrailingSlash(path))
+	}
+	if pn.caseInsensitive {
+		lower := strings.ToLower(path)
+		paths = append(paths, lower)
+		if pn.redirectTrailingSlash || pn.matchTrailingSlash {
+			paths = append(paths, toggleTrailingSlash(lower))
+		}
+	}
+	return paths
+}
+
+// toggleTrailingSlash removes the trailing slash of the given path if it has
+// one, and adds one if it doesn't.
+func toggleTrailingSlash(path string) string {
+	if path == "/" {
+		return path
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path + "/"
+}
+
+// normalizePaths is the middleware that routes, or redirects, requests whose
+// path doesn't match any route to the first normalized path that does.
+// Paths that match a route as they are are never changed, so path params
+// keep their case, and FileServer directories keep their trailing slash.
+func (a *App) normalizePaths(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if a.mux.Match(chi.NewRouteContext(), r.Method, path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, candidate := range a.normalization.candidates(path) {
+			if candidate == path || !a.mux.Match(chi.NewRouteContext(), r.Method, candidate) {
+				continue
+			}
+
+			// Only redirect when the trailing slash differs, as matching
+			// case-insensitively should not change the URL.
+			if a.normalization.redirectTrailingSlash && strings.HasSuffix(candidate, "/") != strings.HasSuffix(path, "/") {
+				// Browsers resolve a Location of "//host" or "/\host"
+				// to another host, so such paths are never redirected.
+				if strings.HasPrefix(candidate, "//") || strings.HasPrefix(candidate, "/\\") {
+					break
+				}
+
+				target := candidate
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+
+				code := http.StatusMovedPermanently
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					code = http.StatusPermanentRedirect
+				}
+				http.Redirect(w, r, target, code)
+				return
+			}
+
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				rctx.RoutePath = candidate
+			}
+			break
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package seatbelt
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPathNormalization(t *testing.T) {
+	register := func(app *App) {
+		app.Get("/users", func(c *Context) error {
+			return c.String(200, "users")
+		})
+		app.Get("/users/{id}", func(c *Context) error {
+			return c.String(200, "user "+c.PathParam("id"))
+		})
+		app.Namespace("/admin", func(app *App) {
+			app.Get("/posts/", func(c *Context) error {
+				return c.String(200, "posts")
+			})
+		})
+	}
+
+	redirect := New(Option{SkipServeFiles: true, RedirectTrailingSlash: true})
+	register(redirect)
+
+	match := New(Option{SkipServeFiles: true, MatchTrailingSlash: true, CaseInsensitivePaths: true})
+	register(match)
+
+	strict := New(Option{SkipServeFiles: true})
+	register(strict)
+
+	repos := New(Option{SkipServeFiles: true, RedirectTrailingSlash: true})
+	repos.Get("/{user}/{repo}", func(c *Context) error {
+		return c.String(200, "repo "+c.PathParam("repo"))
+	})
+
+	cases := []struct {
+		name     string
+		app      *App
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{name: "redirect removes the trailing slash", app: redirect, path: "/users/?page=2", status: 301, location: "/users?page=2"},
+		{name: "redirect adds the trailing slash", app: redirect, path: "/admin/posts", status: 301, location: "/admin/posts/"},
+		{name: "redirect leaves matching paths alone", app: redirect, path: "/users/AbC", status: 200, body: "user AbC"},
+		{name: "match routes without redirecting", app: match, path: "/users/", status: 200, body: "users"},
+		{name: "match is case-insensitive", app: match, path: "/USERS/", status: 200, body: "users"},
+		{name: "strict paths are not normalized", app: strict, path: "/users/", status: 404},
+		{name: "redirect never leaves the host", app: repos, path: "//evil.com/", status: 404},
+		{name: "redirect never leaves the host with a backslash", app: repos, path: "/\\evil.com/x/", status: 404},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := c.app.Invoke(http.MethodGet, c.path, nil)
+
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d but got %d", c.status, resp.StatusCode)
+			}
+			if c.body != "" && resp.String() != c.body {
+				t.Fatalf("expected %s but got %s", c.body, resp.String())
+			}
+			if location := resp.Header.Get("Location"); location != c.location {
+				t.Fatalf("expected location %s but got %s", c.location, location)
+			}
+		})
+	}
+}
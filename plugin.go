@@ -0,0 +1,141 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"fmt"
+	"sync"
+
+	"github.com/go-seatbelt/seatbelt/render"
+)
+
+// A Plugin integrates a third-party package, i.e., an APM agent or an admin
+// panel, with every application, without users wiring each hook manually.
+// All hooks are optional.
+type Plugin struct {
+	// Name identifies the plugin in error messages. Required.
+	Name string
+
+	// OnBoot is called with each application created with New, after its
+	// default routes are registered, i.e., to register routes or
+	// middleware. Returning an error makes New panic.
+	OnBoot func(app *App) error
+
+	// OnRequest wraps the handler of every request, outside of all
+	// middleware registered with Use.
+	OnRequest MiddlewareFunc
+
+	// OnRender is called after each template and partial is executed.
+	OnRender func(e render.Event)
+
+	// OnShutdown is called by App.Shutdown.
+	OnShutdown func(ctx stdcontext.Context) error
+}
+
+// plugins holds the plugins registered with RegisterPlugin.
+var plugins struct {
+	mu      sync.RWMutex
+	plugins []Plugin
+}
+
+// RegisterPlugin registers a plugin with all applications that are created
+// with New afterwards. Plugins are usually registered from the init function
+// of the package providing them, i.e.,
+//
+//	func init() {
+//		seatbelt.RegisterPlugin(seatbelt.Plugin{
+//			Name:      "apm",
+//			OnRequest: trace,
+//		})
+//	}
+//
+// RegisterPlugin panics if a plugin with the same name is already
+// registered.
+func RegisterPlugin(p Plugin) {
+	if p.Name == "" {
+		panic("seatbelt: attempting to RegisterPlugin() a plugin without a name")
+	}
+
+	plugins.mu.Lock()
+	defer plugins.mu.Unlock()
+
+	for _, existing := range plugins.plugins {
+		if existing.Name == p.Name {
+			panic(fmt.Sprintf("seatbelt: plugin '%s' is already registered", p.Name))
+		}
+	}
+	plugins.plugins = append(plugins.plugins, p)
+}
+
+// registeredPlugins returns a copy of the registered plugins.
+func registeredPlugins() []Plugin {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+
+	return append([]Plugin(nil), plugins.plugins...)
+}
+
+// withPluginHooks returns render hooks that call the given hooks, and then
+// the OnRender hooks of the given plugins.
+func withPluginHooks(hooks render.Hooks, ps []Plugin) render.Hooks {
+	var onRender []func(e render.Event)
+	for _, p := range ps {
+		if p.OnRender != nil {
+			onRender = append(onRender, p.OnRender)
+		}
+	}
+	if len(onRender) == 0 {
+		return hooks
+	}
+
+	after := hooks.AfterRender
+	hooks.AfterRender = func(e render.Event) {
+		if after != nil {
+			after(e)
+		}
+		for _, fn := range onRender {
+			fn(e)
+		}
+	}
+	return hooks
+}
+
+// pluginMiddleware returns the OnRequest hooks of the application's plugins.
+func (a *App) pluginMiddleware() []MiddlewareFunc {
+	var middleware []MiddlewareFunc
+	for _, p := range a.plugins {
+		if p.OnRequest != nil {
+			middleware = append(middleware, p.OnRequest)
+		}
+	}
+	return middleware
+}
+
+// boot calls the OnBoot hooks of the application's plugins.
+func (a *App) boot() {
+	for _, p := range a.plugins {
+		if p.OnBoot == nil {
+			continue
+		}
+		if err := p.OnBoot(a); err != nil {
+			panic(fmt.Sprintf("seatbelt: plugin '%s' failed to boot: %v", p.Name, err))
+		}
+	}
+}
+
+// Shutdown calls the OnShutdown hooks of the application's plugins, i.e., to
+// flush buffered data. It should be called after the *http.Server serving the
+// application has shut down. Servers started with StartListeners are shut down
+// before the hooks are called. All hooks are called, and the first error is
+// returned.
+func (a *App) Shutdown(ctx stdcontext.Context) error {
+	first := a.servers.shutdown(ctx)
+	for _, p := range a.plugins {
+		if p.OnShutdown == nil {
+			continue
+		}
+		if err := p.OnShutdown(ctx); err != nil && first == nil {
+			first = fmt.Errorf("seatbelt: plugin '%s' failed to shut down: %w", p.Name, err)
+		}
+	}
+	return first
+}
@@ -0,0 +1,70 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-seatbelt/seatbelt/render"
+)
+
+func TestPlugin(t *testing.T) {
+	var calls []string
+	RegisterPlugin(Plugin{
+		Name: "test",
+		OnBoot: func(app *App) error {
+			calls = append(calls, "boot")
+			app.Get("/plugin", func(c *Context) error {
+				return c.Render("index", nil)
+			})
+			return nil
+		},
+		OnRequest: func(next func(c *Context) error) func(c *Context) error {
+			return func(c *Context) error {
+				calls = append(calls, "request")
+				return next(c)
+			}
+		},
+		OnRender: func(e render.Event) {
+			calls = append(calls, "render "+e.Name)
+		},
+		OnShutdown: func(ctx stdcontext.Context) error {
+			calls = append(calls, "shutdown")
+			return nil
+		},
+	})
+	defer func() {
+		plugins.mu.Lock()
+		plugins.plugins = nil
+		plugins.mu.Unlock()
+	}()
+
+	app := New(Option{
+		SkipServeFiles: true,
+		TemplateDir:    filepath.Join("testdata", "templates"),
+	})
+	app.Invoke(http.MethodGet, "/plugin", nil)
+	if err := app.Shutdown(stdcontext.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"boot", "request", "render index", "shutdown"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, calls)
+		}
+	}
+
+	t.Run("plugins can't be registered twice", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected RegisterPlugin to panic")
+			}
+		}()
+		RegisterPlugin(Plugin{Name: "test"})
+	})
+}
@@ -0,0 +1,83 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"sync"
+)
+
+// A PreloadFunc loads data that is available to all templates.
+type PreloadFunc func(ctx stdcontext.Context) (map[string]interface{}, error)
+
+// preloaded holds the data loaded by the application's PreloadFuncs.
+type preloaded struct {
+	mu     sync.RWMutex
+	fns    []PreloadFunc
+	data   map[string]interface{}
+	reload bool
+}
+
+// load runs all PreloadFuncs, and replaces the preloaded data with their
+// merged results. The data is left unchanged if any of them fails.
+func (p *preloaded) load(ctx stdcontext.Context) error {
+	p.mu.RLock()
+	fns := p.fns
+	p.mu.RUnlock()
+
+	data := make(map[string]interface{})
+	for _, fn := range fns {
+		values, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			data[k] = v
+		}
+	}
+
+	p.mu.Lock()
+	p.data = data
+	p.mu.Unlock()
+	return nil
+}
+
+// get returns the preloaded value with the given key. When templates are
+// reloaded on each request, the data is reloaded too.
+func (p *preloaded) get(ctx stdcontext.Context, key string) (interface{}, error) {
+	if p.reload {
+		if err := p.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.data[key], nil
+}
+
+// Preload registers a func that loads rarely-changing data, i.e., navigation
+// trees or settings, that is available to all templates with the
+// "preloaded" func, without querying for it on each request:
+//
+//	app.Preload(func(ctx context.Context) (map[string]interface{}, error) {
+//		categories, err := db.Categories(ctx)
+//		return map[string]interface{}{"categories": categories}, err
+//	})
+//
+//	{{ range preloaded "categories" }}...{{ end }}
+//
+// The func is run immediately, and Preload returns its error. When
+// Option.Reload is true, it is run again on each request. Use
+// RefreshPreloaded to reload the data after it changes.
+func (a *App) Preload(fn PreloadFunc) error {
+	a.preloaded.mu.Lock()
+	a.preloaded.fns = append(a.preloaded.fns, fn)
+	a.preloaded.mu.Unlock()
+
+	return a.preloaded.load(stdcontext.Background())
+}
+
+// RefreshPreloaded runs all funcs registered with Preload again, replacing
+// the preloaded data. If any of them fails, the previous data is kept.
+func (a *App) RefreshPreloaded(ctx stdcontext.Context) error {
+	return a.preloaded.load(ctx)
+}
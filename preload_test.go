@@ -0,0 +1,66 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreload(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles: true,
+		TemplateDir:    filepath.Join("testdata", "templates"),
+	})
+
+	title := "Home"
+	if err := app.Preload(func(ctx stdcontext.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"title": title}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	app.Get("/", func(c *Context) error {
+		return c.Render("preloaded", nil)
+	})
+
+	t.Run("preloaded data is available to templates", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/", nil)
+		if !strings.Contains(resp.String(), "<h1>Home</h1>") {
+			t.Fatalf("expected the preloaded title but got %s", resp.String())
+		}
+	})
+
+	t.Run("preloaded data is only reloaded on refresh", func(t *testing.T) {
+		title = "Welcome"
+
+		resp := app.Invoke(http.MethodGet, "/", nil)
+		if !strings.Contains(resp.String(), "<h1>Home</h1>") {
+			t.Fatalf("expected the previous title but got %s", resp.String())
+		}
+
+		if err := app.RefreshPreloaded(stdcontext.Background()); err != nil {
+			t.Fatal(err)
+		}
+		resp = app.Invoke(http.MethodGet, "/", nil)
+		if !strings.Contains(resp.String(), "<h1>Welcome</h1>") {
+			t.Fatalf("expected the refreshed title but got %s", resp.String())
+		}
+	})
+
+	t.Run("failed refreshes keep the previous data", func(t *testing.T) {
+		err := app.Preload(func(ctx stdcontext.Context) (map[string]interface{}, error) {
+			return nil, errors.New("unavailable")
+		})
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+
+		resp := app.Invoke(http.MethodGet, "/", nil)
+		if !strings.Contains(resp.String(), "<h1>Welcome</h1>") {
+			t.Fatalf("expected the previous title but got %s", resp.String())
+		}
+	})
+}
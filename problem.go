@@ -0,0 +1,133 @@
+package seatbelt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemMediaType is the media type of problem details, as specified by RFC
+// 7807.
+const ProblemMediaType = "application/problem+json"
+
+// A Problem describes an error in the body of an HTTP API response, as
+// specified by RFC 7807.
+type Problem struct {
+	// A URI that identifies the type of the problem. Default is
+	// "about:blank", meaning the problem is described by its status code.
+	Type string
+
+	// A short summary of the type of the problem.
+	Title string
+
+	// The HTTP status code of the response.
+	Status int
+
+	// An explanation of this occurrence of the problem.
+	Detail string
+
+	// A URI that identifies this occurrence of the problem, i.e., the path
+	// of the request.
+	Instance string
+
+	// Additional members of the problem, i.e., "errors" with the invalid
+	// fields of a request.
+	Extensions map[string]interface{}
+}
+
+// MarshalJSON encodes the problem as a single object, with its extension
+// members next to the standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+
+	m["type"] = p.Type
+	if p.Type == "" {
+		m["type"] = "about:blank"
+	}
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// Problem sends problem details, as specified by RFC 7807, with the given
+// status code, title, and detail. The fields are alternating keys and values
+// of additional members, i.e.,
+//
+//	return c.Problem(http.StatusUnprocessableEntity, "Invalid order",
+//		"The order has no items.", "errors", map[string]string{"items": "is empty"})
+//
+// The title defaults to the status text of the code.
+func (c *context) Problem(code int, title, detail string, fields ...interface{}) error {
+	if len(fields)%2 != 0 {
+		return fmt.Errorf("seatbelt: Problem requires key and value pairs, but got %d fields", len(fields))
+	}
+	if title == "" {
+		title = http.StatusText(code)
+	}
+
+	p := Problem{
+		Title:    title,
+		Status:   code,
+		Detail:   detail,
+		Instance: c.r.URL.Path,
+	}
+	if len(fields) > 0 {
+		p.Extensions = make(map[string]interface{}, len(fields)/2)
+		for i := 0; i < len(fields); i += 2 {
+			p.Extensions[fmt.Sprint(fields[i])] = fields[i+1]
+		}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	c.w.Header().Set("Content-Type", ProblemMediaType)
+	c.w.WriteHeader(code)
+	_, err = c.w.Write(b)
+	return err
+}
+
+// acceptsJSON returns true if the request prefers a JSON response, i.e., it
+// was sent by JavaScript with an Accept header of "application/json". JSON
+// must be accepted explicitly, and with at least the quality of HTML, so
+// that a wildcard, or a browser's Accept header, gets the HTML error page.
+func acceptsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			params := strings.Split(mediaRange, ";")
+			q := 1.0
+			for _, param := range params[1:] {
+				if p := strings.TrimSpace(param); strings.HasPrefix(p, "q=") {
+					if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+						q = v
+					}
+				}
+			}
+
+			switch strings.ToLower(strings.TrimSpace(params[0])) {
+			case "application/json", ProblemMediaType:
+				if q > jsonQ {
+					jsonQ = q
+				}
+			case "text/html", "application/xhtml+xml":
+				if q > htmlQ {
+					htmlQ = q
+				}
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ >= htmlQ
+}
@@ -0,0 +1,102 @@
+package seatbelt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblem(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Post("/api/orders", func(c *Context) error {
+		return c.Problem(http.StatusUnprocessableEntity, "Invalid order", "The order has no items.",
+			"errors", map[string]string{"items": "is empty"})
+	})
+	app.Get("/api/orders/{id}", func(c *Context) error {
+		return WrapError(errors.New("sql: no rows"), http.StatusNotFound, "")
+	})
+	app.Get("/api/reports", func(c *Context) error {
+		return errors.New("connection refused")
+	})
+
+	accept := InvokeOptions{Headers: map[string]string{"Accept": "application/json"}, SkipCSRF: true}
+
+	for _, tt := range []struct {
+		method   string
+		path     string
+		expected map[string]interface{}
+	}{
+		{http.MethodPost, "/api/orders", map[string]interface{}{
+			"type":     "about:blank",
+			"title":    "Invalid order",
+			"status":   float64(422),
+			"detail":   "The order has no items.",
+			"instance": "/api/orders",
+			"errors":   map[string]interface{}{"items": "is empty"},
+		}},
+		{http.MethodGet, "/api/orders/1", map[string]interface{}{
+			"type":     "about:blank",
+			"title":    "Not Found",
+			"status":   float64(404),
+			"instance": "/api/orders/1",
+		}},
+		{http.MethodGet, "/api/reports", map[string]interface{}{
+			"type":     "about:blank",
+			"title":    "Internal Server Error",
+			"status":   float64(500),
+			"instance": "/api/reports",
+		}},
+	} {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp := app.Invoke(tt.method, tt.path, nil, accept)
+			if contentType := resp.Header.Get("Content-Type"); contentType != ProblemMediaType {
+				t.Fatalf("expected %s but got %s", ProblemMediaType, contentType)
+			}
+			if resp.StatusCode != int(tt.expected["status"].(float64)) {
+				t.Fatalf("expected %v but got %d", tt.expected["status"], resp.StatusCode)
+			}
+
+			var problem map[string]interface{}
+			if err := resp.JSON(&problem); err != nil {
+				t.Fatal(err)
+			}
+			if len(problem) != len(tt.expected) {
+				t.Fatalf("expected %v but got %v", tt.expected, problem)
+			}
+			for k, v := range tt.expected {
+				if _, ok := v.(map[string]interface{}); ok {
+					continue
+				}
+				if problem[k] != v {
+					t.Fatalf("expected %s to be %v but got %v", k, v, problem[k])
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	for _, tt := range []struct {
+		accept   string
+		expected bool
+	}{
+		{"application/json", true},
+		{ProblemMediaType, true},
+		{"application/json, text/plain, */*", true},
+		{"text/html;q=0.5, application/json", true},
+		{"", false},
+		{"*/*", false},
+		{"application/json;q=0", false},
+		{"text/html, application/json;q=0.1", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+	} {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			if actual := acceptsJSON(r); actual != tt.expected {
+				t.Fatalf("expected %t but got %t", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package seatbelt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trustedProxies are the networks whose forwarded headers are trusted.
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses the given IP addresses and CIDR ranges. It
+// panics if one of them is invalid, as that is a configuration error.
+func parseTrustedProxies(proxies []string) trustedProxies {
+	var tp trustedProxies
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			panic(fmt.Sprintf("seatbelt: invalid trusted proxy '%s': %v", p, err))
+		}
+		tp = append(tp, network)
+	}
+	return tp
+}
+
+// trusts returns true if the given IP address belongs to a trusted proxy.
+func (tp trustedProxies) trusts(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range tp {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHeaders are the request headers set by proxies to describe the
+// original request.
+var forwardedHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+}
+
+// middleware normalizes requests forwarded by a trusted proxy, so that the
+// request's RemoteAddr is the client's IP address, its Host is the host the
+// client requested, and the X-Forwarded-Proto header is the scheme the client
+// used. The forwarded headers of requests from any other address are removed,
+// so that clients can't spoof their address.
+func (tp trustedProxies) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tp.trusts(hostname(r.RemoteAddr)) {
+			for _, h := range forwardedHeaders {
+				r.Header.Del(h)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fwd := parseForwarded(r.Header)
+		for _, h := range forwardedHeaders {
+			r.Header.Del(h)
+		}
+
+		// The client is the last address in the chain that isn't a trusted
+		// proxy, as only the entries appended by trusted proxies can be
+		// relied upon.
+		for i := len(fwd.chain) - 1; i >= 0; i-- {
+			ip := fwd.chain[i]
+			r.RemoteAddr = ip
+			if !tp.trusts(ip) {
+				break
+			}
+		}
+		if fwd.host != "" {
+			r.Host = fwd.host
+		}
+		if fwd.proto != "" {
+			r.Header.Set("X-Forwarded-Proto", fwd.proto)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwarded is the original request described by the forwarded headers.
+type forwarded struct {
+	chain []string
+	host  string
+	proto string
+}
+
+// parseForwarded parses the standard Forwarded header, falling back to the
+// X-Forwarded-* headers, and X-Real-Ip for the client's address.
+func parseForwarded(header http.Header) forwarded {
+	var fwd forwarded
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, elem := range strings.Split(strings.Join(values, ","), ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				v = strings.Trim(v, `"`)
+				switch strings.ToLower(k) {
+				case "for":
+					fwd.chain = append(fwd.chain, forwardedIP(v))
+				case "host":
+					if fwd.host == "" {
+						fwd.host = v
+					}
+				case "proto":
+					if fwd.proto == "" {
+						fwd.proto = strings.ToLower(v)
+					}
+				}
+			}
+		}
+		return fwd
+	}
+
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, ip := range strings.Split(strings.Join(values, ","), ",") {
+			fwd.chain = append(fwd.chain, forwardedIP(strings.TrimSpace(ip)))
+		}
+	} else if ip := header.Get("X-Real-Ip"); ip != "" {
+		fwd.chain = append(fwd.chain, forwardedIP(ip))
+	}
+	if host := header.Get("X-Forwarded-Host"); host != "" {
+		fwd.host = strings.TrimSpace(strings.Split(host, ",")[0])
+	}
+	if proto := header.Get("X-Forwarded-Proto"); proto != "" {
+		fwd.proto = strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	return fwd
+}
+
+// forwardedIP returns the IP address of a forwarded node, i.e., without its
+// port, or the brackets around an IPv6 address.
+func forwardedIP(node string) string {
+	node = hostname(node)
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
+
+// maxProxyHeaderLen is the maximum length of a PROXY protocol version 1
+// header, including the CRLF.
+const maxProxyHeaderLen = 107
+
+// proxyHeaderTimeout is how long a connection has to send its PROXY header.
+var proxyHeaderTimeout = 5 * time.Second
+
+// ProxyProtocolListener wraps the given listener to read the PROXY protocol
+// (version 1) header sent by load balancers such as HAProxy and AWS NLB, so
+// that the RemoteAddr of each connection is the client's address instead of
+// the load balancer's. Connections that don't start with a PROXY header are
+// served as they are, so the listener must only be reachable through the
+// load balancer.
+func ProxyProtocolListener(l net.Listener) net.Listener {
+	return &proxyListener{Listener: l}
+}
+
+type proxyListener struct {
+	net.Listener
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// proxyConn reads the PROXY header on first use, rather than in Accept, so
+// that a slow client doesn't block other connections from being accepted.
+type proxyConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		c.remote = c.Conn.RemoteAddr()
+
+		// The deadline is cleared once the header is read, before the
+		// server sets its own.
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		prefix, err := c.r.Peek(6)
+		if err != nil || string(prefix) != "PROXY " {
+			return
+		}
+		line, err := readProxyHeader(c.r)
+		if err != nil {
+			c.err = err
+			return
+		}
+		addr, err := parseProxyHeader(line)
+		if err != nil {
+			c.err = err
+			return
+		}
+		if addr != nil {
+			c.remote = addr
+		}
+	})
+}
+
+// readProxyHeader reads the PROXY header line, which must not be longer than
+// maxProxyHeaderLen.
+func readProxyHeader(r *bufio.Reader) (string, error) {
+	line := make([]byte, 0, maxProxyHeaderLen)
+	for len(line) < maxProxyHeaderLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			return string(line), nil
+		}
+	}
+	return "", errInvalidProxyHeader
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	return c.remote
+}
+
+// errInvalidProxyHeader is returned when reading from a connection whose
+// PROXY header is malformed.
+var errInvalidProxyHeader = errors.New("seatbelt: invalid PROXY protocol header")
+
+// parseProxyHeader parses a PROXY protocol version 1 header line, i.e.,
+//
+//	PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n
+//
+// It returns a nil address for the UNKNOWN protocol, meaning the address of
+// the connection should be used.
+func parseProxyHeader(line string) (net.Addr, error) {
+	fields := strings.Fields(strings.TrimSuffix(line, "\r\n"))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errInvalidProxyHeader
+	}
+	// The source is parsed rather than resolved, so that a header can't
+	// trigger DNS lookups.
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, errInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
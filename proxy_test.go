@@ -0,0 +1,203 @@
+package seatbelt
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles: true,
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+	app.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, c.GetIP()+" "+c.Request().Host+" "+c.Request().Header.Get("X-Forwarded-Proto"))
+	})
+
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		expect  string
+	}{
+		{
+			"untrusted headers are ignored",
+			"203.0.113.9:1234",
+			map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Forwarded-Proto": "https"},
+			"203.0.113.9:1234 example.com ",
+		},
+		{
+			"x-forwarded headers from a trusted proxy",
+			"10.0.0.2:1234",
+			map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 192.168.1.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "app.example.com"},
+			"203.0.113.7 app.example.com https",
+		},
+		{
+			"forwarded header from a trusted proxy",
+			"10.0.0.2:1234",
+			map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https;host=app.example.com, for=10.0.0.3`},
+			"2001:db8::1 app.example.com https",
+		},
+		{
+			"x-real-ip from a trusted proxy",
+			"192.168.1.1:1234",
+			map[string]string{"X-Real-Ip": "203.0.113.7"},
+			"203.0.113.7 example.com ",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			r.RemoteAddr = c.remote
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if body := w.Body.String(); body != c.expect {
+				t.Fatalf("expected %s but got %s", c.expect, body)
+			}
+		})
+	}
+}
+
+func TestGetIPWithoutTrustedProxies(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, c.GetIP())
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil, InvokeOptions{Headers: map[string]string{
+		"X-Real-Ip":       "1.2.3.4",
+		"X-Forwarded-For": "1.2.3.4",
+	}})
+	if ip := resp.String(); ip != "192.0.2.1:1234" {
+		t.Fatalf("expected the remote address but got %s", ip)
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln = ProxyProtocolListener(ln)
+	defer ln.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\nhello"))
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if addr := conn.RemoteAddr().String(); addr != "203.0.113.7:56324" {
+		t.Fatalf("expected 203.0.113.7:56324 but got %s", addr)
+	}
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("expected hello but got %s", b)
+	}
+}
+
+func TestProxyProtocolListenerInvalidHeader(t *testing.T) {
+	defer func(timeout time.Duration) { proxyHeaderTimeout = timeout }(proxyHeaderTimeout)
+	proxyHeaderTimeout = 50 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln = ProxyProtocolListener(ln)
+	defer ln.Close()
+
+	// read sends the given bytes on a new connection, and returns the
+	// error of reading from the accepted connection.
+	read := func(b []byte) error {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.Write(b)
+			<-done
+		}()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		_, err = conn.Read(make([]byte, 1))
+		return err
+	}
+
+	t.Run("headers longer than the maximum are rejected", func(t *testing.T) {
+		if err := read([]byte("PROXY TCP4 " + strings.Repeat("1", 200))); !errors.Is(err, errInvalidProxyHeader) {
+			t.Fatalf("expected %v but got %v", errInvalidProxyHeader, err)
+		}
+	})
+
+	t.Run("headers that are never finished time out", func(t *testing.T) {
+		var netErr net.Error
+		if err := read([]byte("PROXY TCP4 ")); !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("expected a timeout but got %v", err)
+		}
+	})
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	cases := []struct {
+		line   string
+		expect string
+		err    error
+	}{
+		{line: "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n", expect: "203.0.113.7:56324"},
+		{line: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", expect: "[2001:db8::1]:56324"},
+		{line: "PROXY UNKNOWN\r\n"},
+		{line: "PROXY TCP4 localhost 10.0.0.1 56324 443\r\n", err: errInvalidProxyHeader},
+		{line: "PROXY TCP4 203.0.113.7 10.0.0.1 443\r\n", err: errInvalidProxyHeader},
+		{line: "PROXY TCP4 203.0.113.7 10.0.0.1 65536 443\r\n", err: errInvalidProxyHeader},
+	}
+
+	for _, c := range cases {
+		t.Run(c.line, func(t *testing.T) {
+			addr, err := parseProxyHeader(c.line)
+			if err != c.err {
+				t.Fatalf("expected %v but got %v", c.err, err)
+			}
+			if c.expect == "" {
+				if addr != nil {
+					t.Fatalf("expected no address but got %s", addr)
+				}
+				return
+			}
+			if addr.String() != c.expect {
+				t.Fatalf("expected %s but got %s", c.expect, addr)
+			}
+		})
+	}
+}
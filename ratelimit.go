@@ -0,0 +1,178 @@
+package seatbelt
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A RateLimitStore counts the requests of each key in fixed time windows, so
+// that rate limits can be shared between processes, i.e., with Redis INCR and
+// EXPIRE.
+//
+// RateLimitStore implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Increment counts a request with the given key in the window that
+	// starts at the given time, and returns the number of requests counted
+	// in that window. The count can be deleted once the window has ended,
+	// after the given duration.
+	Increment(key string, window time.Time, d time.Duration) (int, error)
+}
+
+// rateLimitCount is the number of requests of a key in a window.
+type rateLimitCount struct {
+	window time.Time
+	ends   time.Time
+	count  int
+}
+
+// A MemoryRateLimitStore is a RateLimitStore that keeps counts in memory.
+// Counts aren't shared between processes, so each instance of an application
+// allows the full limit.
+type MemoryRateLimitStore struct {
+	mu     sync.Mutex
+	counts map[string]rateLimitCount
+	pruned time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counts: make(map[string]rateLimitCount)}
+}
+
+// Increment implements RateLimitStore.
+func (m *MemoryRateLimitStore) Increment(key string, window time.Time, d time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Counts of windows that have ended are deleted at most once per
+	// window, so that keys that are never seen again don't leak.
+	if window.Sub(m.pruned) >= d {
+		for k, c := range m.counts {
+			if !c.ends.After(window) {
+				delete(m.counts, k)
+			}
+		}
+		m.pruned = window
+	}
+
+	c := m.counts[key]
+	if !c.window.Equal(window) {
+		c = rateLimitCount{window: window, ends: window.Add(d)}
+	}
+	c.count++
+	m.counts[key] = c
+	return c.count, nil
+}
+
+// A RateLimitKeyFunc returns the key that the requests of a client are
+// counted by, i.e., its IP address. Requests with an empty key aren't
+// limited.
+type RateLimitKeyFunc func(c *Context) string
+
+// RateLimitByIP counts requests by the client's IP address. Set
+// Option.TrustedProxies for applications behind a load balancer, so that the
+// IP address is the client's instead of the load balancer's.
+func RateLimitByIP(c *Context) string {
+	return hostname(c.r.RemoteAddr)
+}
+
+// RateLimitBySession counts requests by the session value with the given key,
+// i.e., "user_id", or by the client's IP address if the session doesn't have
+// the value.
+func RateLimitBySession(key string) RateLimitKeyFunc {
+	return func(c *Context) string {
+		if v := c.Session.Get(key); v != nil {
+			return "session:" + fmt.Sprint(v)
+		}
+		return RateLimitByIP(c)
+	}
+}
+
+// RateLimitOptions configure the middleware returned by RateLimit.
+type RateLimitOptions struct {
+	// Name separates the counts of this limit from those of other limits
+	// that share a store. Default is "", which only one limit can use.
+	Name string
+
+	// Store counts the requests. Default is a new MemoryRateLimitStore.
+	Store RateLimitStore
+
+	// OnLimit responds to requests that exceed the limit, after the
+	// Retry-After header has been set, i.e., to render a "slow down" page.
+	// Default is nil, meaning clients that accept JSON are sent problem
+	// details, and others are sent the error page for 429 Too Many
+	// Requests, see ErrorHandler.
+	OnLimit func(c *Context, retryAfter time.Duration) error
+}
+
+// rateLimiter limits the number of requests of each key in a window.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+	key    RateLimitKeyFunc
+	opts   RateLimitOptions
+	clock  func() time.Time
+}
+
+// RateLimit returns middleware that limits each client to the given number of
+// requests in each window of the given duration, i.e., to protect a login
+// form from password guessing:
+//
+//	app.Group(func(app *seatbelt.App) {
+//		app.Use(seatbelt.RateLimit(5, time.Minute, seatbelt.RateLimitByIP))
+//		app.Post("/login", login)
+//	})
+//
+// Requests that exceed the limit are answered with 429 Too Many Requests,
+// and a Retry-After header of the time left in the window. If the store
+// fails, requests are let through, and the error is logged.
+func RateLimit(limit int, window time.Duration, key RateLimitKeyFunc, opts ...RateLimitOptions) MiddlewareFunc {
+	var o RateLimitOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.Store == nil {
+		o.Store = NewMemoryRateLimitStore()
+	}
+
+	rl := &rateLimiter{limit: limit, window: window, key: key, opts: o, clock: now}
+	return rl.middleware
+}
+
+func (rl *rateLimiter) middleware(fn func(c *Context) error) func(*Context) error {
+	return func(c *Context) error {
+		key := rl.key(c)
+		if key == "" {
+			return fn(c)
+		}
+
+		t := rl.clock()
+		window := t.Truncate(rl.window)
+		count, err := rl.opts.Store.Increment(rl.opts.Name+":"+key, window, rl.window)
+		if err != nil {
+			log.Printf("[warning] seatbelt: rate limit store failed: %v", err)
+			return fn(c)
+		}
+		if count <= rl.limit {
+			return fn(c)
+		}
+
+		retryAfter := window.Add(rl.window).Sub(t)
+		seconds := int((retryAfter + time.Second - 1) / time.Second)
+		c.w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+		if rl.opts.OnLimit != nil {
+			return rl.opts.OnLimit(c, retryAfter)
+		}
+		detail := fmt.Sprintf("Too many requests, try again in %d seconds.", seconds)
+		if acceptsJSON(c.r) {
+			return c.Problem(http.StatusTooManyRequests, "", detail)
+		}
+		c.errorPage(c, http.StatusTooManyRequests, detail, nil)
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package seatbelt
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	restore := TestMode(TestModeOptions{Now: time.Date(2000, time.January, 1, 10, 0, 30, 0, time.UTC)})
+	defer restore()
+
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+	app.Group(func(app *App) {
+		app.Use(RateLimit(2, time.Minute, RateLimitByIP))
+		app.Get("/login", func(c *Context) error {
+			return c.String(http.StatusOK, "login")
+		})
+	})
+	app.Group(func(app *App) {
+		app.Use(RateLimit(1, time.Minute, RateLimitByIP, RateLimitOptions{
+			Name: "signup",
+			OnLimit: func(c *Context, retryAfter time.Duration) error {
+				return c.String(http.StatusTooManyRequests, "slow down for "+retryAfter.String())
+			},
+		}))
+		app.Get("/signup", func(c *Context) error {
+			return c.String(http.StatusOK, "signup")
+		})
+	})
+
+	for i := 0; i < 2; i++ {
+		if resp := app.Invoke(http.MethodGet, "/login", nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected request %d to be allowed but got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	resp := app.Invoke(http.MethodGet, "/login", nil)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected %d but got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "30" {
+		t.Fatalf("expected Retry-After 30 but got %s", retryAfter)
+	}
+	if !strings.Contains(resp.String(), `<p class="error">429 Too many requests, try again in 30 seconds.</p>`) {
+		t.Fatalf("expected the error page but got %s", resp.String())
+	}
+
+	resp = app.Invoke(http.MethodGet, "/login", nil, InvokeOptions{Headers: map[string]string{"Accept": "application/json"}})
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected problem details but got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// Limits with different names are counted separately.
+	if resp := app.Invoke(http.MethodGet, "/signup", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first signup to be allowed but got %d", resp.StatusCode)
+	}
+	if resp := app.Invoke(http.MethodGet, "/signup", nil); resp.String() != "slow down for 30s" {
+		t.Fatalf("expected the OnLimit response but got %s", resp.String())
+	}
+
+	// Requests are allowed again in the next window.
+	restoreNext := TestMode(TestModeOptions{Now: time.Date(2000, time.January, 1, 10, 1, 0, 0, time.UTC)})
+	defer restoreNext()
+
+	if resp := app.Invoke(http.MethodGet, "/login", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the next window to be allowed but got %d", resp.StatusCode)
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 3; i++ {
+		if count, _ := store.Increment("a", start, time.Minute); count != i {
+			t.Fatalf("expected count %d but got %d", i, count)
+		}
+	}
+	if count, _ := store.Increment("b", start, time.Minute); count != 1 {
+		t.Fatalf("expected keys to be counted separately but got %d", count)
+	}
+
+	// Counts of ended windows are pruned.
+	if count, _ := store.Increment("a", start.Add(time.Minute), time.Minute); count != 1 {
+		t.Fatalf("expected the next window to start at 1 but got %d", count)
+	}
+	if _, ok := store.counts["b"]; ok {
+		t.Fatal("expected the ended window of b to be pruned")
+	}
+}
@@ -0,0 +1,112 @@
+package seatbelt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces redacted values in logs and error reports.
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactKeys are the substrings of param and session keys whose values
+// are redacted by default.
+var DefaultRedactKeys = []string{"password", "secret", "token", "key", "csrf", "card", "cvv", "cvc", "ssn"}
+
+// DefaultRedactPatterns match the values that are redacted by default,
+// wherever they appear, i.e., card numbers of 13 to 19 digits, optionally
+// separated by spaces or dashes.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+}
+
+// RedactOptions configure the redaction of sensitive data from logs and
+// error reports, i.e., to keep PCI and GDPR sensitive data out of them.
+type RedactOptions struct {
+	// Keys are substrings of param and session keys, matched
+	// case-insensitively, whose values are redacted. Default is
+	// DefaultRedactKeys.
+	Keys []string
+
+	// Patterns match values that are redacted wherever they appear,
+	// including in error messages. Default is DefaultRedactPatterns.
+	Patterns []*regexp.Regexp
+}
+
+// redactor redacts sensitive data.
+type redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(o RedactOptions) *redactor {
+	keys := o.Keys
+	if keys == nil {
+		keys = DefaultRedactKeys
+	}
+	r := &redactor{patterns: o.Patterns}
+	if r.patterns == nil {
+		r.patterns = DefaultRedactPatterns
+	}
+	for _, k := range keys {
+		r.keys = append(r.keys, strings.ToLower(k))
+	}
+	return r
+}
+
+// sensitive returns true if the values of the given key are redacted.
+func (r *redactor) sensitive(key string) bool {
+	lower := strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(lower, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces the values matched by the redaction patterns in s, i.e.,
+// before logging an error message.
+func (a *App) Redact(s string) string {
+	for _, p := range a.redactor.patterns {
+		s = p.ReplaceAllString(s, RedactedValue)
+	}
+	return s
+}
+
+// RedactParams returns a copy of the given params, i.e., the request's form,
+// with the values of sensitive keys, and the values matched by the
+// redaction patterns, redacted.
+func (a *App) RedactParams(params map[string][]string) map[string][]string {
+	redacted := make(map[string][]string, len(params))
+	for k, values := range params {
+		if a.redactor.sensitive(k) {
+			redacted[k] = []string{RedactedValue}
+			continue
+		}
+		vs := make([]string, len(values))
+		for i, v := range values {
+			vs[i] = a.Redact(v)
+		}
+		redacted[k] = vs
+	}
+	return redacted
+}
+
+// RedactValues is the same as RedactParams, but for session data and other
+// maps of arbitrary values. Values of sensitive keys are redacted, and
+// string values are matched against the redaction patterns.
+func (a *App) RedactValues(values map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		switch {
+		case a.redactor.sensitive(k):
+			redacted[k] = RedactedValue
+		default:
+			if s, ok := v.(string); ok {
+				v = a.Redact(s)
+			}
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,69 @@
+package seatbelt
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	t.Run("card numbers are redacted from strings", func(t *testing.T) {
+		s := app.Redact("charge failed for card 4242 4242 4242 4242 at 2024-01-01")
+		if s != "charge failed for card [REDACTED] at 2024-01-01" {
+			t.Fatalf("expected the card number to be redacted but got %s", s)
+		}
+	})
+
+	t.Run("sensitive params are redacted", func(t *testing.T) {
+		params := map[string][]string{
+			"email":            {"ada@example.com"},
+			"Password":         {"hunter2"},
+			"api_token":        {"abc"},
+			"note":             {"card 4111111111111111"},
+			"confirm_password": {"hunter2"},
+		}
+		redacted := app.RedactParams(params)
+
+		expected := map[string]string{
+			"email":            "ada@example.com",
+			"Password":         RedactedValue,
+			"api_token":        RedactedValue,
+			"note":             "card " + RedactedValue,
+			"confirm_password": RedactedValue,
+		}
+		for k, v := range expected {
+			if redacted[k][0] != v {
+				t.Fatalf("expected %s to be %s but got %s", k, v, redacted[k][0])
+			}
+		}
+		if params["Password"][0] != "hunter2" {
+			t.Fatalf("expected the params not to be modified")
+		}
+	})
+
+	t.Run("sensitive session values are redacted", func(t *testing.T) {
+		redacted := app.RedactValues(map[string]interface{}{"user_id": 1, "oauth_token": "abc"})
+		if redacted["user_id"] != 1 || redacted["oauth_token"] != RedactedValue {
+			t.Fatalf("expected only the token to be redacted but got %v", redacted)
+		}
+	})
+
+	t.Run("custom keys and patterns", func(t *testing.T) {
+		app := New(Option{
+			SkipServeFiles: true,
+			Redact: RedactOptions{
+				Keys:     []string{"dob"},
+				Patterns: []*regexp.Regexp{regexp.MustCompile(`\d{3}-\d{2}-\d{4}`)},
+			},
+		})
+
+		redacted := app.RedactParams(map[string][]string{"dob": {"1990-01-01"}, "password": {"hunter2"}})
+		if redacted["dob"][0] != RedactedValue || redacted["password"][0] != "hunter2" {
+			t.Fatalf("expected only the custom keys to be redacted but got %v", redacted)
+		}
+		if s := app.Redact("ssn 123-45-6789"); s != "ssn "+RedactedValue {
+			t.Fatalf("expected the custom pattern to be redacted but got %s", s)
+		}
+	})
+}
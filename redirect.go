@@ -0,0 +1,84 @@
+package seatbelt
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// canonicalRedirects configures the redirects to HTTPS and the canonical host
+// applied before routing.
+type canonicalRedirects struct {
+	forceHTTPS    bool
+	canonicalHost string
+	exempt        []string
+}
+
+// enabled returns true if any redirect is enabled.
+func (cr canonicalRedirects) enabled() bool {
+	return cr.forceHTTPS || cr.canonicalHost != ""
+}
+
+// middleware redirects requests over plain HTTP to HTTPS, and requests for
+// any host other than the canonical host to the canonical host, in a single
+// redirect. Requests to exempt paths are never redirected.
+func (cr canonicalRedirects) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if withinAny(r.URL.Path, cr.exempt) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme := requestScheme(r)
+		host := r.Host
+
+		redirect := false
+		if cr.forceHTTPS && scheme != "https" {
+			scheme = "https"
+			// The port of the plaintext listener doesn't apply to HTTPS.
+			host = hostname(host)
+			redirect = true
+		}
+		if cr.canonicalHost != "" && !strings.EqualFold(hostname(host), hostname(cr.canonicalHost)) {
+			host = cr.canonicalHost
+			redirect = true
+		}
+		if !redirect {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := *r.URL
+		u.Scheme = scheme
+		u.Host = host
+
+		// Preserve the method and body of unsafe requests.
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, u.String(), code)
+	})
+}
+
+// requestScheme returns the scheme the client used to make the request,
+// honoring the X-Forwarded-Proto header set by a TLS-terminating proxy.
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		// Proxies may append their own protocol, so the first value is the
+		// one used by the client.
+		return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// hostname returns the given host without its port.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
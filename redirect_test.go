@@ -0,0 +1,56 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalRedirects(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles:      true,
+		ForceHTTPS:          true,
+		CanonicalHost:       "example.com",
+		RedirectExemptPaths: []string{"/healthz"},
+	})
+	app.Get("/", func(c *Context) error {
+		return c.NoContent()
+	})
+	app.Get("/healthz", func(c *Context) error {
+		return c.NoContent()
+	})
+
+	cases := []struct {
+		name     string
+		method   string
+		target   string
+		proto    string
+		code     int
+		location string
+	}{
+		{"http to https", http.MethodGet, "http://example.com/?q=1", "", http.StatusMovedPermanently, "https://example.com/?q=1"},
+		{"www to apex", http.MethodGet, "http://www.example.com/", "https", http.StatusMovedPermanently, "https://example.com/"},
+		{"http and www in one redirect", http.MethodGet, "http://www.example.com:8080/", "", http.StatusMovedPermanently, "https://example.com/"},
+		{"unsafe methods keep their method", http.MethodPost, "http://example.com/", "", http.StatusPermanentRedirect, "https://example.com/"},
+		{"forwarded https is served", http.MethodGet, "http://example.com/", "https", http.StatusNoContent, ""},
+		{"health checks are exempt", http.MethodGet, "http://10.0.0.1/healthz", "", http.StatusNoContent, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.target, nil)
+			if c.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", c.proto)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != c.code {
+				t.Fatalf("expected %d but got %d", c.code, w.Code)
+			}
+			if v := w.Header().Get("Location"); v != c.location {
+				t.Fatalf("expected Location %s but got %s", c.location, v)
+			}
+		})
+	}
+}
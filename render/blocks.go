@@ -0,0 +1,103 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/unrolled/render"
+)
+
+var (
+	// contentForRe matches the opening tag of a content block, i.e.,
+	//
+	// 	{{ contentFor "sidebar" }}
+	contentForRe = regexp.MustCompile(`\{\{(-?)\s*contentFor\s+"([^"]+)"\s*(-?)\}\}`)
+
+	// extendsRe matches the directive that wraps a layout in a parent
+	// layout, i.e.,
+	//
+	// 	{{ extends "application" }}
+	extendsRe = regexp.MustCompile(`\{\{-?\s*extends\s+"([^"]+)"\s*-?\}\}\n?`)
+)
+
+// preprocess rewrites the content blocks of the template with the given name
+// into the partials that the "yieldBlock" template func renders, so that
+//
+//	{{ contentFor "sidebar" }}...{{ end }}
+//
+// in "users/index" becomes
+//
+//	{{ define "sidebar-users/index" }}...{{ end }}
+//
+// Cache blocks are moved into templates of their own, see hoistCacheBlocks.
+// It also removes the "extends" directive from the template, and returns the
+// name of the parent layout it declares, if any.
+func preprocess(name string, buf []byte) ([]byte, string) {
+	buf = contentForRe.ReplaceAllFunc(buf, func(tag []byte) []byte {
+		m := contentForRe.FindSubmatch(tag)
+		return []byte(fmt.Sprintf(`{{%s define "%s-%s" %s}}`, m[1], m[2], name, m[3]))
+	})
+
+	buf = hoistCacheBlocks(name, buf)
+
+	var parent string
+	if m := extendsRe.FindSubmatch(buf); m != nil {
+		parent = string(m[1])
+		buf = extendsRe.ReplaceAll(buf, nil)
+	}
+	return buf, parent
+}
+
+// parentLayouts returns the chain of layouts that the given layout is nested
+// in, from the innermost to the outermost.
+func (r *Render) parentLayouts(layout string) ([]string, error) {
+	var parents []string
+	for {
+		parent := r.fs.parent(layout)
+		if parent == "" {
+			return parents, nil
+		}
+		parent = r.resolveLayout(parent)
+		if len(parents) >= maxPartialDepth {
+			return nil, fmt.Errorf("seatbelt/render: layouts are nested more than %d levels deep: %s -> %s", maxPartialDepth, layout, strings.Join(parents, " -> "))
+		}
+		parents = append(parents, parent)
+		layout = parent
+	}
+}
+
+// htmlInNestedLayouts renders the HTML template with the given name in its
+// layout, and then renders the result as the "yield" of each of the
+// layout's parents in turn.
+func (r *Render) htmlInNestedLayouts(w io.Writer, status int, name string, parents []string, data interface{}, opts render.HTMLOptions) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := r.re.HTML(buf, status, name, data, opts); err != nil {
+		return err
+	}
+
+	for _, parent := range parents {
+		tpl := r.re.TemplateLookup(parent)
+		if tpl == nil {
+			return fmt.Errorf("html/template: %q is undefined", parent)
+		}
+
+		content := template.HTML(buf.String())
+		funcs := template.FuncMap{}
+		for k, v := range opts.Funcs {
+			funcs[k] = v
+		}
+		funcs["yield"] = func() template.HTML { return content }
+		funcs["current"] = func() string { return name }
+		tpl.Funcs(funcs)
+
+		buf.Reset()
+		if err := tpl.Execute(buf, data); err != nil {
+			return err
+		}
+	}
+	return writeHTML(w, status, buf)
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity above which buffers aren't returned to the
+// pool, so that a single large page doesn't keep its memory alive.
+const maxPooledBuffer = 256 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return &bytes.Buffer{} },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns the given buffer to the pool, unless it has grown too
+// large.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// pool implements the unrolled/render GenericBufferPool with the buffer
+// pool, instead of its default pool, which allocates 512KB for each buffer.
+type pool struct{}
+
+func (pool) Get() *bytes.Buffer    { return getBuffer() }
+func (pool) Put(buf *bytes.Buffer) { putBuffer(buf) }
+
+// bufferedResponse buffers a rendered page, so that its Content-Length can
+// be set before it is written.
+type bufferedResponse struct {
+	http.ResponseWriter
+	buf  *bytes.Buffer
+	code int
+}
+
+func newBufferedResponse(w http.ResponseWriter) *bufferedResponse {
+	return &bufferedResponse{ResponseWriter: w, buf: getBuffer()}
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+// flush writes the buffered page to the underlying response. Pages up to
+// streamThreshold bytes are sent with a Content-Length, and larger pages are
+// streamed with chunked encoding. A streamThreshold of 0 means pages are
+// always sent with a Content-Length.
+func (b *bufferedResponse) flush(streamThreshold int) error {
+	defer b.release()
+
+	// Nothing was rendered.
+	if b.code == 0 && b.buf.Len() == 0 {
+		return nil
+	}
+
+	if streamThreshold == 0 || b.buf.Len() <= streamThreshold {
+		b.Header().Set("Content-Length", strconv.Itoa(b.buf.Len()))
+	} else {
+		b.Header().Del("Content-Length")
+	}
+	if b.code != 0 {
+		b.ResponseWriter.WriteHeader(b.code)
+	}
+	_, err := b.buf.WriteTo(b.ResponseWriter)
+	return err
+}
+
+// notModified sets a strong ETag computed from the buffered page, and if it
+// matches the If-None-Match header of the request, responds with 304 Not
+// Modified instead of the page. It returns true if it did.
+func (b *bufferedResponse) notModified(req *http.Request) bool {
+	if b.code != 0 && b.code != http.StatusOK {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	sum := sha256.Sum256(b.buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	b.Header().Set("ETag", etag)
+	if !etagMatches(req.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	b.release()
+	b.Header().Del("Content-Type")
+	b.ResponseWriter.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches returns true if the given If-None-Match header matches the
+// given ETag, using the weak comparison.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// release returns the buffer to the pool.
+func (b *bufferedResponse) release() {
+	putBuffer(b.buf)
+	b.buf = nil
+}
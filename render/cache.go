@@ -0,0 +1,289 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cache stores the rendered output of the template fragments wrapped in
+// cache blocks, i.e.,
+//
+//	{{ cache "navbar" "10m" }}{{ partial "navbar" }}{{ end }}
+//
+// Cache implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the fragment saved with the given key. It returns nil
+	// data and a nil error if there is no such fragment, or if it expired.
+	Get(key string) ([]byte, error)
+
+	// Set saves the fragment with the given key until the given expiry
+	// time.
+	Set(key string, data []byte, expires time.Time) error
+
+	// Delete deletes the fragment with the given key, i.e., after the data
+	// it shows has changed.
+	Delete(key string) error
+}
+
+// cachedFragment is a fragment saved in a MemoryCache.
+type cachedFragment struct {
+	data    []byte
+	expires time.Time
+}
+
+// A MemoryCache is a Cache that keeps fragments in memory. Fragments aren't
+// shared between processes.
+type MemoryCache struct {
+	mu        sync.RWMutex
+	fragments map[string]cachedFragment
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{fragments: make(map[string]cachedFragment)}
+}
+
+// Get implements Cache.
+func (m *MemoryCache) Get(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, ok := m.fragments[key]
+	if !ok || time.Now().After(f.expires) {
+		return nil, nil
+	}
+	return f.data, nil
+}
+
+// Set implements Cache. Expired fragments are removed.
+func (m *MemoryCache) Set(key string, data []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, f := range m.fragments {
+		if now.After(f.expires) {
+			delete(m.fragments, k)
+		}
+	}
+	m.fragments[key] = cachedFragment{data: data, expires: expires}
+	return nil
+}
+
+// Delete implements Cache.
+func (m *MemoryCache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.fragments, key)
+	return nil
+}
+
+// actionRe matches a template action, capturing its first word.
+var actionRe = regexp.MustCompile(`(?s)\{\{-?\s*(\w*)(.*?)\}\}`)
+
+// blockKeywords are the actions that are closed by an "end" action.
+var blockKeywords = map[string]bool{
+	"if":     true,
+	"range":  true,
+	"with":   true,
+	"block":  true,
+	"define": true,
+	"cache":  true,
+}
+
+// hoistCacheBlocks moves the body of each cache block in the template with
+// the given name into a template of its own, and replaces the block with a
+// call to the "cacheFragment" template func, so that
+//
+//	{{ range .Posts }}{{ cache .Key "1h" }}...{{ end }}{{ end }}
+//
+// becomes
+//
+//	{{ range .Posts }}{{ cacheFragment "fragment:posts/index:1" . .Key "1h" }}{{ end }}
+//	{{ define "fragment:posts/index:1" }}...{{ end }}
+func hoistCacheBlocks(name string, buf []byte) []byte {
+	if !bytes.Contains(buf, []byte("cache")) {
+		return buf
+	}
+
+	type block struct {
+		fragment string
+		trim     bool
+		body     *bytes.Buffer
+	}
+
+	out := &bytes.Buffer{}
+	var (
+		stack     []block
+		defines   bytes.Buffer
+		fragments int
+		last      int
+	)
+	current := func() *bytes.Buffer {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].body != nil {
+				return stack[i].body
+			}
+		}
+		return out
+	}
+
+	for _, m := range actionRe.FindAllSubmatchIndex(buf, -1) {
+		w := current()
+		w.Write(buf[last:m[0]])
+		last = m[1]
+
+		tag := buf[m[0]:m[1]]
+		keyword := string(buf[m[2]:m[3]])
+		switch {
+		case keyword == "cache":
+			fragments++
+			fragment := fmt.Sprintf("fragment:%s:%d", name, fragments)
+			args := strings.TrimSpace(string(buf[m[4]:m[5]]))
+			trim := strings.HasSuffix(args, "-")
+			args = strings.TrimSpace(strings.TrimSuffix(args, "-"))
+			openTag := "{{"
+			if bytes.HasPrefix(tag, []byte("{{-")) {
+				openTag = "{{-"
+			}
+			fmt.Fprintf(w, `%s cacheFragment %q . %s }}`, openTag, fragment, args)
+			stack = append(stack, block{fragment: fragment, trim: trim, body: &bytes.Buffer{}})
+		case blockKeywords[keyword]:
+			w.Write(tag)
+			stack = append(stack, block{})
+		case keyword == "end" && len(stack) > 0:
+			b := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if b.body == nil {
+				w.Write(tag)
+				continue
+			}
+			closeTag := "}}"
+			if b.trim {
+				closeTag = "-}}"
+			}
+			fmt.Fprintf(&defines, "{{ define %q %s", b.fragment, closeTag)
+			b.body.WriteTo(&defines)
+			defines.Write(tag)
+		default:
+			w.Write(tag)
+		}
+	}
+	current().Write(buf[last:])
+	defines.WriteTo(out)
+	return out.Bytes()
+}
+
+// fragmentCache renders the cache blocks of a single page.
+type fragmentCache struct {
+	r *Render
+
+	// called is the name of the first uncacheable template func called by
+	// the fragment being rendered, if any.
+	called string
+
+	// depth is the number of fragments being rendered.
+	depth int
+}
+
+// guard wraps the uncacheable template func with the given name, so that
+// calling it from within a cache block is detected.
+func (f *fragmentCache) guard(name string, fn interface{}) interface{} {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fn
+	}
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		if f.depth > 0 && f.called == "" {
+			f.called = name
+		}
+		if v.Type().IsVariadic() {
+			return v.CallSlice(args)
+		}
+		return v.Call(args)
+	}).Interface()
+}
+
+// fragment is the "cacheFragment" template func. It returns the cached
+// output of the given fragment, or renders and caches it with the given
+// key for the given duration.
+//
+// Fragments that call uncacheable template funcs, such as "csrf", return an
+// error, as their cached output would be wrong for every other request.
+func (f *fragmentCache) fragment(name string, data interface{}, key string, ttl interface{}) (template.HTML, error) {
+	d, err := parseTTL(ttl)
+	if err != nil {
+		return "", fmt.Errorf("seatbelt/render: invalid duration for cache block %s: %w", key, err)
+	}
+
+	cached, err := f.r.cache.Get(key)
+	if err != nil {
+		fmt.Printf("[warning] seatbelt/render: failed to read cache block %s: %v\n", key, err)
+	} else if cached != nil {
+		return template.HTML(cached), nil
+	}
+
+	tpl := f.r.re.TemplateLookup(name)
+	if tpl == nil {
+		return "", fmt.Errorf("seatbelt/render: template %s does not exist", name)
+	}
+
+	called := f.called
+	f.called = ""
+	f.depth++
+	defer func() {
+		f.depth--
+		f.called = called
+	}()
+
+	e := Event{Name: name, Partial: true}
+	f.r.hooks.before(e)
+	start := time.Now()
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	err = tpl.Execute(buf, data)
+	f.r.hooks.after(e, start, err)
+	if err != nil {
+		return "", err
+	}
+	if f.called != "" {
+		return "", fmt.Errorf("seatbelt/render: cache block %s calls %s, whose output is different for every request", key, f.called)
+	}
+
+	// The buffer is reused, so the cache gets a copy of its contents.
+	cached = append([]byte(nil), buf.Bytes()...)
+	if err := f.r.cache.Set(key, cached, time.Now().Add(d)); err != nil {
+		fmt.Printf("[warning] seatbelt/render: failed to save cache block %s: %v\n", key, err)
+	}
+
+	// Return safe HTML here since we are rendering our own template.
+	return template.HTML(buf.String()), nil
+}
+
+// parseTTL parses the duration of a cache block, given as a duration string,
+// i.e., "10m", a number of seconds, or a time.Duration.
+func parseTTL(ttl interface{}) (time.Duration, error) {
+	switch v := ttl.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(seconds) * time.Second, nil
+		}
+		return time.ParseDuration(v)
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	}
+	return 0, fmt.Errorf("unsupported type %T", ttl)
+}
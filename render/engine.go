@@ -0,0 +1,30 @@
+package render
+
+import (
+	"html/template"
+	"io"
+)
+
+// An Engine renders templates with an alternate template engine, i.e.,
+// templ or jet, in place of html/template. The Render still handles
+// layouts, template funcs, hooks, status codes, and buffering.
+type Engine interface {
+	// Render writes the template with the given name to w. If layout is
+	// not empty, the template is rendered in the layout with that name.
+	// The funcs are the request's template funcs. The "partial" and
+	// "render" funcs among them only render html/template templates.
+	Render(w io.Writer, name, layout string, data map[string]interface{}, funcs template.FuncMap) error
+
+	// Exists returns true if a template with the given name exists.
+	Exists(name string) bool
+}
+
+// engineHTML renders the template with the given name with the engine.
+func (r *Render) engineHTML(w io.Writer, status int, name, layout string, data map[string]interface{}, funcs template.FuncMap) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := r.engine.Render(buf, name, layout, data, funcs); err != nil {
+		return err
+	}
+	return writeHTML(w, status, buf)
+}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"encoding/json"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// formats are the content types of the formats that templates can be
+// rendered in, other than HTML.
+var formats = map[string]string{
+	"json": "application/json; charset=UTF-8",
+	"xml":  "application/xml; charset=UTF-8",
+	"txt":  "text/plain; charset=UTF-8",
+	"csv":  "text/csv; charset=UTF-8",
+}
+
+// negotiateFormat returns the format that the request's Accept header
+// prefers, or "html" if it prefers HTML or doesn't prefer any of the
+// supported formats.
+func negotiateFormat(req *http.Request) string {
+	format, best := "html", 0.0
+	for _, accepted := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= best {
+			continue
+		}
+
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			format, best = "html", q
+		default:
+			for f, contentType := range formats {
+				if strings.HasPrefix(contentType, mediaType+";") {
+					format, best = f, q
+				}
+			}
+		}
+	}
+	return format
+}
+
+// formatTemplate returns the name of the template to render for the given
+// template name in the given format, i.e., "users/show.json", and its content
+// type. It returns an empty name if the template should be rendered as HTML.
+func (r *Render) formatTemplate(name, format string) (string, string) {
+	contentType, ok := formats[format]
+	if !ok || r.engine != nil {
+		return "", ""
+	}
+	if !r.Exists(name + "." + format) {
+		return "", ""
+	}
+	return name + "." + format, contentType
+}
+
+// toJSON is the "json" template func, which encodes the given value as JSON
+// in templates of other formats, i.e.,
+//
+//	{"id": {{ json .User.ID }}, "name": {{ json .User.Name }}}
+//
+// The encoded value is safe to use in HTML, as encoding/json escapes HTML
+// characters.
+func toJSON(v interface{}) (template.HTML, error) {
+	b, err := json.Marshal(v)
+	return template.HTML(b), err
+}
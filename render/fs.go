@@ -0,0 +1,162 @@
+package render
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A mount is a template file system whose templates are named under a
+// directory prefix.
+type mount struct {
+	prefix string
+	fsys   fs.FS
+}
+
+// layeredFS implements the unrolled/render FileSystem interface for a local
+// template directory, with other template file systems mounted under it.
+// Templates in the local directory take precedence over mounted templates
+// with the same name, so that applications can override them.
+//
+// If base is set, its templates are used in place of the local directory,
+// i.e., for templates embedded in the binary.
+type layeredFS struct {
+	dir    string
+	base   fs.FS
+	mu     sync.RWMutex
+	mounts []mount
+
+	// parents maps the names of layouts to the parent layouts they extend.
+	parents map[string]string
+}
+
+// walkLocal walks the local directory, or the base file system if one is
+// set.
+func (l *layeredFS) walkLocal(root string, walkFn filepath.WalkFunc) error {
+	if l.base == nil {
+		return filepath.Walk(root, walkFn)
+	}
+	return fs.WalkDir(l.base, ".", func(path string, d fs.DirEntry, err error) error {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err != nil {
+			return walkFn(full, nil, err)
+		}
+		info, err := d.Info()
+		return walkFn(full, info, err)
+	})
+}
+
+// readLocal reads the file with the given name from the local directory, or
+// from the base file system if one is set.
+func (l *layeredFS) readLocal(name string) ([]byte, error) {
+	if l.base == nil {
+		return os.ReadFile(name)
+	}
+	rel, err := filepath.Rel(l.dir, name)
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+	return fs.ReadFile(l.base, filepath.ToSlash(rel))
+}
+
+// Walk walks the local directory, and then the mounted file systems, as if
+// they were subdirectories of it.
+func (l *layeredFS) Walk(root string, walkFn filepath.WalkFunc) error {
+	seen := make(map[string]bool)
+	if err := l.walkLocal(root, func(path string, info os.FileInfo, err error) error {
+		seen[path] = true
+		return walkFn(path, info, err)
+	}); err != nil {
+		return err
+	}
+
+	l.mu.RLock()
+	mounts := l.mounts
+	l.mu.RUnlock()
+
+	for _, m := range mounts {
+		if err := fs.WalkDir(m.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			full := filepath.Join(root, m.prefix, filepath.FromSlash(path))
+			if seen[full] {
+				return nil
+			}
+			info, err := d.Info()
+			return walkFn(full, info, err)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFile reads the template file with the given name, and preprocesses it.
+func (l *layeredFS) ReadFile(name string) ([]byte, error) {
+	buf, err := l.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tplName := strings.TrimSuffix(name, filepath.Ext(name))
+	if rel, err := filepath.Rel(l.dir, tplName); err == nil {
+		tplName = filepath.ToSlash(rel)
+	}
+	buf, parent := preprocess(tplName, buf)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.parents == nil {
+		l.parents = make(map[string]string)
+	}
+	if parent != "" {
+		l.parents[tplName] = parent
+	} else {
+		delete(l.parents, tplName)
+	}
+	return buf, nil
+}
+
+// parent returns the name of the parent layout the given layout extends, or
+// an empty string.
+func (l *layeredFS) parent(layout string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.parents[layout]
+}
+
+// readFile reads the file with the given name from the local directory, or
+// from the file system mounted under its directory.
+func (l *layeredFS) readFile(name string) ([]byte, error) {
+	buf, err := l.readLocal(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return buf, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, m := range l.mounts {
+		prefix := filepath.Join(l.dir, m.prefix) + string(filepath.Separator)
+		if rel := strings.TrimPrefix(name, prefix); rel != name {
+			return fs.ReadFile(m.fsys, filepath.ToSlash(rel))
+		}
+	}
+	return nil, err
+}
+
+// Mount adds the templates in fsys under the given directory prefix, so
+// that a template "posts/index.html" in fsys mounted under "blog" is
+// rendered as "blog/posts/index". Templates in the template directory with
+// the same name take precedence. All templates are recompiled.
+func (r *Render) Mount(prefix string, fsys fs.FS) {
+	r.fs.mu.Lock()
+	r.fs.mounts = append(r.fs.mounts, mount{prefix: prefix, fsys: fsys})
+	r.fs.mu.Unlock()
+
+	r.re.CompileTemplates()
+}
@@ -3,12 +3,16 @@
 package render
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/unrolled/render"
 )
@@ -20,14 +24,81 @@ import (
 type ContextualFuncMap func(w http.ResponseWriter, r *http.Request) template.FuncMap
 
 type Render struct {
-	re    *render.Render
-	funcs []ContextualFuncMap
+	re     *render.Render
+	fs     *layeredFS
+	funcs  []ContextualFuncMap
+	hooks  Hooks
+	layout string
+	reload bool
+	engine Engine
+
+	cache       Cache
+	uncacheable []string
+
+	streamThreshold int
+	etag            bool
+}
+
+// An Event describes the execution of a template, and is passed to Hooks.
+type Event struct {
+	// The name of the template.
+	Name string
+
+	// The layout the template is rendered in, if any.
+	Layout string
+
+	// Whether the template is a partial rendered from within another
+	// template.
+	Partial bool
+
+	// How long the template took to execute. Only set after execution.
+	Duration time.Duration
+
+	// The error returned by the template, if any. Only set after execution.
+	Err error
+}
+
+// Hooks are called before and after each template and partial is executed,
+// i.e., in order to attribute render time to templates in APM integrations.
+type Hooks struct {
+	// BeforeRender is called before a template is executed.
+	BeforeRender func(e Event)
+
+	// AfterRender is called after a template is executed.
+	AfterRender func(e Event)
+}
+
+// before calls the BeforeRender hook if it's set.
+func (h Hooks) before(e Event) {
+	if h.BeforeRender != nil {
+		h.BeforeRender(e)
+	}
+}
+
+// after calls the AfterRender hook if it's set.
+func (h Hooks) after(e Event, start time.Time, err error) {
+	if h.AfterRender != nil {
+		e.Duration = time.Since(start)
+		e.Err = err
+		h.AfterRender(e)
+	}
+}
+
+// enabled returns true if any hooks are set.
+func (h Hooks) enabled() bool {
+	return h.BeforeRender != nil || h.AfterRender != nil
 }
 
 type Options struct {
 	// The directory to serve templates from. Default is "templates".
 	Dir string
 
+	// FS serves templates from the given file system instead of Dir, i.e.,
+	// an embed.FS, so that templates ship inside the binary. In Reload mode,
+	// templates are still read from Dir if it is set, so that changes are
+	// picked up without recompiling. Default is nil.
+	FS fs.FS
+
 	// The template to use as a layout. Layouts can call {{ yield }}. Defaults
 	// to an empty string (meaning a layout is not used).
 	Layout string
@@ -39,6 +110,42 @@ type Options struct {
 	// recompile templates in production, as this adds a significant
 	// performance penalty.
 	Reload bool
+
+	// Hooks called before and after templates and partials are executed.
+	// Default is no hooks.
+	Hooks Hooks
+
+	// The file extensions of templates, i.e., ".gohtml". Default is ".html".
+	//
+	// Templates of other formats are named with the format before the
+	// extension, i.e., "users/show.json.tmpl" next to "users/show.html",
+	// with ".tmpl" added to the extensions. HTML renders them in place of
+	// the HTML template when the request prefers their format.
+	Extensions []string
+
+	// Engine renders templates with an alternate template engine instead of
+	// html/template. Dir, FS, and Extensions are ignored. Default is nil.
+	Engine Engine
+
+	// StreamThreshold is the size in bytes above which rendered HTML is
+	// streamed with chunked encoding, instead of being sent with a
+	// Content-Length. Default is 0, meaning a Content-Length is always sent.
+	StreamThreshold int
+
+	// Cache stores the output of cache blocks. Default is an in-memory
+	// cache.
+	Cache Cache
+
+	// Uncacheable are the names of template funcs whose output is different
+	// for every request, i.e., "csrf". Cache blocks that call them return an
+	// error. Default is nil.
+	Uncacheable []string
+
+	// ETag sets a strong ETag computed from each page rendered for a GET or
+	// HEAD request, and responds with 304 Not Modified when it matches the
+	// request's If-None-Match header. Streamed pages have no ETag. Default
+	// is false.
+	ETag bool
 }
 
 func New(o *Options) *Render {
@@ -60,34 +167,115 @@ func New(o *Options) *Render {
 
 	// Mock the template funcs by passing in the user-defined template funcs
 	// as no-ops in order for the templates to compile successfully. The real
-	// implementations are injected at render time.
+	// implementations are injected at render time. A func defined by more
+	// than one func map is taken from the first one.
 	mocks := make(map[string]interface{})
 	if o.Funcs != nil {
 		for _, fn := range o.Funcs {
 			if fn != nil {
 				for k := range fn(nil, nil) {
+					if _, ok := mocks[k]; ok {
+						log.Printf("[warning] seatbelt/render: func %s is already defined, and will not be overridden", k)
+						continue
+					}
 					mocks[k] = func() template.HTML { return "" }
 				}
 			}
 		}
 	}
+	mocks["render"] = func(string, ...interface{}) template.HTML { return "" }
+	mocks["yieldBlock"] = func(string) template.HTML { return "" }
+	mocks["cacheFragment"] = func(string, interface{}, string, interface{}) template.HTML { return "" }
+	mocks["json"] = toJSON
+
+	dir := o.Dir
+	if dir == "" {
+		dir = "templates"
+	}
+	extensions := o.Extensions
+	if len(extensions) == 0 {
+		extensions = []string{".html"}
+	}
+
+	fs := &layeredFS{dir: dir}
+	if o.FS != nil && !(o.Reload && o.Dir != "") {
+		fs.base = o.FS
+	}
+
+	cache := o.Cache
+	if cache == nil {
+		cache = NewMemoryCache()
+	}
 
 	re := render.New(render.Options{
-		Directory:     o.Dir,
+		Directory:     dir,
+		FileSystem:    fs,
 		Layout:        o.Layout,
-		Extensions:    []string{".html"},
+		Extensions:    extensions,
 		IsDevelopment: o.Reload,
-		Funcs:         []template.FuncMap{mocks},
+
+		// Errors are returned to the caller, which decides how to respond.
+		DisableHTTPErrorRendering: true,
+		BufferPool:                pool{},
+		Funcs:                     []template.FuncMap{mocks},
 	})
 
 	return &Render{
-		re:    re,
-		funcs: o.Funcs,
+		re:     re,
+		fs:     fs,
+		funcs:  o.Funcs,
+		hooks:  o.Hooks,
+		layout: o.Layout,
+		reload: o.Reload,
+		engine: o.Engine,
+
+		cache:       cache,
+		uncacheable: o.Uncacheable,
+
+		streamThreshold: o.StreamThreshold,
+		etag:            o.ETag,
 	}
 }
 
 type RenderOptions struct {
-	Layout     string
+	// The layout to render the template in, instead of the default layout.
+	// A name without a directory, i.e., "marketing", resolves to
+	// "layouts/marketing" if that template exists.
+	Layout string
+
+	// SkipLayout renders the template on its own, i.e., for a Turbo Frame or
+	// an htmx fragment. Default is false.
+	SkipLayout bool
+
+	// Stream writes the layout to the response as it is executed, instead
+	// of buffering the whole page, i.e., for very large pages. The response
+	// is sent without a Content-Length, and if the template fails part way
+	// through, the page is cut short instead of an error page being
+	// rendered. Pages in nested layouts are always buffered. Default is
+	// false.
+	Stream bool
+
+	// Tenant and Locale select overrides of the template and its layout,
+	// which are looked up in the following order, i.e., for "users/index"
+	// with the tenant "acme" and the locale "fr":
+	//
+	//	acme/users/index.fr
+	//	acme/users/index
+	//	users/index.fr
+	//	users/index
+	//
+	// A locale with a region, i.e., "fr-CA", also falls back to its
+	// language. Default is no overrides.
+	Tenant string
+	Locale string
+
+	// Format renders the template of the given format, i.e., "json" renders
+	// "users/show.json" in place of "users/show", without a layout. Default
+	// is the format preferred by the request's Accept header, if the
+	// template has a variant in that format. The formats are "html", "json",
+	// "xml", "txt", and "csv".
+	Format string
+
 	StatusCode int
 	Headers    map[string]string
 }
@@ -109,16 +297,95 @@ func (r *Render) TextError(w io.Writer, error string, code int) {
 	w.Write([]byte(error))
 }
 
+// Exists returns true if a template with the given name exists.
+func (r *Render) Exists(name string) bool {
+	if r.engine != nil {
+		return r.engine.Exists(name)
+	}
+	return r.re.TemplateLookup(name) != nil
+}
+
+// layoutsDir is the directory within the templates directory that alternate
+// layouts are looked up in.
+const layoutsDir = "layouts/"
+
+// resolveLayout returns the name of the template to use for the given layout.
+// Layouts given without a directory are looked up in the layouts directory
+// first.
+func (r *Render) resolveLayout(layout string) string {
+	if layout == "" || strings.Contains(layout, "/") {
+		return layout
+	}
+	if r.Exists(layoutsDir + layout) {
+		return layoutsDir + layout
+	}
+	return layout
+}
+
+// override returns the name of the first of the tenant's and the locale's
+// overrides of the template with the given name that exists, or the name
+// itself if none do.
+func (r *Render) override(name, tenant, locale string) string {
+	if name == "" || (tenant == "" && locale == "") {
+		return name
+	}
+
+	var locales []string
+	if locale != "" {
+		locales = append(locales, locale)
+		if i := strings.IndexByte(locale, '-'); i != -1 {
+			locales = append(locales, locale[:i])
+		}
+	}
+
+	prefixes := []string{""}
+	if tenant != "" {
+		prefixes = []string{tenant + "/", ""}
+	}
+
+	for _, prefix := range prefixes {
+		for _, l := range locales {
+			if r.Exists(prefix + name + "." + l) {
+				return prefix + name + "." + l
+			}
+		}
+		if prefix != "" && r.Exists(prefix+name) {
+			return prefix + name
+		}
+	}
+	return name
+}
+
 // HTML renders the HTML template with the given name. The HTTP request is
 // optional, and can be set to nil. It is only used to add request-specific
 // context to HTML template functions.
-func (r *Render) HTML(w io.Writer, req *http.Request, name string, data map[string]interface{}, opts ...RenderOptions) {
+//
+// Unless it is streamed, the page is buffered, so if the template fails to
+// execute, nothing is written and the error is returned.
+func (r *Render) HTML(w io.Writer, req *http.Request, name string, data map[string]interface{}, opts ...RenderOptions) error {
 	var o RenderOptions
 	for _, opt := range opts {
 		o = opt
 	}
 	o.setDefaults()
 
+	// Render the tenant's or locale's override of the template, if it has
+	// one, and the variant of the template in the requested format. New
+	// templates are picked up in Reload mode.
+	format := o.Format
+	if format == "" && req != nil {
+		format = negotiateFormat(req)
+	}
+	if r.reload && r.engine == nil && (o.Tenant != "" || o.Locale != "" || formats[format] != "") {
+		r.re.CompileTemplates()
+	}
+	name = r.override(name, o.Tenant, o.Locale)
+	contentType := htmlContentType
+	if formatName, formatType := r.formatTemplate(name, format); formatName != "" {
+		name, contentType = formatName, formatType
+		o.SkipLayout, o.Stream = true, false
+	}
+
 	// Prevent read from nil errors by ensuring the map is always initialized.
 	//
 	// TODO In Seatbelt, this should come from the newly propsed `Values`
@@ -128,7 +395,13 @@ func (r *Render) HTML(w io.Writer, req *http.Request, name string, data map[stri
 	}
 
 	// Prepare the render options.
-	htmlOpts := render.HTMLOptions{Layout: o.Layout}
+	layout := r.resolveLayout(o.Layout)
+	if layout != "" {
+		layout = r.override(layout, o.Tenant, o.Locale)
+	} else if l := r.override(r.layout, o.Tenant, o.Locale); l != r.layout {
+		layout = l
+	}
+	htmlOpts := render.HTMLOptions{Layout: layout}
 
 	// Add the template funcs, providing the context of the current request,
 	// if one is provided.
@@ -142,9 +415,7 @@ func (r *Render) HTML(w io.Writer, req *http.Request, name string, data map[stri
 					if fn != nil {
 						m := fn(rw, req)
 						for k, v := range m {
-							if _, ok := mergedFuncMap[k]; ok {
-								fmt.Printf("[warning] seatbelt/render.HTML: func %s overrides existing func\n", k)
-							} else {
+							if _, ok := mergedFuncMap[k]; !ok {
 								mergedFuncMap[k] = v
 							}
 						}
@@ -161,15 +432,239 @@ func (r *Render) HTML(w io.Writer, req *http.Request, name string, data map[stri
 		}
 	}
 
-	// Even if the template isn't found, the given status code is respected.
-	// This is somewhat confusing, but works better in a
-	// Turbo (https://turbo.hotwired.dev/) context because it causes the error
-	// page rendered by unrolled/render to actually show  up instead of being
-	// silently dropped due to the >=400 level status code.
-	//
-	// If an error occurs, the reponse has already been written meaning that
-	// it's too late to intervene, so the best we can do is log it.
-	if err := r.re.HTML(w, o.StatusCode, name, data, htmlOpts); err != nil {
-		log.Printf("seatbelt/render: failed to render template: %v", err)
+	// Replace the partial helper with one that detects recursion and reports
+	// the execution of each partial to the hooks.
+	if htmlOpts.Funcs == nil {
+		htmlOpts.Funcs = make(map[string]interface{})
+	}
+	htmlOpts.Funcs["partial"] = r.partialFunc(name, data)
+	htmlOpts.Funcs["yieldBlock"] = htmlOpts.Funcs["partial"]
+	htmlOpts.Funcs["render"] = r.renderFunc(data)
+
+	fc := &fragmentCache{r: r}
+	htmlOpts.Funcs["cacheFragment"] = fc.fragment
+	for _, name := range r.uncacheable {
+		if fn, ok := htmlOpts.Funcs[name]; ok {
+			htmlOpts.Funcs[name] = fc.guard(name, fn)
+		}
+	}
+
+	e := Event{Name: name, Layout: layout}
+	if e.Layout == "" {
+		e.Layout = r.layout
+	}
+	if o.SkipLayout {
+		e.Layout = ""
+	}
+	// Pages rendered to an http.ResponseWriter are buffered, so that their
+	// Content-Length can be set, unless they are streamed.
+	stream := o.Stream && r.engine == nil
+	out := w
+	var buffered *bufferedResponse
+	if ok && !stream {
+		buffered = newBufferedResponse(rw)
+		out = buffered
+	}
+
+	r.hooks.before(e)
+	start := time.Now()
+
+	var err error
+	if r.engine != nil {
+		err = r.engineHTML(out, o.StatusCode, name, e.Layout, data, htmlOpts.Funcs)
+	} else if o.SkipLayout && stream {
+		err = r.streamHTML(out, o.StatusCode, name, "", data, htmlOpts.Funcs)
+	} else if o.SkipLayout {
+		err = r.htmlWithoutLayout(out, o.StatusCode, name, contentType, data, htmlOpts.Funcs)
+	} else if parents, perr := r.parentLayouts(e.Layout); perr != nil {
+		err = perr
+	} else if len(parents) > 0 {
+		err = r.htmlInNestedLayouts(out, o.StatusCode, name, parents, data, htmlOpts)
+	} else if stream {
+		err = r.streamHTML(out, o.StatusCode, name, e.Layout, data, htmlOpts.Funcs)
+	} else {
+		err = r.re.HTML(out, o.StatusCode, name, data, htmlOpts)
+	}
+	r.hooks.after(e, start, err)
+	if err != nil {
+		if buffered != nil {
+			buffered.release()
+		}
+		return fmt.Errorf("seatbelt/render: failed to render template %s: %w", name, err)
+	}
+	if buffered != nil {
+		if r.etag && req != nil && buffered.notModified(req) {
+			return nil
+		}
+		return buffered.flush(r.streamThreshold)
+	}
+	return nil
+}
+
+// htmlWithoutLayout renders the HTML template with the given name on its own,
+// the same way unrolled/render renders it in a layout, and sends it with the
+// given content type.
+func (r *Render) htmlWithoutLayout(w io.Writer, status int, name, contentType string, data interface{}, funcs template.FuncMap) error {
+	if r.reload {
+		r.re.CompileTemplates()
+	}
+
+	tpl := r.re.TemplateLookup(name)
+	if tpl == nil {
+		return fmt.Errorf("html/template: %q is undefined", name)
+	}
+	tpl.Funcs(funcs)
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := tpl.Execute(buf, data); err != nil {
+		return err
+	}
+	return writeBody(w, status, contentType, buf)
+}
+
+// streamHTML executes the HTML template with the given name, in the given
+// layout if one is given, directly to w. The page itself is rendered into a
+// buffer when the layout yields to it, as html/template funcs can't write to
+// the output.
+func (r *Render) streamHTML(w io.Writer, status int, name, layout string, data interface{}, funcs template.FuncMap) error {
+	if r.reload {
+		r.re.CompileTemplates()
+	}
+
+	tpl := r.re.TemplateLookup(name)
+	if tpl == nil {
+		return fmt.Errorf("html/template: %q is undefined", name)
+	}
+	if layout != "" {
+		page := tpl
+		if tpl = r.re.TemplateLookup(layout); tpl == nil {
+			return fmt.Errorf("html/template: %q is undefined", layout)
+		}
+
+		layoutFuncs := template.FuncMap{}
+		for k, v := range funcs {
+			layoutFuncs[k] = v
+		}
+		layoutFuncs["yield"] = func() (template.HTML, error) {
+			buf := getBuffer()
+			defer putBuffer(buf)
+			err := page.Execute(buf, data)
+			// Return safe HTML here since we are rendering our own template.
+			return template.HTML(buf.String()), err
+		}
+		layoutFuncs["current"] = func() string { return name }
+		funcs = layoutFuncs
+	}
+	tpl.Funcs(funcs)
+
+	if rw, ok := w.(http.ResponseWriter); ok {
+		rw.Header().Set("Content-Type", htmlContentType)
+		rw.WriteHeader(status)
+	}
+	return tpl.Execute(w, data)
+}
+
+// htmlContentType is the content type of rendered HTML pages.
+const htmlContentType = "text/html; charset=UTF-8"
+
+// writeHTML writes the given rendered page with the given status code.
+func writeHTML(w io.Writer, status int, buf *bytes.Buffer) error {
+	return writeBody(w, status, htmlContentType, buf)
+}
+
+// writeBody writes the given rendered template with the given status code
+// and content type.
+func writeBody(w io.Writer, status int, contentType string, buf *bytes.Buffer) error {
+	if rw, ok := w.(http.ResponseWriter); ok {
+		rw.Header().Set("Content-Type", contentType)
+		rw.WriteHeader(status)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// renderFunc returns the "render" template func, which renders the template
+// with the given name without a layout, i.e.,
+//
+//	{{ range .Users }}{{ render "users/_row" . }}{{ end }}
+//
+// The template is rendered with the data of the page if no data is given.
+func (r *Render) renderFunc(pageData interface{}) func(name string, data ...interface{}) (template.HTML, error) {
+	var stack []string
+
+	return func(name string, data ...interface{}) (template.HTML, error) {
+		tpl := r.re.TemplateLookup(name)
+		if tpl == nil {
+			return "", fmt.Errorf("seatbelt/render: template %s does not exist", name)
+		}
+		if len(stack) >= maxPartialDepth {
+			return "", fmt.Errorf("seatbelt/render: templates are nested more than %d levels deep: %s", maxPartialDepth, strings.Join(stack, " -> "))
+		}
+		stack = append(stack, name)
+		defer func() { stack = stack[:len(stack)-1] }()
+
+		binding := pageData
+		if len(data) > 0 {
+			binding = data[0]
+		}
+
+		e := Event{Name: name, Partial: true}
+		r.hooks.before(e)
+		start := time.Now()
+
+		buf := getBuffer()
+		defer putBuffer(buf)
+		err := tpl.Execute(buf, binding)
+		r.hooks.after(e, start, err)
+
+		// Return safe HTML here since we are rendering our own template.
+		return template.HTML(buf.String()), err
+	}
+}
+
+// maxPartialDepth is the maximum number of partials that can be nested
+// within each other.
+const maxPartialDepth = 32
+
+// partialFunc returns a replacement for the "partial" template func that
+// calls the render hooks around the execution of the partial.
+//
+// Unlike the default implementation, it keeps track of the partials that are
+// currently being executed, and returns an error when a partial includes
+// itself, directly or indirectly, or when partials are nested too deeply.
+// Without this, a recursive partial overflows the stack.
+func (r *Render) partialFunc(name string, data interface{}) func(partialName string) (template.HTML, error) {
+	var stack []string
+
+	return func(partialName string) (template.HTML, error) {
+		fullName := partialName + "-" + name
+		tpl := r.re.TemplateLookup(fullName)
+		if tpl == nil {
+			return "", nil
+		}
+
+		for _, active := range stack {
+			if active == fullName {
+				return "", fmt.Errorf("seatbelt/render: partial %s includes itself: %s -> %s", fullName, strings.Join(stack, " -> "), fullName)
+			}
+		}
+		if len(stack) >= maxPartialDepth {
+			return "", fmt.Errorf("seatbelt/render: partials are nested more than %d levels deep: %s", maxPartialDepth, strings.Join(stack, " -> "))
+		}
+		stack = append(stack, fullName)
+		defer func() { stack = stack[:len(stack)-1] }()
+
+		e := Event{Name: fullName, Partial: true}
+		r.hooks.before(e)
+		start := time.Now()
+
+		buf := getBuffer()
+		defer putBuffer(buf)
+		err := tpl.Execute(buf, data)
+		r.hooks.after(e, start, err)
+
+		// Return safe HTML here since we are rendering our own template.
+		return template.HTML(buf.String()), err
 	}
 }
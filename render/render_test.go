@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"html/template"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestRender(t *testing.T) {
@@ -61,26 +63,18 @@ func TestRender(t *testing.T) {
 		}
 	})
 
-	t.Run("render a non-existent template should 500 with an error message", func(t *testing.T) {
+	t.Run("render a non-existent template should return an error without writing", func(t *testing.T) {
 		rr := httptest.NewRecorder()
 		tr := httptest.NewRequest(http.MethodGet, "/", nil)
 
-		h := func(w http.ResponseWriter, req *http.Request) {
-			r.HTML(w, req, "not-found.html", nil)
-		}
-
-		h(rr, tr)
-
-		code := rr.Result().StatusCode
-		expected := 500
-		if code != expected {
-			t.Errorf("expected status code %d but got %d", expected, code)
-		}
+		err := r.HTML(rr, tr, "not-found.html", nil)
 
-		s := rr.Body.String()
 		contains := `html/template: "not-found.html" is undefined`
-		if !strings.Contains(s, contains) {
-			t.Errorf("expected body %s to contain %s", s, contains)
+		if err == nil || !strings.Contains(err.Error(), contains) {
+			t.Errorf("expected error %v to contain %s", err, contains)
+		}
+		if rr.Body.Len() != 0 || rr.Header().Get("Content-Type") != "" {
+			t.Errorf("expected nothing to be written but got %s", rr.Body.String())
 		}
 	})
 
@@ -173,3 +167,449 @@ func BenchmarkSlowRender(b *testing.B) {
 		r.HTML(w, nil, "index", nil)
 	}
 }
+
+func BenchmarkStreamRender(b *testing.B) {
+	r := New(&Options{
+		Dir:    filepath.Join("testdata", "nested"),
+		Layout: "layouts/application",
+	})
+	data := map[string]interface{}{"title": "Home"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.HTML(httptest.NewRecorder(), nil, "index", data, RenderOptions{Stream: true})
+	}
+}
+
+func TestDuplicateFuncs(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	funcMap := func(name string) ContextualFuncMap {
+		return func(w http.ResponseWriter, r *http.Request) template.FuncMap {
+			return template.FuncMap{"name": func() string { return name }}
+		}
+	}
+	r := New(&Options{
+		FS:    fstest.MapFS{"index.html": {Data: []byte("{{ name }}")}},
+		Funcs: []ContextualFuncMap{funcMap("first"), funcMap("second")},
+	})
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		if err := r.HTML(rr, httptest.NewRequest(http.MethodGet, "/", nil), "index", nil); err != nil {
+			t.Fatal(err)
+		}
+		if body := rr.Body.String(); body != "first" {
+			t.Fatalf("expected the first func to be used but got %s", body)
+		}
+	}
+	if n := strings.Count(logs.String(), "[warning]"); n != 1 {
+		t.Fatalf("expected 1 warning but got %d: %s", n, logs.String())
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var events []Event
+
+	r := New(&Options{
+		Dir:    filepath.Join("testdata", "templates"),
+		Layout: "layout",
+		Funcs: []ContextualFuncMap{
+			func(w http.ResponseWriter, r *http.Request) template.FuncMap {
+				return map[string]interface{}{
+					"path": func() string {
+						return r.URL.Path
+					},
+				}
+			},
+		},
+		Hooks: Hooks{
+			AfterRender: func(e Event) {
+				events = append(events, e)
+			},
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	r.HTML(rr, req, "home", nil)
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events but got %d: %+v", len(events), events)
+	}
+
+	partial, page := events[0], events[1]
+	if partial.Name != "title-home" || !partial.Partial {
+		t.Errorf("expected partial title-home but got %+v", partial)
+	}
+	if page.Name != "home" || page.Layout != "layout" || page.Partial {
+		t.Errorf("expected page home in layout but got %+v", page)
+	}
+	if page.Duration < partial.Duration {
+		t.Errorf("expected page duration %s to include partial duration %s", page.Duration, partial.Duration)
+	}
+}
+
+func TestRecursivePartial(t *testing.T) {
+	r := New(&Options{
+		Dir:    filepath.Join("testdata", "recursive"),
+		Layout: "layout",
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	err := r.HTML(rr, req, "loop", nil)
+
+	contains := "partial row-loop includes itself: row-loop -> row-loop"
+	if err == nil || !strings.Contains(err.Error(), contains) {
+		t.Errorf("expected error %v to contain %s", err, contains)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected nothing to be written but got %s", rr.Body.String())
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html":      {Data: []byte("<main>{{ yield }}</main>")},
+		"posts/index.html": {Data: []byte("<h1>embedded</h1>")},
+	}
+
+	t.Run("templates are read from the file system", func(t *testing.T) {
+		r := New(&Options{Dir: "missing", FS: fsys, Layout: "layout"})
+
+		b := &bytes.Buffer{}
+		r.HTML(b, nil, "posts/index", nil)
+
+		if s := b.String(); s != "<main><h1>embedded</h1></main>" {
+			t.Fatalf("expected embedded template but got %s", s)
+		}
+	})
+
+	t.Run("templates are read from disk in reload mode", func(t *testing.T) {
+		r := New(&Options{
+			Dir:    filepath.Join("testdata", "templates"),
+			FS:     fsys,
+			Reload: true,
+			Funcs: []ContextualFuncMap{
+				func(w http.ResponseWriter, r *http.Request) template.FuncMap {
+					return template.FuncMap{"path": func() string { return "" }}
+				},
+			},
+		})
+
+		b := &bytes.Buffer{}
+		r.HTML(b, nil, "index", nil)
+
+		if s := b.String(); !strings.Contains(s, "index") || strings.Contains(s, "embedded") {
+			t.Fatalf("expected template from disk but got %s", s)
+		}
+	})
+}
+
+func TestContentLength(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte("<h1>index</h1>")},
+	}
+
+	t.Run("html is sent with a content length", func(t *testing.T) {
+		r := New(&Options{FS: fsys})
+		rr := httptest.NewRecorder()
+		r.HTML(rr, nil, "index", nil)
+
+		if v := rr.Header().Get("Content-Length"); v != "14" {
+			t.Fatalf("expected Content-Length 14 but got %s", v)
+		}
+		if s := rr.Body.String(); s != "<h1>index</h1>" {
+			t.Fatalf("expected <h1>index</h1> but got %s", s)
+		}
+	})
+
+	t.Run("html above the stream threshold is sent without a content length", func(t *testing.T) {
+		r := New(&Options{FS: fsys, StreamThreshold: 8})
+		rr := httptest.NewRecorder()
+		r.HTML(rr, nil, "index", nil, RenderOptions{StatusCode: http.StatusCreated})
+
+		if v := rr.Header().Get("Content-Length"); v != "" {
+			t.Fatalf("expected no Content-Length but got %s", v)
+		}
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected %d but got %d", http.StatusCreated, rr.Code)
+		}
+		if s := rr.Body.String(); s != "<h1>index</h1>" {
+			t.Fatalf("expected <h1>index</h1> but got %s", s)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	r := New(&Options{
+		Dir:    filepath.Join("testdata", "nested"),
+		Layout: "layouts/application",
+	})
+	data := map[string]interface{}{"title": "Home"}
+
+	t.Run("stream a page in its layout", func(t *testing.T) {
+		buffered := httptest.NewRecorder()
+		if err := r.HTML(buffered, nil, "index", data); err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		if err := r.HTML(rr, nil, "index", data, RenderOptions{Stream: true, StatusCode: http.StatusAccepted}); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected %d but got %d", http.StatusAccepted, rr.Code)
+		}
+		if v := rr.Header().Get("Content-Length"); v != "" {
+			t.Fatalf("expected no Content-Length but got %s", v)
+		}
+		if rr.Body.String() != buffered.Body.String() {
+			t.Fatalf("expected %s but got %s", buffered.Body.String(), rr.Body.String())
+		}
+	})
+
+	t.Run("stream a page without a layout", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		if err := r.HTML(rr, nil, "plain", nil, RenderOptions{Stream: true, SkipLayout: true}); err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.TrimSpace(rr.Body.String()); s != "<h1>plain</h1>" {
+			t.Fatalf("expected <h1>plain</h1> but got %s", s)
+		}
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	r := New(&Options{
+		Dir:        filepath.Join("testdata", "extensions"),
+		Extensions: []string{".tmpl"},
+		Layout:     "layout",
+	})
+
+	b := &bytes.Buffer{}
+	if err := r.HTML(b, nil, "index", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.TrimSpace(b.String()); s != "<main><h1>tmpl</h1>\n</main>" {
+		t.Fatalf("expected the .tmpl template but got %s", s)
+	}
+	if r.Exists("ignored") {
+		t.Fatalf("expected templates with other extensions to be ignored")
+	}
+}
+
+func TestNestedLayouts(t *testing.T) {
+	r := New(&Options{
+		Dir:    filepath.Join("testdata", "nested"),
+		Layout: "layouts/application",
+	})
+
+	t.Run("render content blocks in the layout", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		if err := r.HTML(b, nil, "index", map[string]interface{}{"title": "Home"}); err != nil {
+			t.Fatal(err)
+		}
+		expected := "<html><nav><a href=\"/users\">Users</a></nav>\n<h1>Home</h1>\n</html>"
+		if s := strings.TrimSpace(b.String()); s != expected {
+			t.Fatalf("expected %s but got %s", expected, s)
+		}
+	})
+
+	t.Run("render a layout in its parent layout", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		if err := r.HTML(b, nil, "index", map[string]interface{}{"title": "Users"}, RenderOptions{Layout: "admin"}); err != nil {
+			t.Fatal(err)
+		}
+		expected := "<html><nav><a href=\"/users\">Users</a></nav><div class=\"admin\">\n<h1>Users</h1>\n</div>\n</html>"
+		if s := strings.TrimSpace(b.String()); s != expected {
+			t.Fatalf("expected %s but got %s", expected, s)
+		}
+	})
+
+	t.Run("render an empty block", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		if err := r.HTML(b, nil, "plain", nil); err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.TrimSpace(b.String()); s != "<html><nav></nav><h1>plain</h1>\n</html>" {
+			t.Fatalf("expected an empty sidebar but got %s", s)
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	r := New(&Options{
+		Dir: filepath.Join("testdata", "cache"),
+		Funcs: []ContextualFuncMap{
+			func(w http.ResponseWriter, r *http.Request) template.FuncMap {
+				return map[string]interface{}{
+					"csrf": func() template.HTML {
+						return `<input type="hidden" name="gorilla.csrf.Token" value="token">`
+					},
+				}
+			},
+		},
+		Uncacheable: []string{"csrf"},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	t.Run("reuse the output of a cache block", func(t *testing.T) {
+		for _, name := range []string{"Ada", "Grace"} {
+			b := &bytes.Buffer{}
+			if err := r.HTML(b, nil, "greeting", map[string]interface{}{"name": name}, RenderOptions{SkipLayout: true}); err != nil {
+				t.Fatal(err)
+			}
+			if s := strings.TrimSpace(b.String()); s != "<p>Hello Ada</p>" {
+				t.Fatalf("expected the cached greeting but got %s", s)
+			}
+		}
+	})
+
+	t.Run("cache blocks within a range", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		items := map[string]interface{}{"items": []string{"a", "b"}}
+		if err := r.HTML(b, nil, "items", items, RenderOptions{SkipLayout: true}); err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.TrimSpace(b.String()); s != "<ul><li>a</li><li>b</li></ul>" {
+			t.Fatalf("expected each item to be rendered but got %s", s)
+		}
+	})
+
+	t.Run("refuse to cache uncacheable funcs", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		err := r.HTML(rr, req, "form", nil, RenderOptions{SkipLayout: true})
+		if err == nil || !strings.Contains(err.Error(), "calls csrf") {
+			t.Fatalf("expected an error for the csrf func but got %v", err)
+		}
+		if rr.Body.Len() != 0 {
+			t.Fatalf("expected nothing to be written but got %s", rr.Body.String())
+		}
+	})
+}
+
+// stubEngine renders templates as their name, layout, and data.
+type stubEngine struct{}
+
+func (stubEngine) Render(w io.Writer, name, layout string, data map[string]interface{}, funcs template.FuncMap) error {
+	if name == "missing" {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := io.WriteString(w, name+" in "+layout+" with "+data["name"].(string))
+	return err
+}
+
+func (stubEngine) Exists(name string) bool {
+	return name != "missing"
+}
+
+func TestEngine(t *testing.T) {
+	r := New(&Options{Engine: stubEngine{}, Layout: "layout"})
+
+	t.Run("render with the engine", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		if err := r.HTML(rr, nil, "users/show", map[string]interface{}{"name": "Ada"}, RenderOptions{Layout: "admin"}); err != nil {
+			t.Fatal(err)
+		}
+		if s := rr.Body.String(); s != "users/show in layouts/admin with Ada" {
+			t.Fatalf("expected the engine's output but got %s", s)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("expected text/html but got %s", ct)
+		}
+	})
+
+	t.Run("render without a layout", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		if err := r.HTML(rr, nil, "users/_row", map[string]interface{}{"name": "Ada"}, RenderOptions{SkipLayout: true}); err != nil {
+			t.Fatal(err)
+		}
+		if s := rr.Body.String(); s != "users/_row in  with Ada" {
+			t.Fatalf("expected no layout but got %s", s)
+		}
+	})
+
+	t.Run("engine errors are returned", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		if err := r.HTML(rr, nil, "missing", nil); err == nil {
+			t.Fatal("expected an error")
+		}
+		if rr.Body.Len() != 0 {
+			t.Fatalf("expected nothing to be written but got %s", rr.Body.String())
+		}
+	})
+}
+
+func TestFormats(t *testing.T) {
+	r := New(&Options{
+		Dir:        filepath.Join("testdata", "formats"),
+		Layout:     "layout",
+		Extensions: []string{".html", ".tmpl"},
+	})
+	data := map[string]interface{}{"Name": "<Ada>", "Admin": true}
+
+	for _, tt := range []struct {
+		accept      string
+		opts        RenderOptions
+		contentType string
+		body        string
+	}{
+		{"text/html,application/xhtml+xml,*/*;q=0.8", RenderOptions{}, "text/html; charset=UTF-8", "<html><h1>&lt;Ada&gt;</h1>\n</html>"},
+		{"application/json", RenderOptions{}, "application/json; charset=UTF-8", `{"name": "\u003cAda\u003e", "admin": true}`},
+		{"text/html;q=0.5, application/json", RenderOptions{}, "application/json; charset=UTF-8", `{"name": "\u003cAda\u003e", "admin": true}`},
+		{"text/csv", RenderOptions{}, "text/html; charset=UTF-8", "<html><h1>&lt;Ada&gt;</h1>\n</html>"},
+		{"text/html", RenderOptions{Format: "json"}, "application/json; charset=UTF-8", `{"name": "\u003cAda\u003e", "admin": true}`},
+	} {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+			if err := r.HTML(rr, req, "show", data, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != tt.contentType {
+				t.Fatalf("expected %s but got %s", tt.contentType, contentType)
+			}
+			if body := strings.TrimSpace(rr.Body.String()); body != tt.body {
+				t.Fatalf("expected %s but got %s", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	r := New(&Options{
+		Dir:    filepath.Join("testdata", "overrides"),
+		Layout: "layout",
+	})
+
+	for _, tt := range []struct {
+		tenant   string
+		locale   string
+		expected string
+	}{
+		{"", "", "<html><h1>Welcome</h1>\n</html>"},
+		{"", "fr", "<html><h1>Bienvenue</h1>\n</html>"},
+		{"", "fr-CA", "<html><h1>Bienvenue</h1>\n</html>"},
+		{"", "de", "<html><h1>Welcome</h1>\n</html>"},
+		{"acme", "en", "<main><h1>Welcome to Acme</h1>\n</main>"},
+		{"acme", "fr", "<main><h1>Welcome to Acme</h1>\n</main>"},
+		{"globex", "en", "<html><h1>Welcome</h1>\n</html>"},
+	} {
+		t.Run(tt.tenant+" "+tt.locale, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := r.HTML(b, nil, "index", nil, RenderOptions{Tenant: tt.tenant, Locale: tt.locale}); err != nil {
+				t.Fatal(err)
+			}
+			if s := strings.TrimSpace(b.String()); s != tt.expected {
+				t.Fatalf("expected %s but got %s", tt.expected, s)
+			}
+		})
+	}
+}
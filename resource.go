@@ -0,0 +1,93 @@
+package seatbelt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The following interfaces are implemented by controllers passed to
+// App.Resource. Each method is optional: only the routes for the methods that
+// a controller implements are registered.
+type (
+	// An Indexer handles GET /resources.
+	Indexer interface{ Index(c *Context) error }
+
+	// A Newer handles GET /resources/new.
+	Newer interface{ New(c *Context) error }
+
+	// A Creator handles POST /resources.
+	Creator interface{ Create(c *Context) error }
+
+	// A Shower handles GET /resources/{id}.
+	Shower interface{ Show(c *Context) error }
+
+	// An Editor handles GET /resources/{id}/edit.
+	Editor interface{ Edit(c *Context) error }
+
+	// An Updater handles PUT and PATCH /resources/{id}.
+	Updater interface{ Update(c *Context) error }
+
+	// A Destroyer handles DELETE /resources/{id}.
+	Destroyer interface{ Destroy(c *Context) error }
+)
+
+// Resource registers the conventional RESTful routes for the given controller
+// at the given path, similar to Rails' `resources :posts`. For example,
+//
+//	app.Resource("/posts", &PostsController{})
+//
+// registers the following routes, provided that PostsController implements
+// the corresponding method:
+//
+//	GET    /posts           Index
+//	GET    /posts/new       New
+//	POST   /posts           Create
+//	GET    /posts/{id}      Show
+//	GET    /posts/{id}/edit Edit
+//	PUT    /posts/{id}      Update
+//	PATCH  /posts/{id}      Update
+//	DELETE /posts/{id}      Destroy
+//
+// The ID of the resource is available to handlers via c.PathParam("id").
+//
+// Resource panics if the controller doesn't implement any of the methods.
+func (a *App) Resource(path string, controller interface{}) {
+	path = strings.TrimSuffix(path, "/")
+	member := path + "/{id}"
+
+	var registered bool
+
+	if ctrl, ok := controller.(Indexer); ok {
+		a.Get(path, ctrl.Index)
+		registered = true
+	}
+	if ctrl, ok := controller.(Newer); ok {
+		a.Get(path+"/new", ctrl.New)
+		registered = true
+	}
+	if ctrl, ok := controller.(Creator); ok {
+		a.Post(path, ctrl.Create)
+		registered = true
+	}
+	if ctrl, ok := controller.(Shower); ok {
+		a.Get(member, ctrl.Show)
+		registered = true
+	}
+	if ctrl, ok := controller.(Editor); ok {
+		a.Get(member+"/edit", ctrl.Edit)
+		registered = true
+	}
+	if ctrl, ok := controller.(Updater); ok {
+		a.Put(member, ctrl.Update)
+		a.Patch(member, ctrl.Update)
+		registered = true
+	}
+	if ctrl, ok := controller.(Destroyer); ok {
+		a.Delete(member, ctrl.Destroy)
+		registered = true
+	}
+
+	if !registered {
+		panic(fmt.Sprintf("seatbelt: controller %T for resource '%s' does not implement any resource methods", controller, path))
+	}
+}
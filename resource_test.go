@@ -0,0 +1,106 @@
+package seatbelt
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type postsController struct{}
+
+func (postsController) Index(c *Context) error { return c.String(200, "index") }
+func (postsController) New(c *Context) error   { return c.String(200, "new") }
+func (postsController) Show(c *Context) error  { return c.String(200, "show "+c.PathParam("id")) }
+func (postsController) Edit(c *Context) error  { return c.String(200, "edit "+c.PathParam("id")) }
+func (postsController) Create(c *Context) error {
+	return c.String(200, "create")
+}
+func (postsController) Update(c *Context) error {
+	return c.String(200, c.Request().Method+" update "+c.PathParam("id"))
+}
+func (postsController) Destroy(c *Context) error {
+	return c.String(200, "destroy "+c.PathParam("id"))
+}
+
+func TestResource(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+	app.Resource("/posts", postsController{})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{method: http.MethodGet, path: "/posts", status: 200, body: "index"},
+		{method: http.MethodGet, path: "/posts/new", status: 200, body: "new"},
+		{method: http.MethodGet, path: "/posts/1", status: 200, body: "show 1"},
+		{method: http.MethodGet, path: "/posts/1/edit", status: 200, body: "edit 1"},
+
+		// Resource routes are protected from CSRF like any other route.
+		{method: http.MethodPost, path: "/posts", status: 403},
+		{method: http.MethodPut, path: "/posts/1", status: 403},
+		{method: http.MethodPatch, path: "/posts/1", status: 403},
+		{method: http.MethodDelete, path: "/posts/1", status: 403},
+	}
+
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			req, err := http.NewRequest(c.method, srv.URL+c.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d but got %d", c.status, resp.StatusCode)
+			}
+			if c.body == "" {
+				return
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != c.body {
+				t.Fatalf("expected %s but got %s", c.body, data)
+			}
+		})
+	}
+
+	for _, c := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPost, path: "/posts", body: "create"},
+		{method: http.MethodPut, path: "/posts/1", body: "PUT update 1"},
+		{method: http.MethodPatch, path: "/posts/1", body: "PATCH update 1"},
+		{method: http.MethodDelete, path: "/posts/1", body: "destroy 1"},
+	} {
+		t.Run(c.method+" "+c.path+" without the CSRF check", func(t *testing.T) {
+			resp := app.Invoke(c.method, c.path, nil, InvokeOptions{SkipCSRF: true})
+			if resp.StatusCode != http.StatusOK || resp.String() != c.body {
+				t.Fatalf("expected %s but got %d %s", c.body, resp.StatusCode, resp.String())
+			}
+		})
+	}
+
+	t.Run("a controller without resource methods should panic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+		app.Resource("/empty", struct{}{})
+	})
+}
@@ -0,0 +1,112 @@
+package seatbelt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseStats contains size accounting for the current request.
+type ResponseStats struct {
+	// The HTTP status code written so far, or zero if nothing has been
+	// written yet.
+	Status int
+
+	// The number of response body bytes written so far.
+	BytesWritten int64
+
+	// The number of request body bytes read so far.
+	RequestBytes int64
+}
+
+// responseWriter wraps an http.ResponseWriter in order to record the status
+// code and the number of bytes written.
+//
+// beforeWrite, if set, is called once before the response header is written.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+
+	beforeWrite func()
+}
+
+// writingHeader calls beforeWrite if the header hasn't been written yet.
+func (rw *responseWriter) writingHeader() {
+	if fn := rw.beforeWrite; fn != nil {
+		rw.beforeWrite = nil
+		fn()
+	}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.writingHeader()
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.writingHeader()
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying response writer does.
+func (rw *responseWriter) Flush() {
+	rw.writingHeader()
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying response writer does.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("seatbelt: response writer does not implement http.Hijacker")
+	}
+	if rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// requestBody wraps a request body in order to record the number of bytes
+// read.
+type requestBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (rb *requestBody) Read(p []byte) (int, error) {
+	n, err := rb.ReadCloser.Read(p)
+	rb.read += int64(n)
+	return n, err
+}
+
+// ResponseStats returns the status code and number of bytes written to the
+// response so far, along with the number of request body bytes read.
+func (c *context) ResponseStats() ResponseStats {
+	stats := ResponseStats{}
+	if c.stats != nil {
+		stats.Status = c.stats.status
+		stats.BytesWritten = c.stats.written
+	}
+	if c.body != nil {
+		stats.RequestBytes = c.body.read
+	}
+	return stats
+}
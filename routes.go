@@ -0,0 +1,168 @@
+package seatbelt
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// A Route is a route registered on an application. It can be given a name in
+// order to generate URLs for it with URLFor.
+type Route struct {
+	app     *App
+	method  string
+	path    string
+	pattern string
+
+	// The constraints on the route's path params and requests, which
+	// are checked before its handler is called.
+	params     map[string][]func(value string) bool
+	conditions []func(r *http.Request) bool
+
+	skipCSRF  bool
+	noTimeout bool
+}
+
+// Name names the route, so that its URL can be generated with URLFor, i.e.,
+//
+//	app.Get("/users/{id}", showUser).Name("user")
+//
+// Name panics if a route with the same name has already been registered.
+func (r *Route) Name(name string) *Route {
+	r.app.routes.add(name, r.path)
+	return r
+}
+
+// routes holds all registered routes, and the paths of all named routes.
+type routes struct {
+	mu          sync.RWMutex
+	paths       map[string]string
+	registered  map[string]*Route
+	constraints *constraints
+}
+
+// newRoutes returns an empty route registry.
+func newRoutes() *routes {
+	return &routes{
+		paths:       make(map[string]string),
+		registered:  make(map[string]*Route),
+		constraints: newConstraints(),
+	}
+}
+
+// routeKey returns the key that identifies a route by its host, method, and
+// path pattern.
+func routeKey(host, method, pattern string) string {
+	return host + " " + method + " " + pattern
+}
+
+// register adds the given route to the registry.
+func (rs *routes) register(r *Route) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.registered[routeKey(r.app.host, r.method, r.pattern)] = r
+}
+
+// lookup returns the registered route with the given host, method, and path
+// pattern, or nil if there isn't one.
+func (rs *routes) lookup(host, method, pattern string) *Route {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.registered[routeKey(host, method, pattern)]
+}
+
+// add adds a named route with the given path.
+func (rs *routes) add(name, path string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if existing, ok := rs.paths[name]; ok {
+		panic(fmt.Sprintf("seatbelt: route name '%s' is already used by '%s'", name, existing))
+	}
+	rs.paths[name] = path
+}
+
+// urlFor generates the URL for the route with the given name.
+func (rs *routes) urlFor(name string, pairs ...interface{}) (string, error) {
+	rs.mu.RLock()
+	path, ok := rs.paths[name]
+	rs.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("seatbelt: no route named '%s'", name)
+	}
+
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("seatbelt: odd number of key value pairs given for route '%s'", name)
+	}
+
+	params := make(map[string]string, len(pairs)/2)
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		params[key] = fmt.Sprint(pairs[i+1])
+		keys = append(keys, key)
+	}
+
+	// Substitute each `{param}` or `{param:regexp}` segment of the path with
+	// its value.
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		if start == -1 {
+			b.WriteString(path)
+			break
+		}
+		end := paramEnd(path, start)
+		if end == -1 {
+			return "", fmt.Errorf("seatbelt: route '%s' has an unterminated path param", name)
+		}
+
+		key := path[start+1 : end]
+		if i := strings.IndexByte(key, ':'); i != -1 {
+			key = key[:i]
+		}
+
+		value, ok := params[key]
+		if !ok {
+			return "", fmt.Errorf("seatbelt: missing path param '%s' for route '%s'", key, name)
+		}
+		delete(params, key)
+
+		b.WriteString(path[:start])
+		b.WriteString(url.PathEscape(value))
+		path = path[end+1:]
+	}
+
+	// Any pairs that aren't path params are added as query params, in the
+	// order in which they were given.
+	var query []string
+	for _, key := range keys {
+		if value, ok := params[key]; ok {
+			query = append(query, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	if len(query) > 0 {
+		b.WriteString("?" + strings.Join(query, "&"))
+	}
+
+	return b.String(), nil
+}
+
+// URLFor returns the URL of the route with the given name. The pairs are
+// alternating keys and values that are substituted for the route's path
+// params, and any keys that aren't path params are added to the query string,
+// i.e.,
+//
+//	app.Get("/users/{id}", showUser).Name("user")
+//	app.URLFor("user", "id", 42, "tab", "posts") // "/users/42?tab=posts"
+//
+// The same URLs can be generated in templates with the "urlFor" func:
+//
+//	<a href="{{ urlFor "user" "id" .User.ID }}">Profile</a>
+func (a *App) URLFor(name string, pairs ...interface{}) (string, error) {
+	return a.routes.urlFor(name, pairs...)
+}
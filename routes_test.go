@@ -0,0 +1,67 @@
+package seatbelt
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestURLFor(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	noop := func(c *Context) error { return c.NoContent() }
+
+	app.Get("/", noop).Name("root")
+	app.Get("/users/{id}", noop).Name("user")
+	app.Get("/users/{id:[0-9]+}/posts/{slug}", noop).Name("user_post")
+	app.Get("/archive/{year:[0-9]{4}}/{month}", noop).Name("archive")
+	app.Namespace("/admin", func(app *App) {
+		app.Get("/users/{id}", noop).Name("admin_user")
+	})
+	app.Get("/current", func(c *Context) error {
+		return c.String(200, c.URLFor("user", "id", 7))
+	})
+
+	cases := []struct {
+		name  string
+		pairs []interface{}
+		url   string
+	}{
+		{name: "root", url: "/"},
+		{name: "user", pairs: []interface{}{"id", 42}, url: "/users/42"},
+		{name: "user", pairs: []interface{}{"id", 42, "tab", "posts"}, url: "/users/42?tab=posts"},
+		{name: "user_post", pairs: []interface{}{"id", 1, "slug", "hello world"}, url: "/users/1/posts/hello%20world"},
+		{name: "admin_user", pairs: []interface{}{"id", 1}, url: "/admin/users/1"},
+		{name: "archive", pairs: []interface{}{"year", 2024, "month", 5}, url: "/archive/2024/5"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.url, func(t *testing.T) {
+			url, err := app.URLFor(c.name, c.pairs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != c.url {
+				t.Fatalf("expected %s but got %s", c.url, url)
+			}
+		})
+	}
+
+	t.Run("missing path params return an error", func(t *testing.T) {
+		if _, err := app.URLFor("user"); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("unknown routes return an error", func(t *testing.T) {
+		if _, err := app.URLFor("unknown"); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("URLFor from a request context", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/current", nil)
+		if resp.String() != "/users/7" {
+			t.Fatalf("expected /users/7 but got %s", resp.String())
+		}
+	})
+}
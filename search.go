@@ -0,0 +1,60 @@
+package seatbelt
+
+import (
+	"fmt"
+
+	"github.com/go-seatbelt/seatbelt/handler"
+)
+
+// SearchParams assigns the query parameters of the request to the given
+// struct, i.e., the filters, sort order, and page of a list page. Fields keep
+// their value if their parameter isn't in the query, so defaults can be set
+// first, and Enumerable fields restrict the allowed values, i.e.,
+//
+//	type UserSearch struct {
+//		Query string   `params:"q"`
+//		Role  []Role   `params:"role"`
+//		Sort  UserSort `params:"sort"`
+//		Page  int      `params:"page"`
+//	}
+//
+//	search := UserSearch{Sort: "name", Page: 1}
+//	if err := c.SearchParams(&search); err != nil {
+//		return err
+//	}
+//
+// Templates link to the same page with some of the params changed with the
+// "linkWithParams" template func, which returns a canonical URL with the
+// params in a stable order, i.e.,
+//
+//	<a href="{{ linkWithParams .Search "page" 2 }}">Next</a>
+func (c *context) SearchParams(v interface{}) error {
+	return handler.Query(c.r, v, handler.ParamsOptions{
+		FieldNaming: c.fieldNaming,
+	})
+}
+
+// linkWithParams returns the given path with the given search params as its
+// query, with the params in the given key and value pairs replaced. A nil or
+// empty value removes the param.
+func linkWithParams(path string, params interface{}, naming handler.FieldNaming, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("seatbelt: linkWithParams requires key and value pairs, but got %d arguments", len(pairs))
+	}
+
+	query := handler.QueryValues(params, handler.ParamsOptions{FieldNaming: naming})
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		value := pairs[i+1]
+		if value == nil || value == "" {
+			query.Del(key)
+			continue
+		}
+		query.Set(key, fmt.Sprint(value))
+	}
+
+	if encoded := query.Encode(); encoded != "" {
+		return path + "?" + encoded, nil
+	}
+	return path, nil
+}
@@ -0,0 +1,40 @@
+package seatbelt
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSearchParams(t *testing.T) {
+	type search struct {
+		Query string `params:"q"`
+		Sort  string `params:"sort"`
+		Page  int    `params:"page"`
+	}
+
+	app := New(Option{SkipServeFiles: true})
+
+	var got search
+	var link string
+	app.Get("/users", func(c *Context) error {
+		got = search{Sort: "name", Page: 1}
+		if err := c.SearchParams(&got); err != nil {
+			return err
+		}
+
+		var err error
+		link, err = linkWithParams(c.Request().URL.Path, got, nil, "page", got.Page+1, "q", "")
+		return err
+	})
+
+	resp := app.Invoke(http.MethodGet, "/users?page=2&q=ada", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	if got.Query != "ada" || got.Sort != "name" || got.Page != 2 {
+		t.Fatalf("expected the query to be bound over the defaults but got %+v", got)
+	}
+	if expected := "/users?page=3&sort=name"; link != expected {
+		t.Fatalf("expected %s but got %s", expected, link)
+	}
+}
@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-seatbelt/seatbelt/captcha"
 	"github.com/go-seatbelt/seatbelt/handler"
 	"github.com/go-seatbelt/seatbelt/i18n"
 	"github.com/go-seatbelt/seatbelt/render"
@@ -43,6 +49,36 @@ type context struct {
 	values   *values.Values
 	session  *session.Session
 	renderer *render.Render
+	captcha  *captcha.Captcha
+	routes   *routes
+
+	// The dark-launched candidates of templates.
+	comparisons *comparisons
+
+	// The render timings, or nil if metrics are disabled.
+	metrics *metrics
+
+	// The naming used for struct fields in params and JSON, and how JSON
+	// responses are encoded.
+	fieldNaming handler.FieldNaming
+	jsonOptions handler.JSONOptions
+
+	// Size accounting for the request and response.
+	stats *responseWriter
+	body  *requestBody
+
+	// Render timings for slow request reporting.
+	trace               *trace
+	slowRenderThreshold time.Duration
+
+	// The layout of the namespace serving the request, if any.
+	layout string
+
+	// Returns the tenant whose template overrides are rendered, if any.
+	templateTenant func(r *http.Request) string
+
+	// Renders the application's error page for the given status.
+	errorPage func(c *Context, status int, message string, err error)
 }
 
 type ContextI18N context
@@ -81,11 +117,84 @@ func (c *ContextSession) Set(key string, value interface{}) {
 	c.session.Set(c.w, c.r, key, value)
 }
 
+// SetWithTTL sets or updates the given value on the session, deleting it
+// once the given ttl has passed.
+func (c *ContextSession) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.session.SetWithTTL(c.w, c.r, key, value, ttl)
+}
+
+// Expire sets how long the session lasts, overriding Option.SessionMaxAge,
+// i.e., 30 days for a "remember me" login. A ttl of 0 or less makes the
+// session expire when the browser is closed.
+func (c *ContextSession) Expire(ttl time.Duration) {
+	c.session.Expire(c.w, c.r, ttl)
+}
+
 // Get returns the value associated with the given key in the request session.
 func (c *ContextSession) Get(key string) interface{} {
 	return c.session.Get(c.r, key)
 }
 
+// GetString returns the session value with the given key if it is a string,
+// or an empty string if it isn't.
+func (c *ContextSession) GetString(key string) string {
+	v, _ := session.GetAs[string](c.session, c.r, key)
+	return v
+}
+
+// GetInt returns the session value with the given key if it is an integer,
+// or 0 if it isn't.
+func (c *ContextSession) GetInt(key string) int {
+	switch v := c.Get(key).(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case uint:
+		return int(v)
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		return int(v)
+	case uint64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// GetBool returns the session value with the given key if it is a bool, or
+// false if it isn't.
+func (c *ContextSession) GetBool(key string) bool {
+	v, _ := session.GetAs[bool](c.session, c.r, key)
+	return v
+}
+
+// GetTime returns the session value with the given key if it is a
+// time.Time, or the zero time if it isn't.
+func (c *ContextSession) GetTime(key string) time.Time {
+	v, _ := session.GetAs[time.Time](c.session, c.r, key)
+	return v
+}
+
+// SessionAs returns the session value with the given key as a T, i.e.,
+//
+//	user, ok := seatbelt.SessionAs[User](c.Session, "user")
+//
+// It returns false if there is no such value, or if the value isn't a T.
+// Custom types must be registered with session.Register.
+func SessionAs[T any](c *ContextSession, key string) (T, bool) {
+	return session.GetAs[T](c.session, c.r, key)
+}
+
 // List returns all key value pairs of session data from the given request.
 func (c *ContextSession) List() map[string]interface{} {
 	return c.session.List(c.r)
@@ -102,20 +211,69 @@ func (c *ContextSession) Reset() {
 	c.session.Reset(c.w, c.r)
 }
 
+// Regenerate re-issues the session cookie, and rotates the session ID when
+// sessions are saved in a store, keeping all values. Call it after a user
+// logs in to prevent session fixation.
+func (c *ContextSession) Regenerate() {
+	c.session.Regenerate(c.w, c.r)
+}
+
+// A Flash is a message that is shown once, with a level, i.e., "notice" or
+// "error", and optional metadata. Templates can range over the flash
+// messages with the "flashes" func:
+//
+//	{{ range flashes }}<p class="{{ .Level }}">{{ .Message }}</p>{{ end }}
+type Flash = session.Flash
+
 type ContextFlash context
 
-// Flash adds a flash message on a request.
-func (c *ContextFlash) Add(key string, value interface{}) {
-	c.session.Flash(c.w, c.r, key, value)
+// Add adds a flash message with the given level, and the given value
+// formatted as its message.
+func (c *ContextFlash) Add(level string, value interface{}) {
+	c.session.Flash(c.w, c.r, level, value)
+}
+
+// Push adds the given flash message, i.e., to include metadata.
+func (c *ContextFlash) Push(f Flash) {
+	c.session.AddFlash(c.w, c.r, f)
+}
+
+// Notice adds a flash message with the "notice" level.
+func (c *ContextFlash) Notice(message string) {
+	c.Add(session.FlashNotice, message)
+}
+
+// Alert adds a flash message with the "alert" level.
+func (c *ContextFlash) Alert(message string) {
+	c.Add(session.FlashAlert, message)
+}
+
+// Error adds a flash message with the "error" level.
+func (c *ContextFlash) Error(message string) {
+	c.Add(session.FlashError, message)
+}
+
+// Success adds a flash message with the "success" level.
+func (c *ContextFlash) Success(message string) {
+	c.Add(session.FlashSuccess, message)
+}
+
+// Now adds a flash message that is only shown during the current request,
+// i.e., when re-rendering a form with validation errors, so that it doesn't
+// carry over to the next request.
+func (c *ContextFlash) Now(level string, value interface{}) {
+	c.session.FlashNow(c.r, level, value)
 }
 
 // List returns all flash messages, clearing all saved flashes.
-func (c *ContextFlash) List() map[string]interface{} {
+func (c *ContextFlash) List() []Flash {
 	return c.session.Flashes(c.w, c.r)
 }
 
 func (c *context) Params(v interface{}) error {
-	return handler.Params(c.w, c.r, ChiPathParamFunc, v)
+	return handler.Params(c.w, c.r, ChiPathParamFunc, v, handler.ParamsOptions{
+		FieldNaming: c.fieldNaming,
+	})
 }
 
 func (c *context) Redirect(url string) error {
@@ -133,14 +291,39 @@ func (c *context) FormValue(name string) string {
 	return c.r.FormValue(name)
 }
 
+// URLFor returns the URL of the route with the given name, with the given
+// key value pairs substituted for its path params. See App.URLFor.
+//
+// URLFor panics if the URL cannot be generated, as that is always a
+// programming error.
+func (c *context) URLFor(name string, pairs ...interface{}) string {
+	url, err := c.routes.urlFor(name, pairs...)
+	if err != nil {
+		panic(err)
+	}
+	return url
+}
+
 // QueryParam returns the URL query parameter with the given name.
 func (c *context) QueryParam(name string) string {
 	return c.r.URL.Query().Get(name)
 }
 
-// JSON renders a JSON response with the given status code and data.
-func (c *context) JSON(code int, v interface{}) error {
-	return handler.JSON(c.w, code, v)
+// JSON renders a JSON response with the given status code and data. The
+// response is encoded with Option.JSON, unless other options are given, i.e.,
+//
+//	return c.JSON(http.StatusOK, export, handler.JSONOptions{Stream: true})
+//
+// Options without a FieldNaming use that of the application.
+func (c *context) JSON(code int, v interface{}, opts ...handler.JSONOptions) error {
+	o := c.jsonOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.FieldNaming == nil {
+		o.FieldNaming = c.fieldNaming
+	}
+	return handler.JSON(c.w, code, v, o)
 }
 
 // String sends a string response with the given status code.
@@ -158,21 +341,44 @@ func (c *context) NoContent() error {
 	return nil
 }
 
-// GetIP attempts to return the request's IP address, first by checking the
-// `X-Real-Ip` header, then the `X-Forwarded-For` header, and finally falling
-// back to the request's `RemoteAddr`.
+// GetIP returns the request's IP address, i.e., its `RemoteAddr`.
+//
+// The `X-Real-Ip` and `X-Forwarded-For` headers are never read, as clients
+// can set them to any address. Applications behind a load balancer should
+// configure TrustedProxies instead, which resolves these headers to the
+// client's address before the request is handled.
 func (c *context) GetIP() string {
-	if ip := c.r.Header.Get("X-Real-Ip"); ip != "" {
-		return ip
+	return c.r.RemoteAddr
+}
+
+// VerifyCaptcha verifies the CAPTCHA response submitted with the request. If
+// the challenge was not solved, it returns an *Error with the status 422
+// Unprocessable Entity that wraps captcha.ErrVerificationFailed, so that
+// returning the error from a handler flashes "Please solve the CAPTCHA and
+// try again." back to the form. Other errors mean that the provider could
+// not be reached, and are handled like any unexpected error.
+//
+// VerifyCaptcha panics if the application was not configured with a CAPTCHA
+// provider.
+func (c *context) VerifyCaptcha() error {
+	if c.captcha == nil {
+		panic("seatbelt: VerifyCaptcha called without configuring Option.Captcha")
 	}
-	if ip := c.r.Header.Get("X-Forwarded-For"); ip != "" {
-		return ip
+
+	ip := c.GetIP()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
 	}
-	return c.r.RemoteAddr
+	err := c.captcha.Verify(c.r, ip)
+	if errors.Is(err, captcha.ErrVerificationFailed) {
+		return &Error{Err: err, Code: http.StatusUnprocessableEntity, message: "Please solve the CAPTCHA and try again."}
+	}
+	return err
 }
 
-// mergeMaps merges the values of m2 into m1. If a value in m2 has the same
-// key as in m1, the key in m1 takes precedence.
+// mergeMaps returns a new map with the values of m1 and m2. If a value in m2
+// has the same key as in m1, the key in m1 takes precedence. Neither map is
+// modified, so that the data of one render doesn't leak into the next.
 func mergeMaps(m1, m2 map[string]interface{}) map[string]interface{} {
 	if m1 == nil {
 		return m2
@@ -181,13 +387,15 @@ func mergeMaps(m1, m2 map[string]interface{}) map[string]interface{} {
 		return m1
 	}
 
+	merged := make(map[string]interface{}, len(m1)+len(m2))
 	for k, v := range m2 {
-		if _, ok := m1[k]; !ok {
-			m1[k] = v
-		}
+		merged[k] = v
+	}
+	for k, v := range m1 {
+		merged[k] = v
 	}
 
-	return m1
+	return merged
 }
 
 // Render renders an HTML template.
@@ -196,33 +404,103 @@ func mergeMaps(m1, m2 map[string]interface{}) map[string]interface{} {
 // be merged with the given data, with the data taking precendence in case of
 // key collisions.
 //
-// Render will never return an error, and only has the function signature as a
-// convenience for writing shorter handlers, for example,
+// Render returns an error if the template fails to execute, in which case
+// nothing is written, so that returning the error from the handler renders
+// the error page instead of a half-written one, for example,
 //
 //	func ShowNewUser(c *seatbelt.Context) error {
 //		return c.Render("users/new", nil)
 //	}
+//
+// It also returns an error if it has already been called during the same
+// request, as rendering a second page would append it to the first one.
 func (c *context) Render(name string, data map[string]interface{}, opts ...render.RenderOptions) error {
-	c.renderer.HTML(c.w, c.r, name, mergeMaps(c.values.List(), data), opts...)
-	return nil
+	if c.trace != nil {
+		if c.trace.rendered != "" {
+			return fmt.Errorf("seatbelt: cannot render %s, as %s has already been rendered in this request", name, c.trace.rendered)
+		}
+		c.trace.rendered = name
+	}
+
+	defer c.timeRender(name, time.Now())
+	var err error
+	if cmp, ok := c.comparisons.sample(name); ok {
+		err = c.renderCompared(cmp, name, mergeMaps(c.values.List(), data), c.renderOptions(opts))
+	} else {
+		err = c.renderer.HTML(c.w, c.r, name, mergeMaps(c.values.List(), data), c.renderOptions(opts))
+	}
+	if err != nil && c.trace != nil && c.ResponseStats().Status == 0 {
+		// Nothing was written, so the error page can still be rendered.
+		c.trace.rendered = ""
+	}
+	return err
 }
 
+// RenderPartial renders the HTML template with the given name without a
+// layout, i.e., to respond to a Turbo Frame or htmx request with a fragment
+// of HTML.
+//
+//	func ShowUserRow(c *seatbelt.Context) error {
+//		return c.RenderPartial("users/_row", data)
+//	}
+func (c *context) RenderPartial(name string, data map[string]interface{}, opts ...render.RenderOptions) error {
+	o := c.renderOptions(opts)
+	o.SkipLayout = true
+	return c.Render(name, data, o)
+}
+
+// renderOptions returns the last of the given render options, using the
+// layout of the namespace serving the request if no layout is given.
+func (c *context) renderOptions(opts []render.RenderOptions) render.RenderOptions {
+	var o render.RenderOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.Layout == "" {
+		o.Layout = c.layout
+	}
+	if o.Locale == "" {
+		o.Locale = c.i18n.Locale(c.r)
+	}
+	if o.Tenant == "" && c.templateTenant != nil {
+		o.Tenant = c.templateTenant(c.r)
+	}
+	return o
+}
+
+// responseStaller captures a rendered template. It has its own copy of the
+// response headers, so that the Content-Type and Content-Length of the
+// template aren't set on the response.
 type responseStaller struct {
-	w    http.ResponseWriter
-	code int
-	buf  *bytes.Buffer
+	header http.Header
+	code   int
+	w      io.Writer
 }
 
-func (rs *responseStaller) Write(b []byte) (int, error) { return rs.buf.Write(b) }
+func (rs *responseStaller) Write(b []byte) (int, error) { return rs.w.Write(b) }
 func (rs *responseStaller) WriteHeader(code int)        { rs.code = code }
-func (rs *responseStaller) Header() http.Header         { return rs.w.Header() }
+func (rs *responseStaller) Header() http.Header         { return rs.header }
 
 // RenderToBytes is the same as Render, but returns the rendered template as
-// a byte slice instead of writing diredtly to the response writer.
+// a byte slice instead of writing diredtly to the response writer. If the
+// template fails to execute, the error is logged and nil is returned.
 func (c *context) RenderToBytes(name string, data map[string]interface{}, opts ...render.RenderOptions) []byte {
-	rs := &responseStaller{w: c.Response(), buf: &bytes.Buffer{}}
-	c.renderer.HTML(rs, c.r, name, mergeMaps(c.values.List(), data), opts...)
-	return rs.buf.Bytes()
+	buf := &bytes.Buffer{}
+	if err := c.RenderToWriter(buf, name, data, opts...); err != nil {
+		log.Printf("seatbelt: %v", err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// RenderToWriter is the same as Render, but writes the rendered template to
+// the given writer instead of the response writer, i.e., to render the body
+// of an email. Pass render.RenderOptions{SkipLayout: true} to render the
+// template without a layout.
+func (c *context) RenderToWriter(w io.Writer, name string, data map[string]interface{}, opts ...render.RenderOptions) error {
+	defer c.timeRender(name, time.Now())
+	rs := &responseStaller{header: c.Response().Header().Clone(), w: w}
+	return c.renderer.HTML(rs, c.r, name, mergeMaps(c.values.List(), data), c.renderOptions(opts))
 }
 
 // Request returns the underlying *http.Request belonging to the current
@@ -268,11 +546,91 @@ type App struct {
 	i18n     *i18n.Translator
 	session  *session.Session
 	renderer *render.Render
+	captcha  *captcha.Captcha
+
+	// The data loaded for all templates with Preload.
+	preloaded *preloaded
+
+	// The dark-launched candidates of templates, registered with
+	// CompareRender.
+	comparisons *comparisons
+
+	// The assets referenced by the asset tag template funcs.
+	assets *assets
+
+	// The plugins registered when the application was created.
+	plugins []Plugin
+
+	// The in-flight requests and drain state, shared between all
+	// namespaces.
+	drainer *drainer
+
+	// The tasks registered with Task, shared between all namespaces.
+	tasks *tasks
+
+	// The resource usage thresholds, or nil if the watchdog is disabled.
+	watchdog *watchdog
+
+	// The request, render, and session timings, or nil if metrics are
+	// disabled.
+	metrics *metrics
+
+	// The servers started with StartListeners, shared between all
+	// namespaces.
+	servers *servers
+
+	// The layout templates are rendered in, set per namespace.
+	layout string
+
+	// Returns the tenant whose template overrides are rendered.
+	templateTenant func(r *http.Request) string
+
+	// The routes of the application, shared between all namespaces, the
+	// path prefix that this application is mounted on, and the host pattern
+	// it is constrained to.
+	routes *routes
+	prefix string
+	host   string
 
 	// The HTTP router and its configuration options.
 	mux          chi.Router
+	std          []func(http.Handler) http.Handler
+	hosts        []*hostRoute
+	parent       *App
 	middlewares  []MiddlewareFunc
 	errorHandler func(c *Context, err error)
+
+	// Whether error pages show the details of errors.
+	development bool
+
+	// The sensitive data redacted from logs and error reports.
+	redactor *redactor
+
+	// The cookie consent configuration, or nil if consent isn't managed.
+	consent *consentConfig
+
+	// The naming used for struct fields in params and JSON, and how JSON
+	// responses are encoded.
+	fieldNaming handler.FieldNaming
+	jsonOptions handler.JSONOptions
+
+	// Thresholds above which requests and renders are reported as slow.
+	slowRequestThreshold time.Duration
+	slowRenderThreshold  time.Duration
+	onSlowRequest        func(s SlowRequest)
+
+	// How long handlers may take before the request is answered with 503
+	// Service Unavailable, or 0 if they may take any time.
+	requestTimeout time.Duration
+
+	// How request paths that don't match a route are normalized.
+	normalization pathNormalization
+
+	// Whether a namespace ignores the middleware of its parent.
+	skipInheritMiddleware bool
+
+	// Whether the routes of the application skip CSRF validation.
+	skipCSRF bool
 }
 
 // MiddlewareFunc is the type alias for Seatbelt middleware.
@@ -283,6 +641,33 @@ type Option struct {
 	// The directory containing your HTML templates.
 	TemplateDir string
 
+	// TemplateFS serves HTML templates from the given file system instead of
+	// TemplateDir, i.e., an embed.FS, so that templates ship inside the
+	// binary. The templates must be at the root of the file system, so use
+	// fs.Sub for an embedded "templates" directory. In Reload mode,
+	// templates are still read from TemplateDir. Default is nil.
+	TemplateFS fs.FS
+
+	// TemplateTenant returns the tenant of the given request, i.e., the
+	// account of a white-label subdomain. c.Render renders the tenant's
+	// overrides of templates in the templates/<tenant> directory in place of
+	// the defaults. Overrides for the request's locale are named with the
+	// locale before the extension, i.e., "users/index.fr.html", and are
+	// always looked up. Default is nil, meaning there are no tenants.
+	TemplateTenant func(r *http.Request) string
+
+	// TemplateExtensions are the file extensions of templates, i.e.,
+	// ".gohtml". Default is ".html". Templates of other formats are named
+	// with their format before the extension, i.e., "users/show.json.tmpl"
+	// with ".tmpl" added to the extensions, and c.Render renders them when
+	// the request prefers that format.
+	TemplateExtensions []string
+
+	// TemplateEngine renders templates with an alternate template engine
+	// instead of html/template, i.e., templ or jet. Layouts, template funcs,
+	// and c.Render work the same. Default is nil.
+	TemplateEngine render.Engine
+
 	// The directory containing your i18n data.
 	LocaleDir string
 
@@ -296,19 +681,212 @@ type Option struct {
 	// max age.
 	SessionMaxAge int
 
+	// SessionStore saves session data on the server, so that the session
+	// cookie only holds the session ID, i.e., session.NewMemoryStore(),
+	// session.NewRedisStore, or session.NewSQLStore.
+	// Expired sessions should be removed periodically, see SessionGC.
+	// Default is nil, meaning session data is saved in the cookie.
+	SessionStore session.Store
+
+	// SessionGC periodically deletes expired sessions from the
+	// SessionStore. Default is to never delete them.
+	SessionGC SessionGCOptions
+
+	// SessionRegenerateKeys are the session keys that grant privileges,
+	// i.e., "user_id". Setting or deleting one of them regenerates the
+	// session, as with c.Session.Regenerate. Default is nil.
+	SessionRegenerateKeys []string
+
 	// Request-contextual HTML functions.
 	Funcs func(w http.ResponseWriter, r *http.Request) template.FuncMap
 
 	// Whether or not to reload templates on each request.
 	Reload bool
 
+	// Consent configures cookie consent for non-essential cookies. Default
+	// is no consent management, meaning every category is granted.
+	Consent ConsentOptions
+
+	// Redact configures the sensitive data redacted from logs and error
+	// reports. Default redacts passwords, tokens, and card numbers.
+	Redact RedactOptions
+
+	// Development shows the cause chain, route, params, and recent log lines
+	// on error pages. Do not enable it in production, as it exposes the
+	// application's internals. Default is false.
+	Development bool
+
 	// SkipServeFiles does not automatically serve static files from the
 	// project's /public directory when set to true. Default is false.
 	SkipServeFiles bool
 
+	// PublicFiles configures the caching headers sent for files served from
+	// the project's /public directory.
+	PublicFiles FileServerOptions
+
+	// Assets configures the "javascriptTag" and "stylesheetTag" template
+	// funcs, which reference assets in the project's /public directory by
+	// their fingerprinted name, with a subresource integrity hash, and
+	// their defer and preload policies.
+	Assets AssetOptions
+
 	// SkipCSRFPaths is used to skip the CSRF validation to POST, PUT, PATCH,
 	// DELETE, etc requests to paths that match one of the given paths.
 	SkipCSRFPaths []string
+
+	// CSRF configures the CSRF protection applied to all unsafe requests.
+	CSRF CSRFOptions
+
+	// CORS allows cross-origin requests to every route from the configured
+	// origins. Preflight requests are answered before CSRF validation.
+	// Default is nil, meaning cross-origin requests are only allowed for
+	// routes that use the CORS middleware.
+	CORS *CORSOptions
+
+	// ServeVersion serves the application's BuildInfo as JSON at
+	// "/__version" when set to true. Default is false.
+	ServeVersion bool
+
+	// SlowRequestThreshold is the duration above which a request is reported
+	// as slow. Default is 0, meaning requests are never reported.
+	SlowRequestThreshold time.Duration
+
+	// SlowRenderThreshold is the duration above which a template render is
+	// reported as slow, along with the request that rendered it. Default is
+	// 0, meaning renders are never reported.
+	SlowRenderThreshold time.Duration
+
+	// OnSlowRequest is called with slow requests instead of logging a
+	// warning, i.e., in order to forward them to an alerting integration.
+	OnSlowRequest func(s SlowRequest)
+
+	// RequestTimeout is how long a handler and its middleware may take. At
+	// the deadline, the request's context is cancelled, so that downstream
+	// calls made with it are abandoned, and the client is sent the error
+	// page for 503 Service Unavailable without waiting for the handler to
+	// return. The page is rendered without the application's middleware, as
+	// it may be what stalled. Responses that were already being written at
+	// the deadline are cut off instead, so routes that stream responses,
+	// i.e., with c.Stream, should be exempted with Route.NoTimeout.
+	// WebSocket routes are always exempt. Default is 0, meaning handlers
+	// may take any time.
+	RequestTimeout time.Duration
+
+	// FieldNaming is used to map struct fields without tags to params and
+	// JSON keys, i.e., handler.SnakeCase binds the field FirstName from the
+	// param "first_name", and serializes it back to JSON as "first_name".
+	// Default is nil, meaning fields are bound by their case-insensitive name
+	// and serialized with the encoding/json defaults.
+	FieldNaming handler.FieldNaming
+
+	// JSON configures how c.JSON encodes responses, i.e., to pretty print
+	// them or to use a faster encoder. Default is compact JSON encoded with
+	// encoding/json.
+	JSON handler.JSONOptions
+
+	// Compress gzips HTML, JSON, and other text responses, including static
+	// files, for clients that accept it. Responses that are already
+	// compressed, i.e., images and archives, are sent as is. Default is
+	// false.
+	Compress bool
+
+	// RedirectTrailingSlash permanently redirects requests to paths that
+	// don't match a route to the same path with the trailing slash added or
+	// removed, if that path matches a route. Default is false.
+	RedirectTrailingSlash bool
+
+	// MatchTrailingSlash routes requests to paths that don't match a route to
+	// the same path with the trailing slash added or removed, if that path
+	// matches a route, without redirecting. Default is false.
+	//
+	// There's no StrictSlash option, as routes are strict by default, i.e.,
+	// "/users/" doesn't match "/users". RedirectTrailingSlash behaves like
+	// gorilla/mux's StrictSlash(true), and MatchTrailingSlash serves both
+	// paths without a redirect.
+	MatchTrailingSlash bool
+
+	// CaseInsensitivePaths routes requests to paths that don't match a route
+	// to the lowercased path, if that path matches a route. Routes should be
+	// registered in lowercase. Default is false.
+	CaseInsensitivePaths bool
+
+	// TrustedProxies are the IP addresses and CIDR ranges, i.e.,
+	// "10.0.0.0/8", of the load balancers in front of the application. The
+	// Forwarded and X-Forwarded-* headers of requests from these addresses
+	// are used to set the request's RemoteAddr, Host, and scheme, and the
+	// headers are removed from requests from any other address, so that
+	// clients can't spoof their address. Default is nil, meaning headers are
+	// left as they are.
+	TrustedProxies []string
+
+	// ForceHTTPS permanently redirects requests made over plain HTTP to the
+	// same URL over HTTPS. The X-Forwarded-Proto header is honored, so that
+	// applications behind a TLS-terminating proxy aren't redirected in a
+	// loop. Default is false.
+	ForceHTTPS bool
+
+	// CanonicalHost permanently redirects requests for any other host to the
+	// given host, i.e., "example.com" to redirect "www.example.com". Default
+	// is an empty string, meaning requests are served for any host.
+	CanonicalHost string
+
+	// RedirectExemptPaths are never redirected by ForceHTTPS or
+	// CanonicalHost, along with any path below them, i.e., "/healthz" for a
+	// load balancer's health checks.
+	RedirectExemptPaths []string
+
+	// RenderHooks are called before and after each template and partial is
+	// rendered, i.e., in order to report render times to an APM.
+	RenderHooks render.Hooks
+
+	// RenderStreamThreshold is the size in bytes above which rendered HTML
+	// is streamed with chunked encoding, instead of being sent with a
+	// Content-Length. Default is 0, meaning a Content-Length is always sent.
+	RenderStreamThreshold int
+
+	// RenderETag sets a strong ETag computed from each page rendered with
+	// c.Render, so that the page isn't sent again when the client's cached
+	// copy is unchanged. The page is still rendered, so use c.FreshWhen to
+	// skip rendering altogether. Default is false.
+	RenderETag bool
+
+	// TemplateCache stores the output of cache blocks in templates, i.e.,
+	//
+	//	{{ cache "navbar" "10m" }}{{ partial "navbar" }}{{ end }}
+	//
+	// Cache blocks can't use the "csrf", "csrfMetaTags", "flashes", or
+	// "cspNonce" template funcs, or variables declared outside of them.
+	// Default is an in-memory cache.
+	TemplateCache render.Cache
+
+	// Captcha configures the CAPTCHA provider used by the "captcha" template
+	// func and Context.VerifyCaptcha. Default is nil, meaning CAPTCHAs are
+	// disabled.
+	Captcha *captcha.Options
+
+	// Metrics serves the application's request counts, latencies by route,
+	// and render and session timings in the Prometheus text format, i.e.,
+	// &seatbelt.MetricsOptions{}. Default is nil, meaning metrics are
+	// disabled.
+	//
+	// The metrics are served without authentication, on the same router as
+	// every other route, so their path must be excluded from public
+	// listeners, and only served on an internal one, i.e.,
+	//
+	//	app.StartListeners(
+	//		seatbelt.Listener{Addr: ":443", CertFile: "cert.pem", KeyFile: "key.pem", Exclude: []string{"/metrics"}},
+	//		seatbelt.Listener{Addr: "127.0.0.1:9090", Namespaces: []string{"/metrics"}},
+	//	)
+	Metrics *MetricsOptions
+
+	// Watchdog reports the application's heap, goroutine, and session
+	// counts, and warns when they exceed a threshold. Default is nil,
+	// meaning the watchdog is disabled.
+	//
+	// The report is only served if WatchdogOptions.Path is set. It's served
+	// without authentication, so as with Metrics, the path must be excluded
+	// from public listeners, and only served on an internal one.
+	Watchdog *WatchdogOptions
 }
 
 // setDefaults sets the default values for Seatbelt options.
@@ -330,7 +908,15 @@ func (o *Option) setDefaults() {
 // The "master.key" file is a secret and should be treated as such. It should
 // not be checked into your source code, and in production, the "SECRET"
 // environment variable should instead be used.
+//
+// When TestMode is enabled, a deterministic key is used instead, and no files
+// are read or written.
 func (o *Option) setMasterKey() {
+	if tm := currentTestMode(); tm != nil {
+		o.SigningKey = tm.key
+		return
+	}
+
 	if key := os.Getenv("SECRET"); key != "" {
 		o.SigningKey = key
 	}
@@ -355,42 +941,82 @@ func (o *Option) setMasterKey() {
 
 // defaultTemplateFuncs sets default HTML template functions on each request
 // context.
-func defaultTemplateFuncs(session *session.Session, translator *i18n.Translator) func(w http.ResponseWriter, r *http.Request) template.FuncMap {
-	return func(w http.ResponseWriter, r *http.Request) template.FuncMap {
-		return template.FuncMap{
-			"t": func(id string, data map[string]interface{}, pluralCount ...int) string {
-				vals := values.New(r).List()
-				return translator.T(r, id, mergeMaps(vals, data), pluralCount...)
-			},
-			"csrf": func() template.HTML {
-				return csrf.TemplateField(r)
-			},
-			"flashes": func() map[string]interface{} {
-				return session.Flashes(w, r)
-			},
-			// versionpath takes a filepath and returns the same filepath with
-			// a query parameter appended that contains the unix timestamp of
-			// that file's last modified time. This should be used for files
-			// that might change between page loads (JavaScript and CSS files,
-			// images, etc).
-			"versionpath": func(path string) string {
-				path = filepath.Clean(path)
-
-				// Leading `/` characters will just break local filepath
-				// resolution, so we remove it if it exists.
-				fi, err := os.Stat(strings.TrimPrefix(path, "/"))
-				if err == nil {
-					path = path + "?" + strconv.Itoa(int(fi.ModTime().Unix()))
-				} else {
-					fmt.Printf("seatbelt: error getting file info at path %s: %v\n", path, err)
-				}
+func (a *App) defaultTemplateFuncs(w http.ResponseWriter, r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"t": func(id string, data map[string]interface{}, pluralCount ...int) string {
+			vals := values.New(r).List()
+			return a.i18n.T(r, id, mergeMaps(vals, data), pluralCount...)
+		},
+		"csrf": func() template.HTML {
+			return csrfField(r)
+		},
+		"flashes": func() []Flash {
+			return a.session.Flashes(w, r)
+		},
+		// consent returns the user's cookie consent choices.
+		"consent": func() *Consent {
+			return consentFromRequest(r)
+		},
+		// versionpath takes a filepath and returns the same filepath with
+		// a query parameter appended that contains the unix timestamp of
+		// that file's last modified time. This should be used for files
+		// that might change between page loads (JavaScript and CSS files,
+		// images, etc).
+		"versionpath": func(path string) string {
+			path = filepath.Clean(path)
+
+			// Leading `/` characters will just break local filepath
+			// resolution, so we remove it if it exists.
+			fi, err := os.Stat(strings.TrimPrefix(path, "/"))
+			if err == nil {
+				path = path + "?" + strconv.Itoa(int(fi.ModTime().Unix()))
+			} else {
+				fmt.Printf("seatbelt: error getting file info at path %s: %v\n", path, err)
+			}
 
-				return path
-			},
-			"csrfMetaTags": func() template.HTML {
-				return template.HTML(`<meta name="csrf-token" content="` + csrf.Token(r) + `">`)
-			},
-		}
+			return path
+		},
+		"csrfMetaTags": func() template.HTML {
+			return template.HTML(`<meta name="csrf-token" content="` + csrfToken(r) + `">`)
+		},
+		// cspNonce returns the nonce of the request's Content-Security-Policy,
+		// for the nonce attribute of inline scripts. See SecureHeaders.
+		"cspNonce": func() string {
+			return cspNonce(r)
+		},
+		// captcha renders the widget of the configured CAPTCHA provider,
+		// or nothing if CAPTCHAs are disabled.
+		"captcha": func() template.HTML {
+			if a.captcha == nil {
+				return ""
+			}
+			return a.captcha.Widget()
+		},
+		// urlFor returns the URL of the route with the given name. See
+		// App.URLFor.
+		"urlFor": func(name string, pairs ...interface{}) (string, error) {
+			return a.URLFor(name, pairs...)
+		},
+		// linkWithParams returns the URL of the current page with the given
+		// search params, and the given key and value pairs replaced. See
+		// Context.SearchParams.
+		"linkWithParams": func(params interface{}, pairs ...interface{}) (string, error) {
+			return linkWithParams(r.URL.Path, params, a.fieldNaming, pairs...)
+		},
+		// javascriptTag and stylesheetTag return the tags of the asset
+		// with the given name, and add its preload hint to the response.
+		// See AssetOptions.
+		"javascriptTag": func(name string, opts ...string) (template.HTML, error) {
+			return a.assets.javascriptTag(w, name, opts...)
+		},
+		"stylesheetTag": func(name string, opts ...string) (template.HTML, error) {
+			return a.assets.stylesheetTag(w, name, opts...)
+		},
+		// preloaded returns the value with the given key loaded by the
+		// funcs registered with App.Preload.
+		"preloaded": func(key string) (interface{}, error) {
+			return a.preloaded.get(r.Context(), key)
+		},
 	}
 }
 
@@ -409,6 +1035,10 @@ func New(opts ...Option) *App {
 
 	translator := i18n.New(opt.LocaleDir, opt.Reload)
 
+	if opt.Development {
+		recentLogs.capture()
+	}
+
 	// Initialize the underlying chi mux so that we can setup our default
 	// middleware stack.
 	mux := chi.NewRouter()
@@ -423,35 +1053,178 @@ func New(opts ...Option) *App {
 			h.ServeHTTP(w, r)
 		})
 	})
-	mux.Use(csrf.Protect(signingKey, csrf.Path("/")))
 
 	sess := session.New(signingKey, session.Options{
 		Name:   opt.SessionName,
 		MaxAge: opt.SessionMaxAge,
+		Clock:  now,
+		Store:  opt.SessionStore,
+
+		RegenerateKeys: opt.SessionRegenerateKeys,
 	})
 
-	funcMaps := []render.ContextualFuncMap{defaultTemplateFuncs(sess, translator)}
-	if opt.Funcs != nil {
-		funcMaps = append(funcMaps, opt.Funcs)
+	var verifier *captcha.Captcha
+	if opt.Captcha != nil {
+		verifier = captcha.New(*opt.Captcha)
 	}
 
 	app := &App{
 		mux:        mux,
 		signingKey: signingKey,
 		session:    sess,
-		renderer: render.New(&render.Options{
-			Dir:    opt.TemplateDir,
-			Layout: "layout",
-			Reload: opt.Reload,
-			Funcs:  funcMaps,
-		}),
-		i18n: translator,
+		i18n:       translator,
+		captcha:    verifier,
+		routes:     newRoutes(),
+		plugins:    registeredPlugins(),
+		drainer:    &drainer{},
+		tasks:      &tasks{},
+		servers:    &servers{},
+		preloaded:  &preloaded{reload: opt.Reload},
+		assets:     newAssets(opt.Assets, opt.Reload),
+
+		development: opt.Development,
+		redactor:    newRedactor(opt.Redact),
+		consent:     newConsentConfig(opt.Consent),
+
+		templateTenant: opt.TemplateTenant,
+		comparisons:    &comparisons{},
+
+		fieldNaming:          opt.FieldNaming,
+		jsonOptions:          opt.JSON,
+		slowRequestThreshold: opt.SlowRequestThreshold,
+		slowRenderThreshold:  opt.SlowRenderThreshold,
+		onSlowRequest:        opt.OnSlowRequest,
+		requestTimeout:       opt.RequestTimeout,
+
+		normalization: pathNormalization{
+			redirectTrailingSlash: opt.RedirectTrailingSlash,
+			matchTrailingSlash:    opt.MatchTrailingSlash,
+			caseInsensitive:       opt.CaseInsensitivePaths,
+		},
+	}
+
+	// Resolve the client's address before anything else uses it.
+	if len(opt.TrustedProxies) > 0 {
+		mux.Use(parseTrustedProxies(opt.TrustedProxies).middleware)
+	}
+
+	// Redirect to the canonical URL before doing any other work.
+	redirects := canonicalRedirects{
+		forceHTTPS:    opt.ForceHTTPS,
+		canonicalHost: opt.CanonicalHost,
+		exempt:        opt.RedirectExemptPaths,
+	}
+	if redirects.enabled() {
+		mux.Use(redirects.middleware)
+	}
+
+	// Metrics are recorded before compression, so that latencies include
+	// it.
+	app.metrics = newMetrics(opt.Metrics, app.InFlight)
+	if app.metrics != nil {
+		mux.Use(app.metrics.middleware)
+	}
+
+	if opt.Compress {
+		mux.Use(compress)
+	}
+
+	// CORS headers are added before CSRF validation, so that browsers can
+	// read the responses to requests that fail it.
+	if opt.CORS != nil {
+		mux.Use(CORS(*opt.CORS))
+	}
+
+	// Paths are normalized before CSRF validation so that routes exempted
+	// with SkipCSRF are matched by their normalized path.
+	if app.normalization.enabled() {
+		mux.Use(app.normalizePaths)
 	}
+	mux.Use(app.skipCSRFRoutes)
+	mux.Use(app.csrfProtect(opt.CSRF))
+
+	funcMaps := []render.ContextualFuncMap{app.defaultTemplateFuncs}
+	if opt.Funcs != nil {
+		funcMaps = append(funcMaps, opt.Funcs)
+	}
+
+	// Route requests for hosts registered with Host after the default
+	// middleware stack has run.
+	mux.Use(app.routeHosts)
+
+	// Requests that don't match a route render the application's error
+	// templates, if it has any.
+	mux.NotFound(app.statusHandler(http.StatusNotFound, http.NotFound))
+	mux.MethodNotAllowed(app.statusHandler(http.StatusMethodNotAllowed, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	app.renderer = render.New(&render.Options{
+		Dir:    opt.TemplateDir,
+		FS:     opt.TemplateFS,
+		Layout: "layout",
+		Reload: opt.Reload,
+		Funcs:  funcMaps,
+		Hooks:  withPluginHooks(opt.RenderHooks, app.plugins),
+
+		Extensions:      opt.TemplateExtensions,
+		Engine:          opt.TemplateEngine,
+		StreamThreshold: opt.RenderStreamThreshold,
+		ETag:            opt.RenderETag,
+
+		Cache:       opt.TemplateCache,
+		Uncacheable: []string{"csrf", "csrfMetaTags", "flashes", "cspNonce"},
+	})
 
 	if !opt.SkipServeFiles {
-		app.FileServer("/public", "public")
+		app.FileServer("/public", "public", opt.PublicFiles)
+	}
+	if opt.CSRF.ServeToken {
+		app.Get("/csrf", func(c *Context) error {
+			return c.JSON(http.StatusOK, map[string]string{"token": c.CSRFToken()})
+		})
+	}
+	if app.consent != nil {
+		path := opt.Consent.Path
+		if path == "" {
+			path = "/consent"
+		}
+		app.Post(path, recordConsent)
+	}
+	if opt.ServeVersion {
+		app.Get("/__version", func(c *Context) error {
+			return c.JSON(http.StatusOK, BuildInfo())
+		})
 	}
 
+	if app.metrics != nil {
+		path := opt.Metrics.Path
+		if path == "" {
+			path = "/metrics"
+		}
+		app.Get(path, app.metrics.serve)
+	}
+	if opt.SessionStore != nil && opt.SessionGC.Interval > 0 {
+		app.every(opt.SessionGC.Interval, func() {
+			app.collectSessions(opt.SessionStore, opt.SessionGC.OnGC)
+		})
+	}
+
+	app.watchdog = newWatchdog(opt.Watchdog, opt.SessionStore, app.InFlight)
+	if app.watchdog != nil {
+		if opt.Watchdog.Path != "" {
+			app.Get(opt.Watchdog.Path, func(c *Context) error {
+				r, _ := app.Watchdog()
+				return c.JSON(http.StatusOK, r)
+			})
+		}
+		if opt.Watchdog.Interval > 0 {
+			app.every(opt.Watchdog.Interval, func() { app.watchdog.check() })
+		}
+	}
+
+	app.boot()
+
 	return app
 }
 
@@ -465,12 +1238,29 @@ func New(opts ...Option) *App {
 // Production applications should create their own
 // *http.Server, and pass the *seatbelt.App to that *http.Server's `Handler`.
 func (a *App) Start(addr string) error {
+	log.Printf("seatbelt: starting %s on %s", BuildInfo(), addr)
 	return http.ListenAndServe(addr, a)
 }
 
+// RenderToWriter renders the HTML template with the given name to the given
+// writer outside of a request, i.e., from a background job. Template funcs
+// that depend on the request, such as "csrf" and "flashes", render as empty
+// strings.
+func (a *App) RenderToWriter(w io.Writer, name string, data map[string]interface{}, opts ...render.RenderOptions) error {
+	var o render.RenderOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.Layout == "" {
+		o.Layout = a.layout
+	}
+	return a.renderer.HTML(w, nil, name, data, o)
+}
+
 // UseStd registers standard HTTP middleware on the application.
 func (a *App) UseStd(middleware ...func(http.Handler) http.Handler) {
 	a.mux.Use(middleware...)
+	a.std = append(a.std, middleware...)
 }
 
 // Use registers Seatbelt HTTP middleware on the application.
@@ -484,7 +1274,12 @@ func (a *App) SetErrorHandler(fn func(c *Context, err error)) {
 	a.errorHandler = fn
 }
 
-// ErrorHandler is the globally registered error handler.
+// ErrorHandler is the globally registered error handler. Errors wrapped
+// with WrapError are responded to with their status code and translated
+// message. Other errors are responded to with a generic 500 Internal Server
+// Error message. Error pages are rendered with the "errors/<status>" or
+// "errors/error" template, if one exists. Clients that accept JSON get
+// problem details instead, see c.Problem.
 //
 // You can override this function using `SetErrorHandler`.
 func (a *App) handleErr(c *Context, err error) {
@@ -493,27 +1288,97 @@ func (a *App) handleErr(c *Context, err error) {
 		return
 	}
 
-	fmt.Printf("seatbelt: hit error handler: %#v\n", err)
+	var e *Error
+	if errors.As(err, &e) {
+		log.Printf("seatbelt: hit error handler with status %d: %s", e.Code, a.Redact(causeChain(err)))
 
-	switch c.r.Method {
-	case "GET", "HEAD", "OPTIONS":
-		c.String(http.StatusInternalServerError, err.Error())
+		message := e.userMessage(c)
+		switch {
+		case acceptsJSON(c.r):
+			detail := message
+			if detail == http.StatusText(e.Code) {
+				detail = ""
+			}
+			c.Problem(e.Code, "", detail)
+		case c.r.Method == "GET" || c.r.Method == "HEAD" || c.r.Method == "OPTIONS":
+			a.renderErrorPage(c, e.Code, message, err)
+		default:
+			from := c.r.Referer()
+			c.Flash.Alert(message)
+			c.Redirect(from)
+		}
+		return
+	}
+
+	log.Printf("seatbelt: hit error handler: %s", a.Redact(causeChain(err)))
+
+	// The error itself is only logged, as it may contain internals that
+	// mustn't be shown to users.
+	message := http.StatusText(http.StatusInternalServerError)
+	switch {
+	case acceptsJSON(c.r):
+		c.Problem(http.StatusInternalServerError, "", "")
+	case c.r.Method == "GET" || c.r.Method == "HEAD" || c.r.Method == "OPTIONS":
+		a.renderErrorPage(c, http.StatusInternalServerError, message, err)
 	default:
 		from := c.r.Referer()
-		c.Flash.Add("alert", err.Error())
+		c.Flash.Alert(message)
 		c.Redirect(from)
 	}
 }
 
 // serveContext creates and registers a Seatbelt handler for an HTTP request.
 func (a *App) serveContext(w http.ResponseWriter, r *http.Request, handle func(c *Context) error) {
+	a.serveContextWith(w, r, handle, append(a.pluginMiddleware(), a.middlewareStack()...))
+}
+
+// serveContextWith is serveContext with the given middleware in place of the
+// application's, i.e., to respond to a request whose handler timed out
+// without running its middleware again.
+func (a *App) serveContextWith(w http.ResponseWriter, r *http.Request, handle func(c *Context) error, middlewares []MiddlewareFunc) {
+	// Changes to the session are written as a single cookie right before
+	// the response header is written.
+	r = a.session.Buffered(r)
+	rw := &responseWriter{ResponseWriter: w}
+	if a.consent != nil {
+		r = a.consent.withConsent(rw, r)
+	}
+	rw.beforeWrite = func() {
+		if a.metrics != nil {
+			defer a.metrics.observeSession(time.Now())
+		}
+		a.session.Flush(rw, r)
+		consentFromRequest(r).filter(rw.Header())
+	}
+	var body *requestBody
+	if r.Body != nil && r.Body != http.NoBody {
+		body = &requestBody{ReadCloser: r.Body}
+		r.Body = body
+	}
+
 	common := &context{
-		w:        w,
+		w:        rw,
 		r:        r,
 		i18n:     a.i18n,
 		values:   values.New(r),
 		session:  a.session,
 		renderer: a.renderer,
+		captcha:  a.captcha,
+		routes:   a.routes,
+		stats:    rw,
+		body:     body,
+		trace:    &trace{},
+
+		fieldNaming: a.fieldNaming,
+		jsonOptions: a.jsonOptions,
+
+		slowRenderThreshold: a.slowRenderThreshold,
+
+		layout:         a.layout,
+		templateTenant: a.templateTenant,
+		comparisons:    a.comparisons,
+		metrics:        a.metrics,
+		errorPage:      a.renderErrorPage,
 	}
 
 	c := &Context{
@@ -533,140 +1398,275 @@ func (a *App) serveContext(w http.ResponseWriter, r *http.Request, handle func(c
 	//	app.Use(m1, m2)
 	// It will run as:
 	//	m1->m2->handler->m2 returned->m1 returned.
-	for i := len(a.middlewares) - 1; i >= 0; i-- {
-		handle = a.middlewares[i](handle)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handle = middlewares[i](handle)
 	}
 
-	if err := handle(c); err != nil {
+	start := time.Now()
+	if err := recoverHandler(handle, c); err != nil {
+		var p *PanicError
+		if errors.As(err, &p) {
+			log.Printf("seatbelt: recovered from panic in %s %s: %v\n%s", r.Method, a.Redact(r.URL.Path), p.Value, p.Stack)
+		}
 		a.handleErr(c, err)
 	}
+
+	// Write the session cookie if the handler didn't write a response.
+	rw.writingHeader()
+
+	a.reportSlow(c, time.Since(start))
 }
 
 // handle registers the given handler to handle requests at the given path
 // with the given HTTP verb.
-func (a *App) handle(verb, path string, handle func(c *Context) error) {
+func (a *App) handle(verb, path string, handle func(c *Context) error) *Route {
+	route := &Route{app: a, method: verb, path: a.prefix + path}
+	path, route.params = a.routes.constraints.parse(path)
+	route.pattern = a.prefix + path
+
+	notFound := a.statusHandler(http.StatusNotFound, http.NotFound)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !route.matches(r) {
+			notFound(w, r)
+			return
+		}
+		if a.timesOut(route) {
+			a.serveWithTimeout(w, r, handle)
+			return
+		}
+		a.serveContext(w, r, handle)
+	})
+
 	switch verb {
 	case "HEAD":
-		a.mux.Head(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Head(path, h)
 
 	case "OPTIONS":
-		a.mux.Options(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Options(path, h)
 
 	case "GET":
-		a.mux.Get(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Get(path, h)
 
 	case "POST":
-		a.mux.Post(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Post(path, h)
 
 	case "PUT":
-		a.mux.Put(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Put(path, h)
 
 	case "PATCH":
-		a.mux.Patch(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Patch(path, h)
 
 	case "DELETE":
-		a.mux.Delete(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a.serveContext(w, r, handle)
-		}))
+		a.mux.Delete(path, h)
 
 	default:
 		panic("method " + verb + " not allowed")
 	}
+
+	a.routes.register(route)
+	return route
+}
+
+// middlewareStack returns the middleware that runs for requests handled by
+// this application, including the middleware inherited from its parents.
+func (a *App) middlewareStack() []MiddlewareFunc {
+	if a.parent == nil || a.skipInheritMiddleware {
+		return a.middlewares
+	}
+
+	inherited := a.parent.middlewareStack()
+	stack := make([]MiddlewareFunc, 0, len(inherited)+len(a.middlewares))
+	stack = append(stack, inherited...)
+	return append(stack, a.middlewares...)
 }
 
-// Namespace creates a new *seatbelt.App with an empty middleware stack and
-// mounts it on the `pattern` as a subrouter.
-func (a *App) Namespace(pattern string, fn func(app *App)) *App {
+// NamespaceOpts are used to configure a namespace created with Namespace.
+type NamespaceOpts struct {
+	// SkipInheritMiddleware starts the namespace with an empty middleware
+	// stack instead of inheriting the middleware of its parent. Default is
+	// false.
+	SkipInheritMiddleware bool
+
+	// Layout renders every template in the namespace in the given layout,
+	// unless a handler passes its own, i.e., "admin" for
+	// "templates/layouts/admin.html". Default is the layout of the parent.
+	Layout string
+}
+
+// Namespace creates a new *seatbelt.App and mounts it on the `pattern` as a
+// subrouter.
+//
+// The namespace inherits the middleware registered on its parent with Use,
+// including middleware registered after the call to Namespace. Inherited
+// middleware runs before the namespace's own middleware. Pass
+// NamespaceOpts{SkipInheritMiddleware: true} to start with an empty
+// middleware stack instead.
+func (a *App) Namespace(pattern string, fn func(app *App), opts ...NamespaceOpts) *App {
 	if fn == nil {
 		panic(fmt.Sprintf("seatbelt: attempting to Route() a nil sub-app on '%s'", pattern))
 	}
 
-	subApp := &App{
+	var o NamespaceOpts
+	for _, opt := range opts {
+		o = opt
+	}
+
+	subApp := a.child(chi.NewRouter(), a.prefix+strings.TrimSuffix(pattern, "/"))
+	subApp.skipInheritMiddleware = o.SkipInheritMiddleware
+	if o.Layout != "" {
+		subApp.layout = o.Layout
+	}
+
+	fn(subApp)
+	// Mount the sub-app's router rather than the sub-app itself, so that the
+	// parent can match paths against the sub-app's routes.
+	a.mux.Mount(pattern, subApp.mux)
+
+	return subApp
+}
+
+// Group creates a new *seatbelt.App that registers its routes on the same
+// router as its parent, without a path prefix, but with its own middleware.
+// This allows applying middleware to a subset of routes, i.e.,
+//
+//	app.Get("/login", showLogin)
+//	app.Group(func(app *seatbelt.App) {
+//		app.Use(requireLogin)
+//		app.Get("/account", showAccount)
+//	})
+//
+// Like a namespace, a group inherits the middleware of its parent.
+// Standard middleware registered on the group with UseStd only applies to
+// the group's routes.
+func (a *App) Group(fn func(app *App)) *App {
+	if fn == nil {
+		panic("seatbelt: attempting to Group() a nil sub-app")
+	}
+
+	group := a.child(a.mux.With(), a.prefix)
+	fn(group)
+
+	return group
+}
+
+// child returns a new *seatbelt.App that shares the dependencies of its
+// parent, but routes requests with the given router.
+func (a *App) child(mux chi.Router, prefix string) *App {
+	return &App{
 		signingKey:   a.signingKey,
 		i18n:         a.i18n,
 		session:      a.session,
 		renderer:     a.renderer,
+		captcha:      a.captcha,
+		routes:       a.routes,
+		preloaded:    a.preloaded,
+		comparisons:  a.comparisons,
+		assets:       a.assets,
+		plugins:      a.plugins,
+		drainer:      a.drainer,
+		tasks:        a.tasks,
+		watchdog:     a.watchdog,
+		metrics:      a.metrics,
+		servers:      a.servers,
+		layout:       a.layout,
+		prefix:       prefix,
+		host:         a.host,
 		errorHandler: a.errorHandler,
-		mux:          chi.NewRouter(),
-		// TODO Not sure if this is actually the behaviour we want -- should
-		// it inherit the middleware stack?
-		middlewares: make([]MiddlewareFunc, 0),
+		development:  a.development,
+		redactor:     a.redactor,
+		consent:      a.consent,
+		mux:          mux,
+		parent:       a,
+		middlewares:  make([]MiddlewareFunc, 0),
+
+		fieldNaming:          a.fieldNaming,
+		jsonOptions:          a.jsonOptions,
+		slowRequestThreshold: a.slowRequestThreshold,
+		slowRenderThreshold:  a.slowRenderThreshold,
+		onSlowRequest:        a.onSlowRequest,
+		requestTimeout:       a.requestTimeout,
+		templateTenant:       a.templateTenant,
 	}
-
-	fn(subApp)
-	a.mux.Mount(pattern, subApp)
-
-	return subApp
 }
 
 // Head routes HEAD requests to the given path.
-func (a *App) Head(path string, handle func(c *Context) error) {
-	a.handle("HEAD", path, handle)
+func (a *App) Head(path string, handle func(c *Context) error) *Route {
+	return a.handle("HEAD", path, handle)
 }
 
 // Options routes OPTIONS requests to the given path.
-func (a *App) Options(path string, handle func(c *Context) error) {
-	a.handle("OPTIONS", path, handle)
+func (a *App) Options(path string, handle func(c *Context) error) *Route {
+	return a.handle("OPTIONS", path, handle)
 }
 
 // Get routes GET requests to the given path.
-func (a *App) Get(path string, handle func(c *Context) error) {
-	a.handle("GET", path, handle)
+func (a *App) Get(path string, handle func(c *Context) error) *Route {
+	return a.handle("GET", path, handle)
 }
 
 // Post routes POST requests to the given path.
-func (a *App) Post(path string, handle func(c *Context) error) {
-	a.handle("POST", path, handle)
+func (a *App) Post(path string, handle func(c *Context) error) *Route {
+	return a.handle("POST", path, handle)
 }
 
 // Put routes PUT requests to the given path.
-func (a *App) Put(path string, handle func(c *Context) error) {
-	a.handle("PUT", path, handle)
+func (a *App) Put(path string, handle func(c *Context) error) *Route {
+	return a.handle("PUT", path, handle)
 }
 
 // Patch routes PATCH requests to the given path.
-func (a *App) Patch(path string, handle func(c *Context) error) {
-	a.handle("PATCH", path, handle)
+func (a *App) Patch(path string, handle func(c *Context) error) *Route {
+	return a.handle("PATCH", path, handle)
 }
 
 // Delete routes DELETE requests to the given path.
-func (a *App) Delete(path string, handle func(c *Context) error) {
-	a.handle("DELETE", path, handle)
+func (a *App) Delete(path string, handle func(c *Context) error) *Route {
+	return a.handle("DELETE", path, handle)
 }
 
 // FileServer serves the contents of the given directory at the given path.
-func (a *App) FileServer(path string, dir string) {
+// Caching headers can be configured with FileServerOptions, i.e.,
+//
+//	app.FileServer("/assets", "assets", seatbelt.FileServerOptions{
+//		ETag:      true,
+//		Immutable: seatbelt.Fingerprinted,
+//	})
+func (a *App) FileServer(path string, dir string, opts ...FileServerOptions) {
+	a.serveFiles(path, http.Dir(dir), opts...)
+}
+
+// FileServerFS serves the contents of the given file system at the given
+// path, i.e., files embedded with the embed package. See FileServer.
+func (a *App) FileServerFS(path string, fsys fs.FS, opts ...FileServerOptions) {
+	a.serveFiles(path, http.FS(fsys), opts...)
+}
+
+// serveFiles serves the files of root at the given path.
+func (a *App) serveFiles(path string, root http.FileSystem, opts ...FileServerOptions) {
 	if strings.ContainsAny(path, "{}*") {
 		panic("FileServer does not permit URL parameters.")
 	}
 
-	fs := http.StripPrefix(path, http.FileServer(http.Dir(dir)))
+	var opt FileServerOptions
+	for _, o := range opts {
+		opt = o
+	}
+
+	files := http.StripPrefix(a.prefix+path, cacheHeaders(root, http.FileServer(root), opt))
 
 	if path != "/" && path[len(path)-1] != '/' {
-		a.mux.Get(path, http.RedirectHandler(path+"/", http.StatusMovedPermanently).ServeHTTP)
+		a.mux.Get(path, http.RedirectHandler(a.prefix+path+"/", http.StatusMovedPermanently).ServeHTTP)
 		path += "/"
 	}
 	path += "*"
 
 	a.mux.Get(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fs.ServeHTTP(w, r)
+		files.ServeHTTP(w, r)
 	}))
 }
 
 // ServeHTTP makes the Seatbelt application implement the http.Handler
 // interface.
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	a.mux.ServeHTTP(w, r)
+	a.drainer.serve(a.mux, w, r)
 }
@@ -1,11 +1,20 @@
 package seatbelt
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-seatbelt/seatbelt/render"
 )
 
 func TestOptions(t *testing.T) {
@@ -139,3 +148,638 @@ func TestCSRFSkipPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestCSRFOptions(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles: true,
+		CSRF: CSRFOptions{
+			CookieName: "_csrf",
+			SameSite:   http.SameSiteStrictMode,
+			OnFailure: func(c *Context, err error) error {
+				return c.String(http.StatusForbidden, "rejected: "+err.Error())
+			},
+		},
+	})
+
+	app.Get("/", func(c *Context) error {
+		return c.NoContent()
+	})
+	app.Post("/", func(c *Context) error {
+		return c.NoContent()
+	})
+
+	t.Run("the CSRF cookie is configured", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/", nil)
+
+		var cookie *http.Cookie
+		for _, c := range resp.Cookies {
+			if c.Name == "_csrf" {
+				cookie = c
+			}
+		}
+		if cookie == nil {
+			t.Fatalf("expected a cookie named _csrf")
+		}
+		if cookie.SameSite != http.SameSiteStrictMode {
+			t.Fatalf("expected SameSite %v but got %v", http.SameSiteStrictMode, cookie.SameSite)
+		}
+	})
+
+	t.Run("failures are handled by OnFailure", func(t *testing.T) {
+		resp := app.Invoke(http.MethodPost, "/", nil)
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected %d but got %d", http.StatusForbidden, resp.StatusCode)
+		}
+		if !strings.HasPrefix(resp.String(), "rejected: ") {
+			t.Fatalf("expected the OnFailure response but got %s", resp.String())
+		}
+	})
+
+	t.Run("failures are forbidden if OnFailure doesn't respond", func(t *testing.T) {
+		app := New(Option{
+			SkipServeFiles: true,
+			CSRF: CSRFOptions{
+				OnFailure: func(c *Context, err error) error {
+					c.Flash.Alert("The form expired, please try again.")
+					return nil
+				},
+			},
+		})
+		app.Post("/", func(c *Context) error {
+			return c.String(http.StatusOK, "accepted")
+		})
+
+		resp := app.Invoke(http.MethodPost, "/", nil)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected %d but got %d %s", http.StatusForbidden, resp.StatusCode, resp.String())
+		}
+	})
+}
+
+func TestTestMode(t *testing.T) {
+	restore := TestMode(TestModeOptions{Seed: 1})
+	defer restore()
+
+	t.Run("the signing key is derived from the seed without writing master.key", func(t *testing.T) {
+		os.Remove("master.key")
+
+		o1, o2 := &Option{}, &Option{}
+		o1.setDefaults()
+		o2.setDefaults()
+
+		if o1.SigningKey != o2.SigningKey {
+			t.Fatalf("expected %s but got %s", o1.SigningKey, o2.SigningKey)
+		}
+		if _, err := os.Stat("master.key"); !os.IsNotExist(err) {
+			t.Fatalf("expected master.key not to exist but got %v", err)
+		}
+	})
+
+	t.Run("session cookies expire relative to the frozen clock", func(t *testing.T) {
+		app := New()
+		app.Get("/", func(c *Context) error {
+			c.Session.Set("key", "value")
+			return c.NoContent()
+		})
+
+		srv := httptest.NewServer(app)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var expires string
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == "_session" {
+				expires = cookie.RawExpires
+				if !cookie.Expires.After(time.Now()) {
+					t.Fatalf("expected the cookie to expire in the future but got %s", cookie.Expires)
+				}
+			}
+		}
+		if !strings.Contains(expires, "01 Jan 2101") {
+			t.Fatalf("expected cookie to expire on 01 Jan 2101 but got %s", expires)
+		}
+	})
+}
+
+func TestNamespaceMiddleware(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	mark := func(name string) MiddlewareFunc {
+		return func(fn func(c *Context) error) func(c *Context) error {
+			return func(c *Context) error {
+				c.Response().Header().Add("X-Middleware", name)
+				return fn(c)
+			}
+		}
+	}
+
+	app.Use(mark("parent"))
+	app.Namespace("/inherit", func(app *App) {
+		app.Use(mark("child"))
+		app.Get("/", func(c *Context) error {
+			return c.NoContent()
+		})
+	})
+	app.Namespace("/skip", func(app *App) {
+		app.Use(mark("child"))
+		app.Get("/", func(c *Context) error {
+			return c.NoContent()
+		})
+	}, NamespaceOpts{SkipInheritMiddleware: true})
+
+	cases := []struct {
+		path        string
+		middlewares string
+	}{
+		{path: "/inherit/", middlewares: "parent,child"},
+		{path: "/skip/", middlewares: "child"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			resp := app.Invoke(http.MethodGet, c.path, nil)
+
+			actual := strings.Join(resp.Header.Values("X-Middleware"), ",")
+			if actual != c.middlewares {
+				t.Fatalf("expected %s but got %s", c.middlewares, actual)
+			}
+		})
+	}
+}
+
+func TestServeVersion(t *testing.T) {
+	app := New(Option{SkipServeFiles: true, ServeVersion: true})
+
+	resp := app.Invoke(http.MethodGet, "/__version", nil)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 but got %d", resp.StatusCode)
+	}
+
+	var b Build
+	if err := resp.JSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Seatbelt != Version {
+		t.Fatalf("expected %s but got %s", Version, b.Seatbelt)
+	}
+	if b.Version == "" {
+		t.Fatal("expected version to be set")
+	}
+}
+
+func TestResponseStats(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	var stats ResponseStats
+	app.Post("/", func(c *Context) error {
+		if _, err := io.ReadAll(c.Request().Body); err != nil {
+			return err
+		}
+		err := c.String(http.StatusCreated, "hello")
+		stats = c.ResponseStats()
+		return err
+	})
+
+	app.Invoke(http.MethodPost, "/", strings.NewReader("request"), InvokeOptions{SkipCSRF: true})
+
+	expected := ResponseStats{Status: http.StatusCreated, BytesWritten: 5, RequestBytes: 7}
+	if stats != expected {
+		t.Fatalf("expected %+v but got %+v", expected, stats)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Get("/public", func(c *Context) error {
+		return c.String(200, "public")
+	})
+	app.Group(func(app *App) {
+		app.Use(func(fn func(c *Context) error) func(c *Context) error {
+			return func(c *Context) error {
+				return c.String(http.StatusUnauthorized, "unauthorized")
+			}
+		})
+		app.Get("/private", func(c *Context) error {
+			return c.String(200, "private")
+		})
+	})
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{path: "/public", status: 200},
+		{path: "/private", status: http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			resp := app.Invoke(http.MethodGet, c.path, nil)
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d but got %d", c.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestDoubleRender(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+
+	var err error
+	app.Get("/", func(c *Context) error {
+		c.Render("index", nil)
+		err = c.Render("index", nil)
+		return nil
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil)
+
+	if err == nil {
+		t.Fatal("expected second render to return an error")
+	}
+	if n := strings.Count(resp.String(), "<h1>index</h1>"); n != 1 {
+		t.Fatalf("expected the page to be rendered once but got %d", n)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+
+	var err error
+	app.Get("/", func(c *Context) error {
+		err = c.Render("missing", nil)
+		return err
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil)
+
+	if err == nil {
+		t.Fatal("expected rendering a missing template to return an error")
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected %d but got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+	if body := resp.String(); !strings.Contains(body, `<p class="error">500 Internal Server Error</p>`) {
+		t.Fatalf("expected the error page but got %s", body)
+	}
+}
+
+func TestRenderLayout(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+
+	app.Get("/", func(c *Context) error {
+		return c.Render("index", nil)
+	})
+	app.Get("/pricing", func(c *Context) error {
+		return c.Render("index", nil, render.RenderOptions{Layout: "marketing"})
+	})
+	app.Namespace("/admin", func(app *App) {
+		app.Get("/", func(c *Context) error {
+			return c.Render("index", nil)
+		})
+		app.Get("/landing", func(c *Context) error {
+			return c.Render("index", nil, render.RenderOptions{Layout: "marketing"})
+		})
+		app.Namespace("/users", func(app *App) {
+			app.Get("/", func(c *Context) error {
+				return c.Render("index", nil)
+			})
+		})
+	}, NamespaceOpts{Layout: "admin"})
+
+	cases := []struct {
+		path   string
+		layout string
+	}{
+		{"/", "<body>"},
+		{"/pricing", `<main class="marketing">`},
+		{"/admin/", `<main class="admin">`},
+		{"/admin/landing", `<main class="marketing">`},
+		{"/admin/users/", `<main class="admin">`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			body := app.Invoke(http.MethodGet, c.path, nil).String()
+			if !strings.Contains(body, c.layout) || !strings.Contains(body, "<h1>index</h1>") {
+				t.Fatalf("expected %s layout but got %s", c.layout, body)
+			}
+		})
+	}
+}
+
+func TestRenderOverrides(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		LocaleDir:      filepath.Join("i18n", "testdata"),
+		SkipServeFiles: true,
+		TemplateTenant: func(r *http.Request) string {
+			return strings.Split(r.Host, ".")[0]
+		},
+	})
+	app.Get("/", func(c *Context) error {
+		return c.Render("index", nil)
+	})
+
+	cases := []struct {
+		target   string
+		language string
+		expected string
+	}{
+		{"http://example.com/", "", "<h1>index</h1>"},
+		{"http://example.com/", "fr-FR", "<h1>index fr</h1>"},
+		{"http://example.com/?locale=fr", "", "<h1>index fr</h1>"},
+		{"http://acme.example.com/", "", "<h1>acme index</h1>"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.target+" "+c.language, func(t *testing.T) {
+			body := app.Invoke(http.MethodGet, c.target, nil, InvokeOptions{
+				Headers: map[string]string{"Accept-Language": c.language},
+			}).String()
+			if !strings.Contains(body, c.expected) {
+				t.Fatalf("expected %s but got %s", c.expected, body)
+			}
+		})
+	}
+}
+
+func TestRenderPartial(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+
+	app.Get("/row", func(c *Context) error {
+		return c.RenderPartial("users/_row", map[string]interface{}{"name": "Ada"})
+	})
+	app.Get("/users", func(c *Context) error {
+		return c.Render("users/index", map[string]interface{}{
+			"users": []map[string]interface{}{{"name": "Ada"}, {"name": "Grace"}},
+		})
+	})
+
+	t.Run("render a template without a layout", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/row", nil)
+		if body := strings.TrimSpace(resp.String()); body != "<li>Ada</li>" {
+			t.Fatalf("expected <li>Ada</li> but got %s", body)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("expected text/html but got %s", ct)
+		}
+	})
+
+	t.Run("render a template from a template", func(t *testing.T) {
+		body := app.Invoke(http.MethodGet, "/users", nil).String()
+		if !strings.Contains(body, "<body>") || !strings.Contains(body, "<ul><li>Ada</li>\n<li>Grace</li>\n</ul>") {
+			t.Fatalf("expected rendered rows in the layout but got %s", body)
+		}
+	})
+}
+
+func TestRenderToWriter(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+
+	var withLayout, withoutLayout bytes.Buffer
+	app.Get("/", func(c *Context) error {
+		c.RenderToWriter(&withLayout, "index", nil)
+		c.RenderToWriter(&withoutLayout, "index", nil, render.RenderOptions{SkipLayout: true})
+		return c.NoContent()
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		t.Fatalf("expected no Content-Type on the response but got %s", ct)
+	}
+	if s := withLayout.String(); !strings.Contains(s, "<body>") || !strings.Contains(s, "<h1>index</h1>") {
+		t.Fatalf("expected the template in its layout but got %s", s)
+	}
+	if s := strings.TrimSpace(withoutLayout.String()); s != "<h1>index</h1>" {
+		t.Fatalf("expected the template without a layout but got %s", s)
+	}
+
+	t.Run("render outside of a request", func(t *testing.T) {
+		var b bytes.Buffer
+		app.RenderToWriter(&b, "index", nil)
+		if s := b.String(); !strings.Contains(s, "<body>") || !strings.Contains(s, "<h1>index</h1>") {
+			t.Fatalf("expected the template in its layout but got %s", s)
+		}
+	})
+}
+
+func TestSkipCSRF(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Post("/", func(c *Context) error {
+		return c.NoContent()
+	})
+	app.Post("/webhook", func(c *Context) error {
+		return c.NoContent()
+	}).SkipCSRF()
+	app.Namespace("/api", func(app *App) {
+		app.Post("/posts/{id}", func(c *Context) error {
+			return c.NoContent()
+		})
+		app.SkipCSRF()
+	})
+	app.Group(func(app *App) {
+		app.SkipCSRF()
+		app.Post("/grouped", func(c *Context) error {
+			return c.NoContent()
+		})
+	})
+	app.Host("admin.example.com", func(app *App) {
+		app.Post("/api/posts/{id}", func(c *Context) error {
+			return c.NoContent()
+		})
+	})
+
+	cases := []struct {
+		host   string
+		path   string
+		status int
+	}{
+		{path: "/", status: http.StatusForbidden},
+		{path: "/webhook", status: http.StatusNoContent},
+		{path: "/api/posts/1", status: http.StatusNoContent},
+		{path: "/grouped", status: http.StatusNoContent},
+		{host: "admin.example.com", path: "/api/posts/1", status: http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		t.Run(c.host+c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, c.path, nil)
+			if c.host != "" {
+				r.Host = c.host
+			}
+			app.ServeHTTP(w, r)
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d but got %d", c.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFTokenEndpoint(t *testing.T) {
+	app := New(Option{
+		SkipServeFiles: true,
+		CSRF:           CSRFOptions{ServeToken: true},
+	})
+
+	app.Post("/", func(c *Context) error {
+		return c.NoContent()
+	})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(srv.URL + "/csrf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Token == "" {
+		t.Fatalf("expected a token")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(CSRFHeader, body.Token)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected %d but got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+type sessionUser struct {
+	Name string
+}
+
+func TestSessionAccessors(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Get("/set", func(c *Context) error {
+		c.Session.Set("name", "Jane")
+		c.Session.Set("visits", 3)
+		c.Session.Set("admin", true)
+		c.Session.Set("seen", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+		c.Session.Set("user", sessionUser{Name: "Jane"})
+		return c.NoContent()
+	})
+	app.Get("/get", func(c *Context) error {
+		user, ok := SessionAs[sessionUser](c.Session, "user")
+		if !ok {
+			return c.String(http.StatusOK, "no user")
+		}
+		return c.String(http.StatusOK, fmt.Sprintf("%s %d %v %d %s",
+			c.Session.GetString("name"),
+			c.Session.GetInt("visits"),
+			c.Session.GetBool("admin"),
+			c.Session.GetTime("seen").Year(),
+			user.Name,
+		))
+	})
+
+	resp := app.Invoke(http.MethodGet, "/set", nil)
+	resp = app.Invoke(http.MethodGet, "/get", nil, InvokeOptions{Cookies: resp.Cookies})
+
+	expected := "Jane 3 true 2022 Jane"
+	if resp.String() != expected {
+		t.Fatalf("expected %s but got %s", expected, resp.String())
+	}
+}
+
+func TestFlash(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Get("/", func(c *Context) error {
+		c.Flash.Notice("Saved")
+		c.Flash.Push(Flash{Level: "success", Message: "Published", Metadata: map[string]interface{}{"id": 1}})
+		c.Flash.Now("error", "Invalid")
+
+		var b strings.Builder
+		for _, f := range c.Flash.List() {
+			fmt.Fprintf(&b, "%s:%s ", f.Level, f.Message)
+		}
+		return c.String(http.StatusOK, b.String())
+	})
+
+	resp := app.Invoke(http.MethodGet, "/", nil)
+	expected := "notice:Saved success:Published error:Invalid "
+	if resp.String() != expected {
+		t.Fatalf("expected %s but got %s", expected, resp.String())
+	}
+}
+
+func TestSessionSingleCookie(t *testing.T) {
+	app := New(Option{SkipServeFiles: true})
+
+	app.Get("/", func(c *Context) error {
+		c.Session.Set("a", 1)
+		c.Session.Set("b", 2)
+		c.Flash.Notice("Saved")
+		return c.String(http.StatusOK, "ok")
+	})
+	app.Get("/empty", func(c *Context) error {
+		c.Session.Set("a", 1)
+		return nil
+	})
+
+	for _, path := range []string{"/", "/empty"} {
+		t.Run(path, func(t *testing.T) {
+			resp := app.Invoke(http.MethodGet, path, nil)
+
+			var n int
+			for _, c := range resp.Cookies {
+				if c.Name == "_session" {
+					n++
+				}
+			}
+			if n != 1 {
+				t.Fatalf("expected 1 session cookie but got %d", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSPNonce is a source of a Content-Security-Policy that is replaced with the
+// nonce of each request, i.e.,
+//
+//	seatbelt.NewCSP().Add("script-src", "'self'", seatbelt.CSPNonce)
+//
+// Inline scripts are allowed by adding the nonce to their tag with the
+// "cspNonce" template func, i.e.,
+//
+//	<script nonce="{{ cspNonce }}">...</script>
+const CSPNonce = "'nonce'"
+
+// A CSP builds a Content-Security-Policy from its directives.
+type CSP struct {
+	names   []string
+	sources map[string][]string
+}
+
+// NewCSP returns an empty Content-Security-Policy.
+func NewCSP() *CSP {
+	return &CSP{sources: make(map[string][]string)}
+}
+
+// Add adds the given sources to the directive with the given name, i.e.,
+//
+//	csp.Add("img-src", "'self'", "data:")
+//
+// Directives are written in the order in which they were first added.
+// Directives without sources, i.e., "upgrade-insecure-requests", are written
+// by their name.
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	if _, ok := p.sources[directive]; !ok {
+		p.names = append(p.names, directive)
+		p.sources[directive] = nil
+	}
+	p.sources[directive] = append(p.sources[directive], sources...)
+	return p
+}
+
+// header returns the policy with the given nonce in place of CSPNonce.
+func (p *CSP) header(nonce string) string {
+	directives := make([]string, len(p.names))
+	for i, name := range p.names {
+		directive := name
+		for _, source := range p.sources[name] {
+			if source == CSPNonce {
+				source = "'nonce-" + nonce + "'"
+			}
+			directive += " " + source
+		}
+		directives[i] = directive
+	}
+	return strings.Join(directives, "; ")
+}
+
+// usesNonce reports whether the policy has a CSPNonce source.
+func (p *CSP) usesNonce() bool {
+	for _, sources := range p.sources {
+		for _, source := range sources {
+			if source == CSPNonce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SecureHeadersOptions configure the headers set by SecureHeaders.
+type SecureHeadersOptions struct {
+	// HSTS is the max age of the Strict-Transport-Security header, which is
+	// only sent for HTTPS requests. Default is 0, meaning one year. Set it
+	// to -1 to not send the header.
+	HSTS time.Duration
+
+	// HSTSIncludeSubdomains and HSTSPreload add the includeSubDomains and
+	// preload directives to the Strict-Transport-Security header.
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// FrameOptions is the X-Frame-Options header. Default is "SAMEORIGIN".
+	// Set it to "-" to not send the header.
+	FrameOptions string
+
+	// ReferrerPolicy is the Referrer-Policy header. Default is
+	// "strict-origin-when-cross-origin". Set it to "-" to not send the
+	// header.
+	ReferrerPolicy string
+
+	// CSP is the Content-Security-Policy of every response. It must not be
+	// changed once the middleware is created. Default is nil, meaning no
+	// policy is sent.
+	CSP *CSP
+
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only,
+	// i.e., to find violations before the policy is enforced. Default is
+	// false.
+	CSPReportOnly bool
+}
+
+// cspNonceCtxKeyType is the context key of the request's CSP nonce.
+type cspNonceCtxKeyType struct{}
+
+var cspNonceCtxKey = cspNonceCtxKeyType{}
+
+// SecureHeaders returns middleware that sets the security headers of every
+// response, i.e., Strict-Transport-Security, X-Frame-Options,
+// X-Content-Type-Options, Referrer-Policy, and Content-Security-Policy:
+//
+//	app.UseStd(seatbelt.SecureHeaders(seatbelt.SecureHeadersOptions{
+//		CSP: seatbelt.NewCSP().
+//			Add("default-src", "'self'").
+//			Add("script-src", "'self'", seatbelt.CSPNonce),
+//	}))
+//
+// When the policy has a CSPNonce source, a new nonce is generated for each
+// request, see the "cspNonce" template func.
+func SecureHeaders(opts ...SecureHeadersOptions) func(http.Handler) http.Handler {
+	var o SecureHeadersOptions
+	for _, opt := range opts {
+		o = opt
+	}
+
+	var hsts string
+	if o.HSTS >= 0 {
+		maxAge := o.HSTS
+		if maxAge == 0 {
+			maxAge = 365 * 24 * time.Hour
+		}
+		hsts = "max-age=" + strconv.Itoa(int(maxAge/time.Second))
+		if o.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if o.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	if o.FrameOptions == "" {
+		o.FrameOptions = "SAMEORIGIN"
+	}
+	if o.ReferrerPolicy == "" {
+		o.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	cspHeader := "Content-Security-Policy"
+	if o.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	nonced := o.CSP != nil && o.CSP.usesNonce()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			if hsts != "" && requestScheme(r) == "https" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			if o.FrameOptions != "-" {
+				h.Set("X-Frame-Options", o.FrameOptions)
+			}
+			if o.ReferrerPolicy != "-" {
+				h.Set("Referrer-Policy", o.ReferrerPolicy)
+			}
+			h.Set("X-Content-Type-Options", "nosniff")
+
+			if o.CSP != nil {
+				var nonce string
+				if nonced {
+					nonce = newCSPNonce()
+					r = r.WithContext(stdcontext.WithValue(r.Context(), cspNonceCtxKey, nonce))
+				}
+				h.Set(cspHeader, o.CSP.header(nonce))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newCSPNonce returns a random nonce with 128 bits of entropy. It's encoded
+// with the URL-safe alphabet, as html/template escapes the "+" of the
+// standard alphabet in attributes.
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("seatbelt: failed to generate CSP nonce: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// cspNonce returns the CSP nonce of the given request, or an empty string if
+// its policy has no CSPNonce source.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceCtxKey).(string)
+	return nonce
+}
+
+// CSPNonce returns the nonce that the Content-Security-Policy set by
+// SecureHeaders allows inline scripts and styles with, or an empty string if
+// the policy has no CSPNonce source.
+func (c *context) CSPNonce() string {
+	return cspNonce(c.r)
+}
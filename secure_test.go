@@ -0,0 +1,95 @@
+package seatbelt
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+		app.UseStd(SecureHeaders())
+		app.Get("/", func(c *Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+
+		resp := app.Invoke(http.MethodGet, "https://example.com/", nil)
+		for header, expected := range map[string]string{
+			"Strict-Transport-Security": "max-age=31536000",
+			"X-Frame-Options":           "SAMEORIGIN",
+			"X-Content-Type-Options":    "nosniff",
+			"Referrer-Policy":           "strict-origin-when-cross-origin",
+			"Content-Security-Policy":   "",
+		} {
+			if actual := resp.Header.Get(header); actual != expected {
+				t.Fatalf("expected %s to be %q but got %q", header, expected, actual)
+			}
+		}
+
+		// HSTS is only sent over HTTPS.
+		resp = app.Invoke(http.MethodGet, "/", nil)
+		if hsts := resp.Header.Get("Strict-Transport-Security"); hsts != "" {
+			t.Fatalf("expected no HSTS over HTTP but got %s", hsts)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+		app.UseStd(SecureHeaders(SecureHeadersOptions{
+			HSTS:                  time.Hour,
+			HSTSIncludeSubdomains: true,
+			HSTSPreload:           true,
+			FrameOptions:          "DENY",
+			ReferrerPolicy:        "-",
+			CSP:                   NewCSP().Add("default-src", "'self'").Add("upgrade-insecure-requests"),
+			CSPReportOnly:         true,
+		}))
+		app.Get("/", func(c *Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+
+		resp := app.Invoke(http.MethodGet, "https://example.com/", nil)
+		for header, expected := range map[string]string{
+			"Strict-Transport-Security":           "max-age=3600; includeSubDomains; preload",
+			"X-Frame-Options":                     "DENY",
+			"Referrer-Policy":                     "",
+			"Content-Security-Policy-Report-Only": "default-src 'self'; upgrade-insecure-requests",
+		} {
+			if actual := resp.Header.Get(header); actual != expected {
+				t.Fatalf("expected %s to be %q but got %q", header, expected, actual)
+			}
+		}
+	})
+
+	t.Run("nonce", func(t *testing.T) {
+		app := New(Option{TemplateDir: "testdata/templates", SkipServeFiles: true})
+		app.UseStd(SecureHeaders(SecureHeadersOptions{
+			CSP: NewCSP().
+				Add("default-src", "'self'").
+				Add("script-src", "'self'", CSPNonce),
+		}))
+		app.Get("/", func(c *Context) error {
+			return c.Render("secure/inline", nil)
+		})
+
+		policy := regexp.MustCompile(`^default-src 'self'; script-src 'self' 'nonce-([A-Za-z0-9_-]{22})'$`)
+		var nonces []string
+		for i := 0; i < 2; i++ {
+			resp := app.Invoke(http.MethodGet, "/", nil)
+			m := policy.FindStringSubmatch(resp.Header.Get("Content-Security-Policy"))
+			if m == nil {
+				t.Fatalf("expected a policy with a nonce but got %s", resp.Header.Get("Content-Security-Policy"))
+			}
+			if expected := `<script nonce="` + m[1] + `">`; !strings.Contains(resp.String(), expected) {
+				t.Fatalf("expected the page to contain %s but got %s", expected, resp.String())
+			}
+			nonces = append(nonces, m[1])
+		}
+		if nonces[0] == nonces[1] {
+			t.Fatalf("expected a new nonce for each request but got %s twice", nonces[0])
+		}
+	})
+}
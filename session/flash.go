@@ -0,0 +1,56 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The levels of flash messages with typed helpers.
+const (
+	FlashNotice  = "notice"
+	FlashAlert   = "alert"
+	FlashError   = "error"
+	FlashSuccess = "success"
+)
+
+// A Flash is a message that is shown once, i.e., on the page that is
+// rendered after a redirect, and then deleted.
+type Flash struct {
+	// Level is the kind of message, i.e., FlashNotice or FlashError.
+	Level string
+
+	// Message is the text of the message.
+	Message string
+
+	// Metadata holds any additional data about the message, i.e., the ID
+	// of a record to link to. Default is nil.
+	Metadata map[string]interface{}
+}
+
+// String returns the message of the flash, so that templates can print a
+// flash with {{ . }}.
+func (f Flash) String() string {
+	return f.Message
+}
+
+// NewFlash returns a flash message with the given level, and the given
+// value formatted as its message.
+func NewFlash(level string, value interface{}) Flash {
+	return Flash{Level: level, Message: fmt.Sprint(value)}
+}
+
+// migrateFlashes converts flash messages saved in the map used by previous
+// versions into flash messages, ordered by their level.
+func migrateFlashes(legacy map[string]interface{}) []Flash {
+	levels := make([]string, 0, len(legacy))
+	for level := range legacy {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+
+	flashes := make([]Flash, 0, len(levels))
+	for _, level := range levels {
+		flashes = append(flashes, NewFlash(level, legacy[level]))
+	}
+	return flashes
+}
@@ -0,0 +1,230 @@
+package session
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisStoreOptions configure a RedisStore.
+type RedisStoreOptions struct {
+	// Prefix is prepended to session IDs to form their keys. Default is
+	// "session:".
+	Prefix string
+
+	// Password authenticates each connection with AUTH. Default is "",
+	// meaning connections aren't authenticated.
+	Password string
+
+	// DB is the database selected on each connection. Default is 0.
+	DB int
+
+	// Dial opens a connection to the given address, i.e., with tls.Dial.
+	// Default is a plain TCP connection.
+	Dial func(addr string) (net.Conn, error)
+
+	// Timeout limits how long each command may take. Default is 5 seconds.
+	Timeout time.Duration
+
+	// MaxIdle is the number of idle connections kept open for reuse.
+	// Default is 8.
+	MaxIdle int
+}
+
+// A RedisStore is a Store that keeps sessions in Redis, so that they're
+// shared between processes. Redis deletes sessions when they expire, so GC
+// does nothing.
+type RedisStore struct {
+	addr string
+	opts RedisStoreOptions
+	idle chan *redisConn
+}
+
+// NewRedisStore returns a RedisStore for the Redis server at the given
+// address, i.e., "localhost:6379". Connections are opened as they're needed.
+func NewRedisStore(addr string, opts ...RedisStoreOptions) *RedisStore {
+	var o RedisStoreOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.Prefix == "" {
+		o.Prefix = "session:"
+	}
+	if o.Dial == nil {
+		o.Dial = func(addr string) (net.Conn, error) {
+			return net.Dial("tcp", addr)
+		}
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.MaxIdle == 0 {
+		o.MaxIdle = 8
+	}
+
+	return &RedisStore{addr: addr, opts: o, idle: make(chan *redisConn, o.MaxIdle)}
+}
+
+// Get implements Store.
+func (rs *RedisStore) Get(id string) ([]byte, error) {
+	reply, err := rs.do("GET", rs.opts.Prefix+id)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("session: unexpected Redis reply %v", reply)
+	}
+	return data, nil
+}
+
+// Set implements Store.
+func (rs *RedisStore) Set(id string, data []byte, expires time.Time) error {
+	ttl := time.Until(expires).Milliseconds()
+	if ttl <= 0 {
+		return rs.Delete(id)
+	}
+	_, err := rs.do("SET", rs.opts.Prefix+id, string(data), "PX", strconv.FormatInt(ttl, 10))
+	return err
+}
+
+// Delete implements Store.
+func (rs *RedisStore) Delete(id string) error {
+	_, err := rs.do("DEL", rs.opts.Prefix+id)
+	return err
+}
+
+// GC implements Store.
+func (rs *RedisStore) GC(now time.Time) error {
+	return nil
+}
+
+// A redisError is an error reply sent by Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "session: Redis error: " + string(e)
+}
+
+// redisConn is a connection to Redis.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// conn returns an idle connection, or opens a new one.
+func (rs *RedisStore) conn() (*redisConn, error) {
+	select {
+	case c := <-rs.idle:
+		return c, nil
+	default:
+	}
+
+	nc, err := rs.opts.Dial(rs.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	if rs.opts.Password != "" {
+		if _, err := rs.command(c, "AUTH", rs.opts.Password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if rs.opts.DB != 0 {
+		if _, err := rs.command(c, "SELECT", strconv.Itoa(rs.opts.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends the given command on an idle connection, and returns its reply.
+func (rs *RedisStore) do(args ...string) (interface{}, error) {
+	c, err := rs.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := rs.command(c, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection may be left in the middle of a reply.
+		c.Close()
+		return nil, err
+	}
+
+	select {
+	case rs.idle <- c:
+	default:
+		c.Close()
+	}
+	return reply, err
+}
+
+// command sends the given command on the given connection, and reads its
+// reply.
+func (rs *RedisStore) command(c *redisConn, args ...string) (interface{}, error) {
+	if err := c.SetDeadline(time.Now().Add(rs.opts.Timeout)); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.r)
+}
+
+// readRedisReply reads a reply in the Redis serialization protocol. Simple
+// strings are returned as strings, bulk strings as byte slices, integers as
+// int64s, and null bulk strings as nil. Arrays aren't supported, as none of
+// the commands used by RedisStore reply with them.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("session: invalid Redis reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, redisError(value)
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("session: invalid Redis reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	}
+	return nil, fmt.Errorf("session: unsupported Redis reply %q", line)
+}
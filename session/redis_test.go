@@ -0,0 +1,172 @@
+package session
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// fakeRedis is a Redis server that supports the commands used by RedisStore.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeRedis{ln: ln, password: password, values: make(map[string]string), ttls: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ := r.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			b := make([]byte, size+2)
+			io.ReadFull(r, b)
+			args[i] = string(b[:size])
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[1] == f.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "GET":
+			v, ok := f.values[args[1]]
+			reply = "$-1\r\n"
+			if ok {
+				reply = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			}
+		case args[0] == "SET":
+			f.values[args[1]] = args[2]
+			f.ttls[args[1]] = args[4]
+			reply = "+OK\r\n"
+		case args[0] == "DEL":
+			delete(f.values, args[1])
+			reply = ":1\r\n"
+		}
+		f.mu.Unlock()
+
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	t.Parallel()
+
+	redis := newFakeRedis(t, "secret")
+	store := NewRedisStore(redis.ln.Addr().String(), RedisStoreOptions{Password: "secret", DB: 2})
+	s := New(securecookie.GenerateRandomKey(32), Options{Store: store})
+
+	rr := httptest.NewRecorder()
+	s.Set(rr, httptest.NewRequest(http.MethodGet, "/", nil), "key", "value")
+	cookie := rr.Result().Cookies()[0]
+
+	t.Run("the session is loaded from Redis", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		if v := s.Get(req, "key"); v != "value" {
+			t.Fatalf("expected value but got %v", v)
+		}
+	})
+
+	t.Run("sessions are saved with an expiry", func(t *testing.T) {
+		redis.mu.Lock()
+		defer redis.mu.Unlock()
+
+		if len(redis.values) != 1 {
+			t.Fatalf("expected 1 session but got %d", len(redis.values))
+		}
+		for key, ttl := range redis.ttls {
+			if !strings.HasPrefix(key, "session:") {
+				t.Fatalf("expected the key to be prefixed but got %s", key)
+			}
+			if ms, _ := strconv.ParseInt(ttl, 10, 64); ms <= 0 || ms > int64(defaultMaxAge)*1000 {
+				t.Fatalf("expected a TTL of at most the MaxAge but got %s", ttl)
+			}
+		}
+		if strings.Join(redis.commands[:2], " ") != "AUTH SELECT" {
+			t.Fatalf("expected the connection to be authenticated but got %v", redis.commands)
+		}
+	})
+
+	t.Run("connections are reused", func(t *testing.T) {
+		redis.mu.Lock()
+		defer redis.mu.Unlock()
+
+		var auths int
+		for _, cmd := range redis.commands {
+			if cmd == "AUTH" {
+				auths++
+			}
+		}
+		if auths != 1 {
+			t.Fatalf("expected 1 connection but got %d", auths)
+		}
+	})
+
+	t.Run("expired sessions are deleted", func(t *testing.T) {
+		store.Set("expired", []byte("data"), time.Now().Add(time.Minute))
+		store.Set("expired", []byte("data"), time.Now().Add(-time.Minute))
+
+		if data, err := store.Get("expired"); err != nil || data != nil {
+			t.Fatalf("expected no data but got %s, %v", data, err)
+		}
+	})
+
+	t.Run("Redis errors are returned", func(t *testing.T) {
+		store := NewRedisStore(redis.ln.Addr().String(), RedisStoreOptions{Password: "wrong"})
+		if _, err := store.Get("id"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+			t.Fatalf("expected an authentication error but got %v", err)
+		}
+	})
+}
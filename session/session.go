@@ -1,10 +1,15 @@
 package session
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/gob"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/securecookie"
@@ -12,6 +17,8 @@ import (
 
 type sessionCtxKeyType struct{}
 
+type bufferCtxKeyType struct{}
+
 const (
 	defaultSessionName = "_session"
 	defaultMaxAge      = 86400 * 365
@@ -19,6 +26,7 @@ const (
 
 var (
 	sessionCtxKey = sessionCtxKeyType{}
+	bufferCtxKey  = bufferCtxKeyType{}
 )
 
 func init() {
@@ -26,13 +34,54 @@ func init() {
 	// successfully save session data in the session.
 	gob.Register(map[string]interface{}{})
 	gob.Register(&session{})
+	gob.Register(time.Time{})
+}
+
+// Register registers the type of the given value, so that values of that
+// type can be saved in the session, i.e.,
+//
+//	func init() {
+//		session.Register(User{})
+//	}
+//
+// Types must be registered before a session containing them is read, so
+// Register should be called from an init function. Values passed to Set are
+// registered automatically. Register panics if a different type was already
+// registered under the same name.
+func Register(value interface{}) {
+	gob.Register(value)
+}
+
+// register registers the type of the given value if it isn't already
+// registered. Types registered under a different name are left as they are.
+func register(value interface{}) {
+	if value == nil {
+		return
+	}
+	defer func() { recover() }()
+	gob.Register(value)
+}
+
+// GetAs returns the session value with the given key as a T. It returns
+// false if there is no such value, or if the value isn't a T.
+func GetAs[T any](s *Session, r *http.Request, key string) (T, bool) {
+	v, ok := s.Get(r, key).(T)
+	return v, ok
 }
 
 // A Session manages setting and getting data from the cookie that stores the
 // session data.
 type Session struct {
-	sc   *securecookie.SecureCookie
-	name string
+	sc     *securecookie.SecureCookie
+	legacy *securecookie.SecureCookie
+	name   string
+	maxAge int
+	clock  func() time.Time
+	store  Store
+
+	// regenerateKeys are the keys that regenerate the session when they
+	// are set or deleted.
+	regenerateKeys map[string]bool
 }
 
 // Options to customize the behaviour of the session.
@@ -43,6 +92,21 @@ type Options struct {
 	// MaxAge of the cookie before expiry (default is 365 days). Set it to
 	// -1 for no expiry.
 	MaxAge int
+
+	// Clock returns the current time used to compute cookie expiry times.
+	// Sessions saved in a Store are kept by the real time. Default is
+	// time.Now.
+	Clock func() time.Time
+
+	// Store saves session data on the server, so that the cookie only holds
+	// the session ID, i.e., NewMemoryStore(). Default is nil, meaning the
+	// session data is saved in the cookie.
+	Store Store
+
+	// RegenerateKeys are the keys of values that grant privileges, i.e.,
+	// "user_id" or "role". Setting or deleting one of them regenerates the
+	// session, as with Regenerate. Default is nil.
+	RegenerateKeys []string
 }
 
 // New creates a new session with the given key.
@@ -63,38 +127,151 @@ func New(secret []byte, opts ...Options) *Session {
 	case -1:
 		o.MaxAge = 0
 	}
+	if o.Clock == nil {
+		o.Clock = time.Now
+	}
+
+	// Cookies are encrypted with a key derived from the secret, so that the
+	// session data can't be read by users. Cookies that were only signed
+	// are still accepted, and are encrypted the next time they're written.
+	//
+	// Expiry is checked against the session's own expiry time, but the
+	// cookie's timestamp still limits sessions without one, i.e.,
+	// browser-session cookies, to the longer of MaxAge and the default.
+	sc := securecookie.New(secret, encryptionKey(secret))
+	sc.MaxAge(codecMaxAge(o.MaxAge))
+
+	// Signed-only cookies keep the limit they were written with.
+	legacy := securecookie.New(secret, nil)
+	if o.MaxAge > 0 {
+		legacy.MaxAge(o.MaxAge)
+	} else {
+		legacy.MaxAge(defaultMaxAge)
+	}
+
+	regenerateKeys := make(map[string]bool, len(o.RegenerateKeys))
+	for _, key := range o.RegenerateKeys {
+		regenerateKeys[key] = true
+	}
 
-	sc := securecookie.New(secret, nil)
-	sc.MaxAge(o.MaxAge)
 	return &Session{
-		sc:   sc,
-		name: o.Name,
+		sc:     sc,
+		legacy: legacy,
+		name:   o.Name,
+		maxAge: o.MaxAge,
+		clock:  o.Clock,
+		store:  o.Store,
+
+		regenerateKeys: regenerateKeys,
 	}
 }
 
+// codecMaxAge returns the age in seconds after which a cookie is rejected,
+// however long its session lasts, for the given MaxAge option.
+func codecMaxAge(maxAge int) int {
+	if maxAge > defaultMaxAge {
+		return maxAge
+	}
+	return defaultMaxAge
+}
+
+// encryptionKey derives the AES-256 key used to encrypt session cookies from
+// the given secret.
+func encryptionKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("seatbelt session encryption"))
+	return mac.Sum(nil)
+}
+
+// decode decodes the given cookie value into dst, accepting both encrypted
+// and signed-only cookies.
+func (s *Session) decode(value string, dst interface{}) error {
+	return securecookie.DecodeMulti(s.name, value, dst, s.sc, s.legacy)
+}
+
 // A session holds the session data. It contains two fields:
 //
 // - "data" for long-lived session data that persists between requests,
 //
-// - "flashes" for session data that should be deleted as soon as it is shown.
+// - "messages" for flash messages that should be deleted as soon as they are
+// shown.
 type session struct {
-	Data    map[string]interface{}
+	Data     map[string]interface{}
+	Messages []Flash
+
+	// Flashes holds flash messages saved by previous versions, which are
+	// migrated to Messages when the session is read.
 	Flashes map[string]interface{}
+
+	// Expires is when the session expires, or the zero time if it expires
+	// when the browser is closed. TTL overrides the session's MaxAge if it
+	// is set, where a negative TTL means the session expires when the
+	// browser is closed. KeyExpires holds the expiry times of values set
+	// with SetWithTTL.
+	Expires    time.Time
+	TTL        time.Duration
+	KeyExpires map[string]time.Time
+
+	// id is the ID of the session in the Store, if there is one.
+	id string
+
+	// now holds the flash messages that are only shown during the current
+	// request, and are never saved.
+	now []Flash
+
+	// dirty is true if the session has changed since its cookie was last
+	// written.
+	dirty bool
 }
 
-// init ensures that both of the underlying maps have been initialized.
+// init ensures that the data map has been initialized, and migrates flash
+// messages saved by previous versions.
 func (s *session) init() {
 	if s.Data == nil {
 		s.Data = make(map[string]interface{})
 	}
-	if s.Flashes == nil {
-		s.Flashes = make(map[string]interface{})
+	if len(s.Flashes) > 0 {
+		s.Messages = append(migrateFlashes(s.Flashes), s.Messages...)
+	}
+	s.Flashes = nil
+}
+
+// expire clears the session if it has expired, and deletes any values that
+// have expired.
+func (s *Session) expire(ss *session) {
+	now := s.clock()
+	if !ss.Expires.IsZero() && now.After(ss.Expires) {
+		*ss = session{id: ss.id}
+		ss.init()
+		return
+	}
+	for key, expires := range ss.KeyExpires {
+		if now.After(expires) {
+			delete(ss.Data, key)
+			delete(ss.KeyExpires, key)
+		}
+	}
+}
+
+// cookieMaxAge returns the MaxAge in seconds of the cookie for the given
+// session, where 0 means the cookie expires when the browser is closed.
+func (s *Session) cookieMaxAge(ss *session) int {
+	switch {
+	case ss.TTL > 0:
+		return int(ss.TTL / time.Second)
+	case ss.TTL < 0:
+		return 0
+	default:
+		return s.maxAge
 	}
 }
 
 // fromReq returns the map of session values from the request. It will
 // never return a nil map, instead, the map will be an initialized empty map
 // in the case where the session has no data.
+//
+// The decoded session is cached on the request's context, so that the cookie
+// is only decoded once per request.
 func (s *Session) fromReq(r *http.Request) *session {
 	// Fastpath: if the context has already been decoded, access the
 	// underlying map and return the value associated with the given key.
@@ -114,35 +291,152 @@ func (s *Session) fromReq(r *http.Request) *session {
 		// key is guaranteed to be nil, so we return nil.
 		ss := &session{}
 		ss.init()
+		cache(r, ss)
 		return ss
 	}
 
 	ss := &session{}
-	if err := s.sc.Decode(s.name, cookie.Value, ss); err != nil {
+	if s.store != nil {
+		if err := s.load(cookie.Value, ss); err != nil {
+			log.Println("[error] failed to load session from store:", err)
+		}
+	} else if err := s.decode(cookie.Value, ss); err != nil {
 		log.Println("[error] failed to decode session from cookie:", err)
-		ss.init()
-		return ss
 	}
+	ss.init()
+	s.expire(ss)
+	cache(r, ss)
 	return ss
 }
 
+// cache saves the given decoded session on the request's context.
+func cache(r *http.Request, ss *session) {
+	*r = *r.WithContext(context.WithValue(r.Context(), sessionCtxKey, ss))
+}
+
+// load decodes the session ID from the given cookie value, and loads the
+// data of that session from the store into ss.
+func (s *Session) load(value string, ss *session) error {
+	var id string
+	if err := s.decode(value, &id); err != nil {
+		return err
+	}
+
+	data, err := s.store.Get(id)
+	if err != nil || data == nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(ss); err != nil {
+		return err
+	}
+	ss.id = id
+	return nil
+}
+
+// save saves the given session in the store, and returns the session ID to
+// set as the cookie's value. A new ID is generated for new sessions.
+func (s *Session) save(ss *session, expires time.Time) (string, error) {
+	if ss.id == "" {
+		ss.id = base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ss); err != nil {
+		return "", err
+	}
+	if err := s.store.Set(ss.id, buf.Bytes(), expires); err != nil {
+		return "", err
+	}
+	return ss.id, nil
+}
+
 // saveCtx saves a map of session data in the current request's context. It
-// also updates the Set-Cookie header of the
+// also updates the Set-Cookie header of the response, unless the request is
+// buffered, in which case the cookie is only written by Flush.
 func (s *Session) saveCtx(w http.ResponseWriter, r *http.Request, session *session) {
 	ctx := context.WithValue(r.Context(), sessionCtxKey, session)
 	r2 := r.Clone(ctx)
 	*r = *r2
 
-	encoded, err := s.sc.Encode(s.name, session)
+	if r.Context().Value(bufferCtxKey) != nil {
+		session.dirty = true
+		return
+	}
+	s.writeCookie(w, session)
+}
+
+// Buffered returns a shallow copy of r on which changes to the session are
+// buffered, instead of writing a cookie for each change. The cookie is
+// written once by Flush, which must be called before the response header is
+// written.
+func (s *Session) Buffered(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), bufferCtxKey, true))
+}
+
+// Flush writes the session cookie of a buffered request if the session has
+// changed since the cookie was last written.
+func (s *Session) Flush(w http.ResponseWriter, r *http.Request) {
+	ss, ok := r.Context().Value(sessionCtxKey).(*session)
+	if !ok || !ss.dirty {
+		return
+	}
+	ss.dirty = false
+	s.writeCookie(w, ss)
+}
+
+// writeCookie encodes the given session, and sets it as the cookie on w,
+// replacing any session cookie that was set before.
+func (s *Session) writeCookie(w http.ResponseWriter, session *session) {
+	maxAge := s.cookieMaxAge(session)
+	session.Expires = time.Time{}
+	if maxAge > 0 {
+		session.Expires = s.clock().UTC().Add(time.Duration(maxAge) * time.Second)
+	}
+
+	var value interface{} = session
+	if s.store != nil {
+		// Sessions that expire when the browser is closed are kept in the
+		// store for the default MaxAge. Stores expire sessions by the real
+		// time, as Redis does, rather than by the session's clock.
+		keep := defaultMaxAge
+		if maxAge > 0 {
+			keep = maxAge
+		}
+		expires := time.Now().UTC().Add(time.Duration(keep) * time.Second)
+
+		id, err := s.save(session, expires)
+		if err != nil {
+			log.Println("error saving session:", err)
+			return
+		}
+		value = id
+	}
+
+	encoded, err := s.sc.Encode(s.name, value)
 	if err != nil {
 		log.Println("error encoding cookie:", err)
 		return
 	}
 
+	// Remove the cookie set by a previous write, so that the response only
+	// contains the latest session.
+	header := w.Header()
+	cookies := header["Set-Cookie"][:0]
+	for _, c := range header["Set-Cookie"] {
+		if !strings.HasPrefix(c, s.name+"=") {
+			cookies = append(cookies, c)
+		}
+	}
+	if len(cookies) == 0 {
+		header.Del("Set-Cookie")
+	} else {
+		header["Set-Cookie"] = cookies
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     s.name,
-		MaxAge:   defaultMaxAge,
-		Expires:  time.Now().UTC().Add(time.Duration(defaultMaxAge * time.Second)),
+		MaxAge:   maxAge,
+		Expires:  session.Expires,
 		Value:    encoded,
 		Path:     "/",
 		HttpOnly: true,
@@ -165,47 +459,153 @@ func (s *Session) List(r *http.Request) map[string]interface{} {
 
 // Set sets or updates the given value on the session.
 func (s *Session) Set(w http.ResponseWriter, r *http.Request, key string, value interface{}) {
+	register(value)
+
 	data := s.fromReq(r)
+	if s.regenerateKeys[key] {
+		s.rotate(data)
+	}
 	data.Data[key] = value
+	delete(data.KeyExpires, key)
+	s.saveCtx(w, r, data)
+}
+
+// SetWithTTL sets or updates the given value on the session, deleting it
+// once the given ttl has passed.
+func (s *Session) SetWithTTL(w http.ResponseWriter, r *http.Request, key string, value interface{}, ttl time.Duration) {
+	register(value)
+
+	data := s.fromReq(r)
+	if s.regenerateKeys[key] {
+		s.rotate(data)
+	}
+	data.Data[key] = value
+	if data.KeyExpires == nil {
+		data.KeyExpires = make(map[string]time.Time)
+	}
+	data.KeyExpires[key] = s.clock().Add(ttl)
+	s.saveCtx(w, r, data)
+}
+
+// Expire sets how long the session lasts, overriding the MaxAge option for
+// this session, i.e., 30 days for a "remember me" login. A ttl of 0 or less
+// makes the session cookie expire when the browser is closed. The expiry is
+// extended by ttl every time the session is written. Sessions that aren't
+// written for longer than the MaxAge option, or one year if that's longer,
+// expire regardless of ttl.
+func (s *Session) Expire(w http.ResponseWriter, r *http.Request, ttl time.Duration) {
+	data := s.fromReq(r)
+	data.TTL = ttl
+	if ttl <= 0 {
+		data.TTL = -1
+	}
 	s.saveCtx(w, r, data)
 }
 
 // Delete deletes and returns the session value with the given key.
 func (s *Session) Delete(w http.ResponseWriter, r *http.Request, key string) interface{} {
 	data := s.fromReq(r)
+	if s.regenerateKeys[key] {
+		s.rotate(data)
+	}
 	value := data.Data[key]
 	delete(data.Data, key)
+	delete(data.KeyExpires, key)
 	s.saveCtx(w, r, data)
 	return value
 }
 
-// Reset resets the session, deleting all values.
+// rotate deletes the given session from the store, if there is one, so that
+// it is saved under a new ID.
+func (s *Session) rotate(ss *session) {
+	if s.store == nil || ss.id == "" {
+		return
+	}
+	if err := s.store.Delete(ss.id); err != nil {
+		log.Println("error deleting session:", err)
+	}
+	ss.id = ""
+}
+
+// Regenerate re-issues the session cookie, keeping the session's values.
+// When the session is saved in a Store, the old session is deleted, and its
+// values are saved under a new session ID. Regenerate should be called when
+// a user logs in, so that a session ID planted before logging in, i.e., by
+// an attacker, can't be used to access the logged in session.
+func (s *Session) Regenerate(w http.ResponseWriter, r *http.Request) {
+	data := s.fromReq(r)
+	s.rotate(data)
+	s.saveCtx(w, r, data)
+}
+
+// Reset resets the session, deleting all values. When the session is saved
+// in a Store, the old session is deleted, and a new session ID is issued.
 func (s *Session) Reset(w http.ResponseWriter, r *http.Request) {
+	s.rotate(s.fromReq(r))
+
 	s.saveCtx(w, r, &session{
-		Data:    make(map[string]interface{}),
-		Flashes: make(map[string]interface{}),
+		Data: make(map[string]interface{}),
 	})
 }
 
-// Flash sets a flash message on a request.
-func (s *Session) Flash(w http.ResponseWriter, r *http.Request, key string, value interface{}) {
+// GC deletes the sessions that have expired from the Store. It does nothing
+// for sessions saved in cookies, which expire with the cookie.
+func (s *Session) GC() error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.GC(time.Now())
+}
+
+// Flash adds a flash message with the given level, and the given value
+// formatted as its message.
+func (s *Session) Flash(w http.ResponseWriter, r *http.Request, level string, value interface{}) {
+	s.AddFlash(w, r, NewFlash(level, value))
+}
+
+// AddFlash adds the given flash message.
+func (s *Session) AddFlash(w http.ResponseWriter, r *http.Request, f Flash) {
 	data := s.fromReq(r)
-	data.Flashes[key] = value
+	data.Messages = append(data.Messages, f)
 	s.saveCtx(w, r, data)
 }
 
-// Flashes returns all flash messages, clearing all saved flashes.
-func (s *Session) Flashes(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+// FlashNow adds a flash message that is only shown during the current
+// request, i.e., when re-rendering a form with validation errors. Unlike
+// Flash, it is never saved in the session, so it doesn't carry over to the
+// next request.
+func (s *Session) FlashNow(r *http.Request, level string, value interface{}) {
+	s.AddFlashNow(r, NewFlash(level, value))
+}
+
+// AddFlashNow adds the given flash message for the current request only.
+// See FlashNow.
+func (s *Session) AddFlashNow(r *http.Request, f Flash) {
 	data := s.fromReq(r)
+	data.now = append(data.now, f)
 
-	// Copy the map before clearing it from the session.
-	values := make(map[string]interface{})
-	for k, v := range data.Flashes {
-		values[k] = v
-	}
+	ctx := context.WithValue(r.Context(), sessionCtxKey, data)
+	*r = *r.Clone(ctx)
+}
 
-	data.Flashes = make(map[string]interface{})
+// Flashes returns all flash messages in the order in which they were added,
+// clearing all saved flashes. Flash messages added for the current request
+// only come last.
+func (s *Session) Flashes(w http.ResponseWriter, r *http.Request) []Flash {
+	data := s.fromReq(r)
 
-	s.saveCtx(w, r, data)
-	return values
+	flashes := make([]Flash, 0, len(data.Messages)+len(data.now))
+	flashes = append(flashes, data.Messages...)
+	flashes = append(flashes, data.now...)
+
+	// The session is only saved if saved flashes were removed, so that
+	// reading flashes doesn't create a session.
+	saved := len(data.Messages) > 0
+	data.Messages = nil
+	data.now = nil
+
+	if saved {
+		s.saveCtx(w, r, data)
+	}
+	return flashes
 }
@@ -1,10 +1,16 @@
 package session
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/securecookie"
@@ -120,11 +126,14 @@ func TestSessionFlashes(t *testing.T) {
 	values1 := s.Flashes(rr, req)
 	values2 := s.Flashes(rr, req)
 
-	if v := values1["flash1"].(string); v != "value1" {
-		t.Fatalf("expected value1, got %s", v)
+	if len(values1) != 2 {
+		t.Fatalf("expected 2 flashes, got %v", values1)
 	}
-	if v := values1["flash2"].(string); v != "value2" {
-		t.Fatalf("expected value2, got %s", v)
+	if f := values1[0]; f.Level != "flash1" || f.Message != "value1" {
+		t.Fatalf("expected flash1: value1, got %s: %s", f.Level, f.Message)
+	}
+	if f := values1[1]; f.Level != "flash2" || f.Message != "value2" {
+		t.Fatalf("expected flash2: value2, got %s: %s", f.Level, f.Message)
 	}
 	if len(values2) != 0 {
 		t.Fatalf("expected empty initialized session but got %v", values2)
@@ -190,3 +199,408 @@ func TestSessionManagerIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestSessionStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	s := New(securecookie.GenerateRandomKey(32), Options{Store: store})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	s.Set(rr, req, "key", strings.Repeat("x", 8192))
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie but got %d", len(cookies))
+	}
+	if len(cookies[0].Value) > 256 {
+		t.Fatalf("expected the cookie to only hold the session ID but got %d bytes", len(cookies[0].Value))
+	}
+
+	t.Run("the session is loaded from the store", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+
+		if v, _ := s.Get(req, "key").(string); len(v) != 8192 {
+			t.Fatalf("expected the stored value but got %d bytes", len(v))
+		}
+	})
+
+	t.Run("reset deletes the stored session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		s.Reset(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		if v := s.Get(req, "key"); v != nil {
+			t.Fatalf("expected the session to be deleted but got %v", v)
+		}
+	})
+
+	t.Run("reading no flashes doesn't create a session", func(t *testing.T) {
+		before := store.Len()
+		rr := httptest.NewRecorder()
+		if flashes := s.Flashes(rr, httptest.NewRequest(http.MethodGet, "/", nil)); len(flashes) != 0 {
+			t.Fatalf("expected no flashes but got %v", flashes)
+		}
+
+		if len(rr.Result().Cookies()) != 0 || store.Len() != before {
+			t.Fatalf("expected no session to be saved but got %d cookies and %d sessions", len(rr.Result().Cookies()), store.Len())
+		}
+	})
+
+	t.Run("expired sessions are not returned", func(t *testing.T) {
+		store.Set("expired", []byte("data"), time.Now().Add(-time.Minute))
+		if data, _ := store.Get("expired"); data != nil {
+			t.Fatalf("expected the expired session not to be returned")
+		}
+	})
+
+	t.Run("gc deletes expired sessions", func(t *testing.T) {
+		store := NewMemoryStore()
+		store.Set("expired", []byte("data"), time.Now().Add(-time.Minute))
+		store.Set("current", []byte("data"), time.Now().Add(time.Minute))
+		store.GC(time.Now())
+
+		if store.Len() != 1 {
+			t.Fatalf("expected the expired session to be deleted")
+		}
+	})
+}
+
+func TestSessionExpiry(t *testing.T) {
+	t.Parallel()
+
+	current := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := securecookie.GenerateRandomKey(32)
+	s := New(secret, Options{
+		MaxAge: 3600,
+		Clock:  func() time.Time { return current },
+	})
+
+	// cookie writes the session with the given func, and returns the
+	// resulting cookie.
+	cookie := func(fn func(w http.ResponseWriter, r *http.Request)) *http.Cookie {
+		rr := httptest.NewRecorder()
+		fn(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rr.Result().Cookies()[0]
+	}
+
+	t.Run("the configured MaxAge is used", func(t *testing.T) {
+		c := cookie(func(w http.ResponseWriter, r *http.Request) {
+			s.Set(w, r, "key", "value")
+		})
+		if c.MaxAge != 3600 {
+			t.Fatalf("expected MaxAge %d but got %d", 3600, c.MaxAge)
+		}
+	})
+
+	t.Run("values set with a TTL expire", func(t *testing.T) {
+		c := cookie(func(w http.ResponseWriter, r *http.Request) {
+			s.SetWithTTL(w, r, "key", "value", time.Minute)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		if v := s.Get(req, "key"); v != "value" {
+			t.Fatalf("expected value but got %v", v)
+		}
+
+		current = current.Add(2 * time.Minute)
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		if v := s.Get(req, "key"); v != nil {
+			t.Fatalf("expected the value to have expired but got %v", v)
+		}
+	})
+
+	t.Run("expire overrides the MaxAge", func(t *testing.T) {
+		c := cookie(func(w http.ResponseWriter, r *http.Request) {
+			s.Expire(w, r, 30*24*time.Hour)
+		})
+		if c.MaxAge != 30*24*3600 {
+			t.Fatalf("expected MaxAge %d but got %d", 30*24*3600, c.MaxAge)
+		}
+
+		c = cookie(func(w http.ResponseWriter, r *http.Request) {
+			s.Expire(w, r, 0)
+		})
+		if c.MaxAge != 0 || !c.Expires.IsZero() {
+			t.Fatalf("expected a browser session cookie but got MaxAge %d and Expires %s", c.MaxAge, c.Expires)
+		}
+	})
+
+	t.Run("browser-session cookies expire after the default MaxAge", func(t *testing.T) {
+		c := cookie(func(w http.ResponseWriter, r *http.Request) {
+			s.Set(w, r, "key", "value")
+			s.Expire(w, r, 0)
+		})
+
+		for _, tt := range []struct {
+			age      time.Duration
+			expected interface{}
+		}{
+			{age: 24 * time.Hour, expected: "value"},
+			{age: 2 * 365 * 24 * time.Hour, expected: nil},
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: c.Name, Value: backdate(t, secret, c.Name, c.Value, time.Now().Add(-tt.age))})
+			if v := s.Get(req, "key"); v != tt.expected {
+				t.Fatalf("expected %v for a cookie issued %s ago but got %v", tt.expected, tt.age, v)
+			}
+		}
+	})
+
+	t.Run("expired sessions are empty", func(t *testing.T) {
+		c := cookie(func(w http.ResponseWriter, r *http.Request) {
+			s.Set(w, r, "key", "value")
+		})
+
+		current = current.Add(2 * time.Hour)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		if v := s.Get(req, "key"); v != nil {
+			t.Fatalf("expected the session to have expired but got %v", v)
+		}
+	})
+}
+
+func TestSessionEncryption(t *testing.T) {
+	t.Parallel()
+
+	secret := securecookie.GenerateRandomKey(32)
+	s := New(secret)
+
+	t.Run("session data is not readable from the cookie", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.Set(rr, httptest.NewRequest(http.MethodGet, "/", nil), "key", "value")
+
+		cookie := rr.Result().Cookies()[0]
+		ss := &session{}
+		if err := securecookie.New(secret, nil).Decode(cookie.Name, cookie.Value, ss); err == nil {
+			t.Fatalf("expected the cookie not to be decodable without the encryption key")
+		}
+	})
+
+	t.Run("signed-only cookies are still read", func(t *testing.T) {
+		legacy := &session{Data: map[string]interface{}{"key": "value"}}
+		encoded, err := securecookie.New(secret, nil).Encode(defaultSessionName, legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultSessionName, Value: encoded})
+		if v := s.Get(req, "key"); v != "value" {
+			t.Fatalf("expected value but got %v", v)
+		}
+	})
+
+	t.Run("signed-only cookies expire after the MaxAge", func(t *testing.T) {
+		legacy := &session{Data: map[string]interface{}{"key": "value"}}
+		encoded, err := securecookie.New(secret, nil).Encode(defaultSessionName, legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: defaultSessionName, Value: backdate(t, secret, defaultSessionName, encoded, time.Now().AddDate(-2, 0, 0))})
+		if v := s.Get(req, "key"); v != nil {
+			t.Fatalf("expected the cookie to have expired but got %v", v)
+		}
+	})
+}
+
+func TestSessionGetAs(t *testing.T) {
+	t.Parallel()
+
+	s := New(securecookie.GenerateRandomKey(32))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	s.Set(rr, req, "name", "Jane")
+
+	if v, ok := GetAs[string](s, req, "name"); !ok || v != "Jane" {
+		t.Fatalf("expected Jane but got %v", v)
+	}
+	if _, ok := GetAs[int](s, req, "name"); ok {
+		t.Fatalf("expected a string not to be returned as an int")
+	}
+	if _, ok := GetAs[string](s, req, "missing"); ok {
+		t.Fatalf("expected a missing value not to be returned")
+	}
+}
+
+func TestSessionFlashNow(t *testing.T) {
+	t.Parallel()
+
+	s := New(securecookie.GenerateRandomKey(32))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	s.FlashNow(req, "error", "invalid")
+	if cookies := rr.Result().Cookies(); len(cookies) != 0 {
+		t.Fatalf("expected no cookies to be set but got %v", cookies)
+	}
+
+	values := s.Flashes(rr, req)
+	if len(values) != 1 || values[0].Message != "invalid" {
+		t.Fatalf("expected invalid, got %v", values)
+	}
+
+	// The flash must not be saved in the cookie written by Flashes.
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range rr.Result().Cookies() {
+		next.AddCookie(cookie)
+	}
+	if values := s.Flashes(httptest.NewRecorder(), next); len(values) != 0 {
+		t.Fatalf("expected no flashes in the next request but got %v", values)
+	}
+}
+
+func TestSessionLegacyFlashes(t *testing.T) {
+	t.Parallel()
+
+	secret := securecookie.GenerateRandomKey(32)
+	s := New(secret)
+
+	legacy := &session{
+		Data:    map[string]interface{}{},
+		Flashes: map[string]interface{}{"notice": "Saved"},
+	}
+	encoded, err := securecookie.New(secret, encryptionKey(secret)).Encode(defaultSessionName, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: defaultSessionName, Value: encoded})
+
+	flashes := s.Flashes(httptest.NewRecorder(), req)
+	if len(flashes) != 1 || flashes[0].Level != FlashNotice || flashes[0].Message != "Saved" {
+		t.Fatalf("expected the legacy flash to be migrated but got %v", flashes)
+	}
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	s := New(securecookie.GenerateRandomKey(32), Options{
+		Store:          store,
+		RegenerateKeys: []string{"user_id"},
+	})
+
+	// sessionID returns the session ID saved in the cookie written to rr.
+	sessionID := func(rr *httptest.ResponseRecorder) string {
+		cookies := rr.Result().Cookies()
+		var id string
+		if err := s.decode(cookies[len(cookies)-1].Value, &id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	rr := httptest.NewRecorder()
+	s.Set(rr, httptest.NewRequest(http.MethodGet, "/", nil), "cart", "1 item")
+	id := sessionID(rr)
+
+	t.Run("regenerate rotates the session ID and keeps the values", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(rr.Result().Cookies()[0])
+
+		next := httptest.NewRecorder()
+		s.Regenerate(next, req)
+
+		if newID := sessionID(next); newID == id {
+			t.Fatalf("expected a new session ID")
+		}
+		if data, _ := store.Get(id); data != nil {
+			t.Fatalf("expected the old session to be deleted")
+		}
+		if v := s.Get(req, "cart"); v != "1 item" {
+			t.Fatalf("expected the session values to be kept but got %v", v)
+		}
+	})
+
+	t.Run("privileged keys regenerate the session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		first := httptest.NewRecorder()
+		s.Set(first, req, "cart", "1 item")
+		before := sessionID(first)
+
+		next := httptest.NewRecorder()
+		s.Set(next, req, "user_id", 1)
+		if after := sessionID(next); after == before {
+			t.Fatalf("expected setting user_id to rotate the session ID")
+		}
+	})
+}
+
+func TestSessionBuffered(t *testing.T) {
+	t.Parallel()
+
+	s := New(securecookie.GenerateRandomKey(32))
+	rr := httptest.NewRecorder()
+	req := s.Buffered(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	s.Set(rr, req, "key1", "value1")
+	s.Set(rr, req, "key2", "value2")
+	if v := rr.Header().Values("Set-Cookie"); len(v) != 0 {
+		t.Fatalf("expected no cookie before Flush but got %v", v)
+	}
+
+	s.Flush(rr, req)
+	if v := rr.Header().Values("Set-Cookie"); len(v) != 1 {
+		t.Fatalf("expected 1 cookie after Flush but got %v", v)
+	}
+
+	next := httptest.NewRequest(http.MethodGet, "/", nil)
+	next.AddCookie(rr.Result().Cookies()[0])
+	if v := s.Get(next, "key2"); v != "value2" {
+		t.Fatalf("expected value2 but got %v", v)
+	}
+}
+
+func TestSessionDecodedOnce(t *testing.T) {
+	t.Parallel()
+
+	s := New(securecookie.GenerateRandomKey(32))
+	rr := httptest.NewRecorder()
+	s.Set(rr, httptest.NewRequest(http.MethodGet, "/", nil), "key", "value")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(rr.Result().Cookies()[0])
+	if v := s.Get(req, "key"); v != "value" {
+		t.Fatalf("expected value but got %v", v)
+	}
+
+	// Replace the cookie with one that can't be decoded. The cached session
+	// must still be returned.
+	req.Header.Set("Cookie", defaultSessionName+"=invalid")
+	if v := s.Get(req, "key"); v != "value" {
+		t.Fatalf("expected the cached session but got %v", v)
+	}
+}
+
+// backdate returns the given cookie value re-signed as if it had been issued
+// at the given time.
+func backdate(t *testing.T, secret []byte, name, value string, issued time.Time) string {
+	t.Helper()
+
+	b, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := bytes.SplitN(b, []byte("|"), 3)
+	if len(parts) != 3 {
+		t.Fatalf("expected a signed cookie value but got %s", b)
+	}
+
+	signed := []byte(fmt.Sprintf("%s|%d|%s", name, issued.Unix(), parts[1]))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signed)
+	signed = append(append(signed, '|'), mac.Sum(nil)...)
+	return base64.URLEncoding.EncodeToString(signed[len(name)+1:])
+}
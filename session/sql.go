@@ -0,0 +1,117 @@
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLStoreOptions configure a SQLStore.
+type SQLStoreOptions struct {
+	// Table is the name of the table that sessions are saved in. Default
+	// is "sessions".
+	Table string
+
+	// DollarPlaceholders writes query placeholders as $1, $2, and so on,
+	// i.e., for PostgreSQL. Default is false, meaning placeholders are
+	// written as ?, i.e., for MySQL and SQLite.
+	DollarPlaceholders bool
+}
+
+// A SQLStore is a Store that keeps sessions in a SQL database, so that they're
+// shared between processes. The table must be created beforehand, i.e.,
+//
+//	CREATE TABLE sessions (
+//		id      VARCHAR(64) PRIMARY KEY,
+//		data    BLOB NOT NULL,
+//		expires BIGINT NOT NULL
+//	);
+//	CREATE INDEX sessions_expires ON sessions (expires);
+//
+// where data is BYTEA for PostgreSQL, and expires is a Unix time in seconds.
+type SQLStore struct {
+	db *sql.DB
+
+	get, insert, delete, gc string
+}
+
+// NewSQLStore returns a SQLStore that saves sessions in the given database.
+func NewSQLStore(db *sql.DB, opts ...SQLStoreOptions) *SQLStore {
+	var o SQLStoreOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.Table == "" {
+		o.Table = "sessions"
+	}
+
+	// query replaces the ? placeholders of the given query if dollar
+	// placeholders are used.
+	query := func(q string) string {
+		if !o.DollarPlaceholders {
+			return q
+		}
+		var b strings.Builder
+		n := 0
+		for _, r := range q {
+			if r == '?' {
+				n++
+				b.WriteString("$" + strconv.Itoa(n))
+				continue
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+
+	return &SQLStore{
+		db:     db,
+		get:    query("SELECT data FROM " + o.Table + " WHERE id = ? AND expires > ?"),
+		insert: query("INSERT INTO " + o.Table + " (id, data, expires) VALUES (?, ?, ?)"),
+		delete: query("DELETE FROM " + o.Table + " WHERE id = ?"),
+		gc:     query("DELETE FROM " + o.Table + " WHERE expires <= ?"),
+	}
+}
+
+// Get implements Store.
+func (s *SQLStore) Get(id string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(s.get, id, time.Now().Unix()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Set implements Store.
+func (s *SQLStore) Set(id string, data []byte, expires time.Time) error {
+	// The session is replaced rather than upserted, as the syntax of
+	// upserts differs between databases.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.delete, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.insert, id, data, expires.Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete implements Store.
+func (s *SQLStore) Delete(id string) error {
+	_, err := s.db.Exec(s.delete, id)
+	return err
+}
+
+// GC implements Store.
+func (s *SQLStore) GC(now time.Time) error {
+	_, err := s.db.Exec(s.gc, now.Unix())
+	return err
+}
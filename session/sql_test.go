@@ -0,0 +1,165 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// fakeSQLRow is a row of the fake sessions table.
+type fakeSQLRow struct {
+	data    []byte
+	expires int64
+}
+
+// fakeSQL is a database/sql driver that runs the queries of SQLStore against
+// a map.
+type fakeSQL struct {
+	mu      sync.Mutex
+	rows    map[string]fakeSQLRow
+	queries []string
+}
+
+func (f *fakeSQL) Open(name string) (driver.Conn, error) { return fakeSQLConn{f}, nil }
+
+type fakeSQLConn struct{ f *fakeSQL }
+
+func (c fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeSQLStmt{f: c.f, query: query}, nil
+}
+func (c fakeSQLConn) Close() error              { return nil }
+func (c fakeSQLConn) Begin() (driver.Tx, error) { return c, nil }
+func (c fakeSQLConn) Commit() error             { return nil }
+func (c fakeSQLConn) Rollback() error           { return nil }
+
+type fakeSQLStmt struct {
+	f     *fakeSQL
+	query string
+}
+
+func (s fakeSQLStmt) Close() error  { return nil }
+func (s fakeSQLStmt) NumInput() int { return -1 }
+
+func (s fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	s.f.queries = append(s.f.queries, s.query)
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.f.rows[args[0].(string)] = fakeSQLRow{data: args[1].([]byte), expires: args[2].(int64)}
+	case strings.Contains(s.query, "WHERE id"):
+		delete(s.f.rows, args[0].(string))
+	case strings.Contains(s.query, "WHERE expires"):
+		for id, row := range s.f.rows {
+			if row.expires <= args[0].(int64) {
+				delete(s.f.rows, id)
+			}
+		}
+	default:
+		return nil, errors.New("unexpected query " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	s.f.queries = append(s.f.queries, s.query)
+	row, ok := s.f.rows[args[0].(string)]
+	if !ok || row.expires <= args[1].(int64) {
+		return &fakeSQLRows{}, nil
+	}
+	return &fakeSQLRows{data: [][]byte{row.data}}, nil
+}
+
+type fakeSQLRows struct{ data [][]byte }
+
+func (r *fakeSQLRows) Columns() []string { return []string{"data"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	dest[0], r.data = r.data[0], r.data[1:]
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	t.Parallel()
+
+	f := &fakeSQL{rows: make(map[string]fakeSQLRow)}
+	db := sql.OpenDB(driverConnector{f})
+	defer db.Close()
+
+	store := NewSQLStore(db, SQLStoreOptions{Table: "app_sessions", DollarPlaceholders: true})
+	s := New(securecookie.GenerateRandomKey(32), Options{Store: store})
+
+	rr := httptest.NewRecorder()
+	s.Set(rr, httptest.NewRequest(http.MethodGet, "/", nil), "key", "value")
+	cookie := rr.Result().Cookies()[0]
+
+	t.Run("the session is loaded from the table", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		if v := s.Get(req, "key"); v != "value" {
+			t.Fatalf("expected value but got %v", v)
+		}
+	})
+
+	t.Run("queries use the table and placeholders", func(t *testing.T) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		expected := "INSERT INTO app_sessions (id, data, expires) VALUES ($1, $2, $3)"
+		for _, q := range f.queries {
+			if q == expected {
+				return
+			}
+		}
+		t.Fatalf("expected %s but got %v", expected, f.queries)
+	})
+
+	t.Run("sessions are replaced when they're saved again", func(t *testing.T) {
+		store.Set("id", []byte("first"), time.Now().Add(time.Minute))
+		store.Set("id", []byte("second"), time.Now().Add(time.Minute))
+
+		if data, err := store.Get("id"); err != nil || string(data) != "second" {
+			t.Fatalf("expected second but got %s, %v", data, err)
+		}
+	})
+
+	t.Run("expired sessions are not returned, and deleted by GC", func(t *testing.T) {
+		store.Set("expired", []byte("data"), time.Now().Add(-time.Minute))
+		if data, err := store.Get("expired"); err != nil || data != nil {
+			t.Fatalf("expected no data but got %s, %v", data, err)
+		}
+
+		if err := store.GC(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.rows["expired"]; ok {
+			t.Fatal("expected the expired session to be deleted")
+		}
+	})
+}
+
+// driverConnector opens connections of a fake driver without registering it.
+type driverConnector struct{ d driver.Driver }
+
+func (c driverConnector) Connect(ctx context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c driverConnector) Driver() driver.Driver                            { return c.d }
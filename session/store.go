@@ -0,0 +1,101 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// A Store saves session data on the server, so that the session cookie only
+// holds a signed session ID instead of the data itself. This avoids the 4KB
+// cookie size limit. Sessions without a Store are saved in the cookie.
+// MemoryStore, RedisStore, and SQLStore are provided.
+//
+// Store implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the data saved for the session with the given ID. It
+	// returns nil data and a nil error if there is no such session, or if
+	// it has expired.
+	Get(id string) ([]byte, error)
+
+	// Set saves the data of the session with the given ID, which should be
+	// kept at least until the given expiry time.
+	Set(id string, data []byte, expires time.Time) error
+
+	// Delete deletes the session with the given ID.
+	Delete(id string) error
+
+	// GC deletes all sessions that expired before the given time.
+	GC(now time.Time) error
+}
+
+// storedSession is a session saved in a MemoryStore.
+type storedSession struct {
+	data    []byte
+	expires time.Time
+}
+
+// A MemoryStore is a Store that keeps sessions in memory. Sessions are lost
+// when the process exits, and aren't shared between processes, so it is
+// best suited to development, tests, and single-instance deployments.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]storedSession
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]storedSession)}
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(id string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Sessions that have expired are left for GC to delete.
+	s, ok := m.sessions[id]
+	if !ok || s.expires.Before(time.Now()) {
+		return nil, nil
+	}
+	return s.data, nil
+}
+
+// Set implements Store.
+func (m *MemoryStore) Set(id string, data []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[id] = storedSession{data: data, expires: expires}
+	return nil
+}
+
+// Delete implements Store.
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// GC implements Store.
+func (m *MemoryStore) GC(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if s.expires.Before(now) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of sessions in the store, including expired
+// sessions that haven't been deleted by GC yet.
+func (m *MemoryStore) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.sessions)
+}
@@ -0,0 +1,67 @@
+package seatbelt
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-seatbelt/seatbelt/session"
+)
+
+// SessionGCOptions configure how often expired sessions are deleted from the
+// application's SessionStore.
+type SessionGCOptions struct {
+	// Interval is how often expired sessions are deleted, i.e., every 10
+	// minutes. Collection stops when the application drains. Default is 0,
+	// meaning expired sessions are never deleted, i.e., for stores that
+	// expire sessions themselves, such as Redis with a TTL.
+	Interval time.Duration
+
+	// OnGC is called after each collection, i.e., in order to record the
+	// number of live sessions as a metric. Default is nil, meaning only
+	// failed collections are logged.
+	OnGC func(s SessionGCStats)
+}
+
+// SessionGCStats describe a single collection of expired sessions.
+type SessionGCStats struct {
+	// The number of sessions that were deleted, and that are left in the
+	// store, or -1 if the store doesn't report its size.
+	Deleted int
+	Live    int
+
+	// How long the collection took.
+	Duration time.Duration
+
+	// The error the collection failed with, if any.
+	Err error
+}
+
+// collectSessions deletes the expired sessions from the given store, and
+// reports the collection.
+func (a *App) collectSessions(store session.Store, onGC func(s SessionGCStats)) {
+	before := -1
+	counter, counted := store.(sessionCounter)
+	if counted {
+		before = counter.Len()
+	}
+
+	start := time.Now()
+	err := a.session.GC()
+	s := SessionGCStats{Deleted: -1, Live: -1, Duration: time.Since(start), Err: err}
+	if counted {
+		s.Live = counter.Len()
+		s.Deleted = before - s.Live
+		if s.Deleted < 0 {
+			// Sessions were created during the collection.
+			s.Deleted = 0
+		}
+	}
+
+	if onGC != nil {
+		onGC(s)
+		return
+	}
+	if err != nil {
+		log.Printf("[warning] seatbelt: failed to delete expired sessions: %v", err)
+	}
+}
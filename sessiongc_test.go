@@ -0,0 +1,47 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"testing"
+	"time"
+
+	"github.com/go-seatbelt/seatbelt/session"
+)
+
+func TestSessionGC(t *testing.T) {
+	store := session.NewMemoryStore()
+	store.Set("expired", []byte("data"), time.Now().Add(-time.Minute))
+	store.Set("live", []byte("data"), time.Now().Add(time.Hour))
+
+	collected := make(chan SessionGCStats, 1)
+	app := New(Option{
+		SkipServeFiles: true,
+		SessionStore:   store,
+		SessionGC: SessionGCOptions{
+			Interval: time.Millisecond,
+			OnGC: func(s SessionGCStats) {
+				select {
+				case collected <- s:
+				default:
+				}
+			},
+		},
+	})
+	defer app.Drain(stdcontext.Background())
+
+	select {
+	case s := <-collected:
+		if s.Err != nil {
+			t.Fatal(s.Err)
+		}
+		if s.Deleted != 1 || s.Live != 1 {
+			t.Fatalf("expected 1 deleted and 1 live session but got %d and %d", s.Deleted, s.Live)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected expired sessions to be collected")
+	}
+
+	if data, _ := store.Get("expired"); data != nil {
+		t.Fatalf("expected the expired session to be deleted")
+	}
+}
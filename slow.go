@@ -0,0 +1,117 @@
+package seatbelt
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// A RenderTiming records how long it took to render a template.
+type RenderTiming struct {
+	// The name of the rendered template.
+	Template string
+
+	// How long the render took.
+	Duration time.Duration
+
+	// Whether the render exceeded Option.SlowRenderThreshold.
+	Slow bool
+}
+
+// A SlowRequest describes a request that exceeded Option.SlowRequestThreshold,
+// or that rendered a template that exceeded Option.SlowRenderThreshold.
+type SlowRequest struct {
+	// The HTTP method and path of the request.
+	Method string
+	Path   string
+
+	// The route pattern that matched the request, i.e., "/users/{id}".
+	Route string
+
+	// How long the request took, including middleware.
+	Duration time.Duration
+
+	// The templates rendered during the request, in the order in which they
+	// were rendered.
+	Renders []RenderTiming
+}
+
+// String returns a single line description of the slow request, i.e.,
+//
+//	GET /users/{id} took 1.2s (render users/show 800ms)
+func (s SlowRequest) String() string {
+	var b strings.Builder
+	b.WriteString(s.Method + " " + s.Route + " took " + s.Duration.String())
+
+	if len(s.Renders) > 0 {
+		renders := make([]string, len(s.Renders))
+		for i, r := range s.Renders {
+			renders[i] = "render " + r.Template + " " + r.Duration.String()
+			if r.Slow {
+				renders[i] += " (slow)"
+			}
+		}
+		b.WriteString(" (" + strings.Join(renders, ", ") + ")")
+	}
+	return b.String()
+}
+
+// trace records the renders of a single request.
+type trace struct {
+	// The name of the template rendered to the response, if any.
+	rendered string
+
+	// The timings of all renders.
+	renders []RenderTiming
+}
+
+// timeRender records the duration of a template render started at the given
+// time.
+func (c *context) timeRender(name string, start time.Time) {
+	d := time.Since(start)
+	if c.metrics != nil {
+		c.metrics.observeRender(name, d)
+	}
+	if c.trace == nil {
+		return
+	}
+
+	c.trace.renders = append(c.trace.renders, RenderTiming{
+		Template: name,
+		Duration: d,
+		Slow:     c.slowRenderThreshold > 0 && d > c.slowRenderThreshold,
+	})
+}
+
+// reportSlow reports the request if it, or any of its renders, exceeded the
+// configured thresholds.
+func (a *App) reportSlow(c *Context, d time.Duration) {
+	slow := a.slowRequestThreshold > 0 && d > a.slowRequestThreshold
+	for _, r := range c.trace.renders {
+		slow = slow || r.Slow
+	}
+	if !slow {
+		return
+	}
+
+	route := c.r.URL.Path
+	if rctx := chi.RouteContext(c.r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+
+	s := SlowRequest{
+		Method:   c.r.Method,
+		Path:     a.Redact(c.r.URL.Path),
+		Route:    route,
+		Duration: d,
+		Renders:  c.trace.renders,
+	}
+
+	if a.onSlowRequest != nil {
+		a.onSlowRequest(s)
+		return
+	}
+	log.Printf("[warning] seatbelt: slow request: %s", s)
+}
@@ -0,0 +1,67 @@
+package seatbelt
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSlowRequest(t *testing.T) {
+	var reported []SlowRequest
+
+	app := New(Option{
+		TemplateDir:          filepath.Join("testdata", "templates"),
+		SkipServeFiles:       true,
+		SlowRequestThreshold: 50 * time.Millisecond,
+		SlowRenderThreshold:  time.Nanosecond,
+		OnSlowRequest: func(s SlowRequest) {
+			reported = append(reported, s)
+		},
+	})
+
+	app.Get("/fast", func(c *Context) error {
+		return c.NoContent()
+	})
+	app.Get("/slow/{id}", func(c *Context) error {
+		time.Sleep(60 * time.Millisecond)
+		return c.NoContent()
+	})
+	app.Get("/render", func(c *Context) error {
+		return c.Render("index", nil)
+	})
+
+	t.Run("fast requests are not reported", func(t *testing.T) {
+		reported = nil
+		app.Invoke(http.MethodGet, "/fast", nil)
+
+		if len(reported) != 0 {
+			t.Fatalf("expected no slow requests but got %v", reported)
+		}
+	})
+
+	t.Run("slow requests are reported with their route", func(t *testing.T) {
+		reported = nil
+		app.Invoke(http.MethodGet, "/slow/1", nil)
+
+		if len(reported) != 1 {
+			t.Fatalf("expected 1 slow request but got %d", len(reported))
+		}
+		if route := reported[0].Route; route != "/slow/{id}" {
+			t.Fatalf("expected /slow/{id} but got %s", route)
+		}
+	})
+
+	t.Run("slow renders are reported with their template", func(t *testing.T) {
+		reported = nil
+		app.Invoke(http.MethodGet, "/render", nil)
+
+		if len(reported) != 1 {
+			t.Fatalf("expected 1 slow request but got %d", len(reported))
+		}
+		renders := reported[0].Renders
+		if len(renders) != 1 || renders[0].Template != "index" || !renders[0].Slow {
+			t.Fatalf("expected a slow render of index but got %+v", renders)
+		}
+	})
+}
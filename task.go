@@ -0,0 +1,156 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// taskOutput is where the list of tasks and their usage are written.
+var taskOutput io.Writer = os.Stderr
+
+// A Task is a named maintenance script that runs with the application's
+// configuration and services, i.e., to backfill data or to purge accounts,
+// instead of a separate main package.
+type Task struct {
+	// Name identifies the task on the command line, i.e., "users:purge".
+	// Required.
+	Name string
+
+	// Description is a single line describing the task in the list of
+	// tasks.
+	Description string
+
+	// Flags defines the flags of the task on the given flag set, i.e.,
+	//
+	//	Flags: func(fs *flag.FlagSet) {
+	//		fs.BoolVar(&dryRun, "dry-run", false, "only print the accounts")
+	//	},
+	//
+	// Default is nil, meaning the task has no flags.
+	Flags func(fs *flag.FlagSet)
+
+	// Run runs the task with the arguments that follow its flags. Required.
+	Run func(ctx stdcontext.Context, args []string) error
+}
+
+// tasks holds the tasks registered with App.Task, shared between all
+// namespaces.
+type tasks struct {
+	mu    sync.RWMutex
+	tasks map[string]Task
+}
+
+// Task registers a task, which is run with RunTask. Task panics if a task with
+// the same name is already registered.
+func (a *App) Task(t Task) {
+	if t.Name == "" || t.Run == nil {
+		panic("seatbelt: attempting to Task() a task without a name or Run func")
+	}
+
+	a.tasks.mu.Lock()
+	defer a.tasks.mu.Unlock()
+
+	if _, ok := a.tasks.tasks[t.Name]; ok {
+		panic(fmt.Sprintf("seatbelt: task '%s' is already registered", t.Name))
+	}
+	if a.tasks.tasks == nil {
+		a.tasks.tasks = make(map[string]Task)
+	}
+	a.tasks.tasks[t.Name] = t
+}
+
+// Tasks returns the registered tasks, sorted by name.
+func (a *App) Tasks() []Task {
+	a.tasks.mu.RLock()
+	defer a.tasks.mu.RUnlock()
+
+	list := make([]Task, 0, len(a.tasks.tasks))
+	for _, t := range a.tasks.tasks {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// RunTask runs the task named by the first of the given arguments, with the
+// remaining arguments parsed as its flags. Without arguments, or with "help",
+// the registered tasks are listed. Applications usually run tasks from their
+// main package, i.e., as "myapp run users:purge -dry-run":
+//
+//	app := seatbelt.New()
+//	routes(app)
+//	tasks(app)
+//
+//	if len(os.Args) > 1 && os.Args[1] == "run" {
+//		if err := app.RunTask(ctx, os.Args[2:]); err != nil {
+//			log.Fatal(err)
+//		}
+//		return
+//	}
+func (a *App) RunTask(ctx stdcontext.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		a.listTasks()
+		return nil
+	}
+
+	a.tasks.mu.RLock()
+	t, ok := a.tasks.tasks[args[0]]
+	a.tasks.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("seatbelt: unknown task %s, run \"help\" to list tasks", args[0])
+	}
+
+	fs := flag.NewFlagSet(t.Name, flag.ContinueOnError)
+	fs.SetOutput(taskOutput)
+	fs.Usage = func() {
+		fmt.Fprintf(taskOutput, "Usage of %s:", t.Name)
+		if t.Description != "" {
+			fmt.Fprintf(taskOutput, " %s", t.Description)
+		}
+		fmt.Fprintln(taskOutput)
+		fs.PrintDefaults()
+	}
+	if t.Flags != nil {
+		t.Flags(fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return fmt.Errorf("seatbelt: invalid arguments for task %s: %w", t.Name, err)
+	}
+
+	if err := t.Run(ctx, fs.Args()); err != nil {
+		return fmt.Errorf("seatbelt: task %s failed: %w", t.Name, err)
+	}
+	return nil
+}
+
+// listTasks writes the names and descriptions of the registered tasks.
+func (a *App) listTasks() {
+	list := a.Tasks()
+	if len(list) == 0 {
+		fmt.Fprintln(taskOutput, "No tasks are registered.")
+		return
+	}
+
+	w := tabwriter.NewWriter(taskOutput, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Tasks:")
+	for _, t := range list {
+		if t.Description == "" {
+			fmt.Fprintf(w, "  %s\n", t.Name)
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", t.Name, t.Description)
+	}
+	w.Flush()
+}
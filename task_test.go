@@ -0,0 +1,76 @@
+package seatbelt
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunTask(t *testing.T) {
+	var out bytes.Buffer
+	defer func(w io.Writer) { taskOutput = w }(taskOutput)
+	taskOutput = &out
+
+	app := New(Option{SkipServeFiles: true})
+
+	var (
+		dryRun bool
+		purged []string
+	)
+	app.Task(Task{
+		Name:        "users:purge",
+		Description: "Purge the given accounts",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&dryRun, "dry-run", false, "only print the accounts")
+		},
+		Run: func(ctx stdcontext.Context, args []string) error {
+			purged = args
+			return nil
+		},
+	})
+	app.Namespace("/admin", func(app *App) {
+		app.Task(Task{
+			Name: "cache:clear",
+			Run: func(ctx stdcontext.Context, args []string) error {
+				return errors.New("cache unavailable")
+			},
+		})
+	})
+
+	t.Run("run with flags and args", func(t *testing.T) {
+		if err := app.RunTask(stdcontext.Background(), []string{"users:purge", "-dry-run", "a@example.com", "b@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if !dryRun || strings.Join(purged, ",") != "a@example.com,b@example.com" {
+			t.Fatalf("expected a dry run for both accounts but got %t %v", dryRun, purged)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		out.Reset()
+		if err := app.RunTask(stdcontext.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		expected := "Tasks:\n  cache:clear\n  users:purge  Purge the given accounts\n"
+		if out.String() != expected {
+			t.Fatalf("expected %q but got %q", expected, out.String())
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		err := app.RunTask(stdcontext.Background(), []string{"cache:clear"})
+		if err == nil || err.Error() != "seatbelt: task cache:clear failed: cache unavailable" {
+			t.Fatalf("expected the task to fail but got %v", err)
+		}
+		if err := app.RunTask(stdcontext.Background(), []string{"db:drop"}); err == nil {
+			t.Fatalf("expected an error for an unknown task")
+		}
+		if err := app.RunTask(stdcontext.Background(), []string{"users:purge", "-force"}); err == nil {
+			t.Fatalf("expected an error for an unknown flag")
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package seatbelt
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// TestModeOptions configures the deterministic behaviour enabled by
+// TestMode.
+type TestModeOptions struct {
+	// The seed used to derive the signing key and any other random values.
+	// Default is 0.
+	Seed int64
+
+	// The time the clock is frozen at. Default is midnight UTC on January 1st,
+	// 2100. It should be in the future, as the expiry times of session
+	// cookies are relative to it, and clients drop cookies that have
+	// already expired.
+	Now time.Time
+}
+
+// testModeState holds the configuration of an active TestMode. It is nil when
+// test mode is disabled.
+type testModeState struct {
+	key string
+	now time.Time
+}
+
+var (
+	testModeMu sync.RWMutex
+	testMode   *testModeState
+)
+
+// TestMode puts Seatbelt into a deterministic mode for reproducible tests.
+// While it is enabled, applications created with New:
+//
+// - use a signing key derived from the seed instead of reading the "SECRET"
+// environment variable or the "master.key" file, and never write a
+// "master.key" file to the working directory,
+//
+// - see a frozen clock, so session cookie expiry times are stable.
+//
+// TestMode should be called before New, usually from TestMain. It returns a
+// function that disables test mode again, i.e.,
+//
+//	func TestMain(m *testing.M) {
+//		restore := seatbelt.TestMode()
+//		code := m.Run()
+//		restore()
+//		os.Exit(code)
+//	}
+func TestMode(opts ...TestModeOptions) (restore func()) {
+	var o TestModeOptions
+	for _, opt := range opts {
+		o = opt
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	b := make([]byte, 32)
+	rand.New(rand.NewSource(o.Seed)).Read(b)
+
+	testModeMu.Lock()
+	prev := testMode
+	testMode = &testModeState{
+		key: hex.EncodeToString(b),
+		now: o.Now,
+	}
+	testModeMu.Unlock()
+
+	return func() {
+		testModeMu.Lock()
+		testMode = prev
+		testModeMu.Unlock()
+	}
+}
+
+// currentTestMode returns the active test mode configuration, or nil if test
+// mode is disabled.
+func currentTestMode() *testModeState {
+	testModeMu.RLock()
+	defer testModeMu.RUnlock()
+	return testMode
+}
+
+// now returns the current time, or the frozen time when test mode is enabled.
+func now() time.Time {
+	if tm := currentTestMode(); tm != nil {
+		return tm.now
+	}
+	return time.Now()
+}
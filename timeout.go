@@ -0,0 +1,218 @@
+package seatbelt
+
+import (
+	"bufio"
+	stdcontext "context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// timeoutWriter passes the response of a handler with a deadline through to
+// the underlying response writer, until the deadline is reached. The handler
+// has its own copy of the response headers, as it may still set them after
+// the timeout response has been written.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	ctx    stdcontext.Context
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+	discarded   bool
+}
+
+// expired reports whether the deadline has been reached, in which case the
+// handler's writes are discarded. The context's error is checked as well,
+// as the handler may see that the context is done before serveWithTimeout
+// does.
+func (tw *timeoutWriter) expired() bool {
+	if tw.ctx.Err() != nil {
+		tw.timedOut = true
+	}
+	if tw.timedOut {
+		tw.discarded = true
+	}
+	return tw.timedOut
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+// writeHeader copies the handler's headers to the response and writes them
+// with the given status code, unless they have already been written.
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+
+	h := tw.w.Header()
+	for k := range h {
+		if _, ok := tw.header[k]; !ok {
+			delete(h, k)
+		}
+	}
+	for k, v := range tw.header {
+		h[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying response writer does.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying response writer does.
+// The deadline no longer applies to hijacked connections.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := tw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("seatbelt: response writer does not implement http.Hijacker")
+	}
+	tw.wroteHeader = true
+	return h.Hijack()
+}
+
+// timeout stops the handler from writing to the response, and reports
+// whether the timeout response can still be written.
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.timedOut = true
+	return !tw.wroteHeader
+}
+
+// wasDiscarded reports whether any of the handler's writes were discarded.
+func (tw *timeoutWriter) wasDiscarded() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	return tw.discarded
+}
+
+// NoTimeout exempts the route from Option.RequestTimeout, i.e., for a large
+// export that is streamed with c.Stream:
+//
+//	app.Get("/exports/{id}", downloadExport).NoTimeout()
+//
+// Routes registered with WebSocket are always exempt.
+func (r *Route) NoTimeout() *Route {
+	r.app.routes.mu.Lock()
+	defer r.app.routes.mu.Unlock()
+
+	r.noTimeout = true
+	return r
+}
+
+// timesOut reports whether requests to the given route are served with the
+// request timeout.
+func (a *App) timesOut(route *Route) bool {
+	if a.requestTimeout <= 0 {
+		return false
+	}
+
+	a.routes.mu.RLock()
+	defer a.routes.mu.RUnlock()
+
+	return !route.noTimeout
+}
+
+// serveWithTimeout serves the request with the given handler, but responds
+// with 503 Service Unavailable once the request timeout has passed, without
+// waiting for the handler to return.
+//
+// The handler runs in its own goroutine, which is left to notice that the
+// request's context has been cancelled. Until it does, its writes to the
+// response fail with http.ErrHandlerTimeout, so that a handler stalled on a
+// slow downstream call can't hold the client's connection open, i.e., while
+// Turbo shows its progress bar.
+func (a *App) serveWithTimeout(w http.ResponseWriter, r *http.Request, handle func(c *Context) error) {
+	ctx, cancel := stdcontext.WithTimeout(r.Context(), a.requestTimeout)
+	defer cancel()
+
+	timed := r.WithContext(ctx)
+	tw := &timeoutWriter{w: w, header: w.Header().Clone(), ctx: ctx}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				panicked <- v
+			}
+		}()
+		a.serveContext(tw, timed, handle)
+		close(done)
+	}()
+
+	select {
+	case v := <-panicked:
+		panic(v)
+
+	case <-done:
+		// A handler that returned after the deadline had its response
+		// discarded.
+		if !tw.wasDiscarded() {
+			return
+		}
+
+	case <-ctx.Done():
+	}
+
+	// The response is cut off if the handler has begun writing it, and
+	// isn't needed if the client has gone away.
+	if !errors.Is(ctx.Err(), stdcontext.DeadlineExceeded) || !tw.timeout() {
+		return
+	}
+
+	// The handler may still be reading the request body.
+	r = r.WithContext(r.Context())
+	r.Body = http.NoBody
+	a.serveContextWith(w, r, func(c *Context) error {
+		message := "The request took too long, please try again."
+		if acceptsJSON(c.r) {
+			return c.Problem(http.StatusServiceUnavailable, "", message)
+		}
+		a.renderErrorPage(c, http.StatusServiceUnavailable, message, nil)
+		return nil
+	}, nil)
+}
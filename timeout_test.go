@@ -0,0 +1,89 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+		RequestTimeout: 20 * time.Millisecond,
+	})
+
+	stalled := make(chan [2]error, 1)
+	app.Get("/stall", func(c *Context) error {
+		<-c.Request().Context().Done()
+		_, err := c.Response().Write([]byte("too late"))
+		stalled <- [2]error{c.Request().Context().Err(), err}
+		return nil
+	})
+	app.Get("/fast", func(c *Context) error {
+		c.Response().Header().Set("X-Fast", "true")
+		return c.String(http.StatusOK, "fast")
+	})
+
+	app.Get("/export", func(c *Context) error {
+		r, w := io.Pipe()
+		go func() {
+			w.Write([]byte("first,"))
+			time.Sleep(40 * time.Millisecond)
+			w.Write([]byte("second"))
+			w.Close()
+		}()
+		return c.Stream(http.StatusOK, "text/csv", r)
+	}).NoTimeout()
+
+	t.Run("handlers that return in time respond as usual", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/fast", nil)
+		if resp.StatusCode != http.StatusOK || resp.String() != "fast" {
+			t.Fatalf("expected the handler's response but got %d %s", resp.StatusCode, resp.String())
+		}
+		if resp.Header.Get("X-Fast") != "true" {
+			t.Fatal("expected the handler's headers to be written")
+		}
+	})
+
+	t.Run("the error page is rendered at the deadline", func(t *testing.T) {
+		// Turbo form submissions accept HTML as well as Turbo Streams.
+		resp := app.Invoke(http.MethodGet, "/stall", nil, InvokeOptions{Headers: map[string]string{
+			"Accept": TurboStreamMediaType + ", text/html",
+		}})
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected %d but got %d", http.StatusServiceUnavailable, resp.StatusCode)
+		}
+		if !strings.Contains(resp.String(), `<p class="error">503 The request took too long, please try again.</p>`) {
+			t.Fatalf("expected the error page but got %s", resp.String())
+		}
+
+		errs := <-stalled
+		if !errors.Is(errs[0], stdcontext.DeadlineExceeded) || !errors.Is(errs[1], http.ErrHandlerTimeout) {
+			t.Fatalf("expected the context to be cancelled and the late write to fail but got %v", errs)
+		}
+		if strings.Contains(resp.String(), "too late") {
+			t.Fatal("expected the late write to be discarded")
+		}
+	})
+
+	t.Run("exempt routes can stream past the deadline", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/export", nil)
+		if resp.StatusCode != http.StatusOK || resp.String() != "first,second" {
+			t.Fatalf("expected the whole stream but got %d %s", resp.StatusCode, resp.String())
+		}
+	})
+
+	t.Run("clients that accept JSON get problem details", func(t *testing.T) {
+		resp := app.Invoke(http.MethodGet, "/stall", nil, InvokeOptions{Headers: map[string]string{"Accept": "application/json"}})
+		<-stalled
+		if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Content-Type") != ProblemMediaType {
+			t.Fatalf("expected problem details but got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package seatbelt
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/go-seatbelt/seatbelt/render"
+)
+
+// TurboStreamMediaType is the content type of Turbo Stream responses.
+const TurboStreamMediaType = "text/vnd.turbo-stream.html"
+
+// WantsTurboStream returns true if the request accepts a Turbo Stream
+// response, i.e., a form submitted by Turbo.
+func (c *context) WantsTurboStream() bool {
+	for _, accept := range c.r.Header.Values("Accept") {
+		if strings.Contains(accept, TurboStreamMediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// A TurboStream builds a response of <turbo-stream> elements, each of which
+// performs an action on the element with the given target ID.
+type TurboStream struct {
+	c   *context
+	buf bytes.Buffer
+	err error
+}
+
+// TurboStream returns a new Turbo Stream response builder, i.e.,
+//
+//	func CreateComment(c *seatbelt.Context) error {
+//		return c.TurboStream().
+//			Append("comments", "comments/_comment", data).
+//			Update("comment_count", "comments/_count", data).
+//			Render()
+//	}
+//
+// Templates are rendered without a layout. If a template fails to render,
+// Render returns the error without writing a response.
+func (c *context) TurboStream() *TurboStream {
+	return &TurboStream{c: c}
+}
+
+// Append appends the rendered template to the target's children.
+func (ts *TurboStream) Append(target, name string, data map[string]interface{}) *TurboStream {
+	return ts.action("append", target, name, data)
+}
+
+// Prepend prepends the rendered template to the target's children.
+func (ts *TurboStream) Prepend(target, name string, data map[string]interface{}) *TurboStream {
+	return ts.action("prepend", target, name, data)
+}
+
+// Replace replaces the target with the rendered template.
+func (ts *TurboStream) Replace(target, name string, data map[string]interface{}) *TurboStream {
+	return ts.action("replace", target, name, data)
+}
+
+// Update replaces the target's children with the rendered template.
+func (ts *TurboStream) Update(target, name string, data map[string]interface{}) *TurboStream {
+	return ts.action("update", target, name, data)
+}
+
+// Remove removes the target.
+func (ts *TurboStream) Remove(target string) *TurboStream {
+	ts.buf.WriteString(`<turbo-stream action="remove" target="` + html.EscapeString(target) + `"></turbo-stream>`)
+	return ts
+}
+
+// action renders the template with the given name into a <turbo-stream>
+// element with the given action.
+func (ts *TurboStream) action(action, target, name string, data map[string]interface{}) *TurboStream {
+	if ts.err != nil {
+		return ts
+	}
+
+	content := &bytes.Buffer{}
+	if err := ts.c.RenderToWriter(content, name, data, render.RenderOptions{SkipLayout: true}); err != nil {
+		ts.err = err
+		return ts
+	}
+
+	ts.buf.WriteString(`<turbo-stream action="` + action + `" target="` + html.EscapeString(target) + `"><template>`)
+	content.WriteTo(&ts.buf)
+	ts.buf.WriteString(`</template></turbo-stream>`)
+	return ts
+}
+
+// Render writes the Turbo Stream response.
+func (ts *TurboStream) Render() error {
+	if ts.err != nil {
+		return ts.err
+	}
+	ts.c.w.Header().Set("Content-Type", TurboStreamMediaType+"; charset=utf-8")
+	ts.c.w.WriteHeader(http.StatusOK)
+	_, err := ts.buf.WriteTo(ts.c.w)
+	return err
+}
+
+// String returns the <turbo-stream> elements, i.e., to broadcast them over a
+// WebSocket.
+func (ts *TurboStream) String() string {
+	return ts.buf.String()
+}
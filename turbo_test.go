@@ -0,0 +1,47 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTurboStream(t *testing.T) {
+	app := New(Option{
+		TemplateDir:    filepath.Join("testdata", "templates"),
+		SkipServeFiles: true,
+	})
+
+	var wants bool
+	app.Get("/", func(c *Context) error {
+		wants = c.WantsTurboStream()
+		return c.TurboStream().
+			Append("users", "users/_row", map[string]interface{}{"name": "Ada"}).
+			Replace(`user"1`, "users/_row", map[string]interface{}{"name": "Grace"}).
+			Remove("user_2").
+			Render()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", TurboStreamMediaType+", text/html, application/xhtml+xml")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if !wants {
+		t.Fatalf("expected the request to want a Turbo Stream")
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, TurboStreamMediaType) {
+		t.Fatalf("expected %s but got %s", TurboStreamMediaType, ct)
+	}
+
+	expected := `<turbo-stream action="append" target="users"><template><li>Ada</li>
+</template></turbo-stream>` +
+		`<turbo-stream action="replace" target="user&#34;1"><template><li>Grace</li>
+</template></turbo-stream>` +
+		`<turbo-stream action="remove" target="user_2"></turbo-stream>`
+	if body := w.Body.String(); body != expected {
+		t.Fatalf("expected %s but got %s", expected, body)
+	}
+}
@@ -0,0 +1,184 @@
+package seatbelt
+
+import (
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-seatbelt/seatbelt/session"
+)
+
+// WatchdogOptions configure the watchdog, which reports the application's
+// memory, goroutine, and session counts, and warns when they exceed a
+// threshold, i.e., to catch leaks introduced by middleware.
+type WatchdogOptions struct {
+	// Path serves the current WatchdogReport as JSON. The report isn't
+	// meant for the public, so exclude the path from public listeners, see
+	// Listener.Exclude. Default is "", meaning the report isn't served.
+	Path string
+
+	// Interval is how often the thresholds are checked in the background.
+	// Checks stop when the application drains. Default is 0, meaning the
+	// thresholds are only checked when the report is served.
+	Interval time.Duration
+
+	// The thresholds above which a warning is reported. Default is 0,
+	// meaning the value is never reported.
+	MaxHeapBytes  uint64
+	MaxGoroutines int
+	MaxSessions   int
+
+	// OnWarning is called with the report when a value first exceeds its
+	// threshold, instead of logging a warning, i.e., in order to forward it
+	// to an alerting integration. It isn't called again until the value has
+	// dropped below its threshold.
+	OnWarning func(r WatchdogReport)
+}
+
+// A WatchdogReport is a snapshot of the application's resource usage.
+type WatchdogReport struct {
+	// The bytes and number of objects allocated on the heap.
+	HeapBytes   uint64 `json:"heap_bytes"`
+	HeapObjects uint64 `json:"heap_objects"`
+
+	// The number of goroutines that currently exist.
+	Goroutines int `json:"goroutines"`
+
+	// The number of sessions in the session store, or -1 if the store
+	// doesn't report its size, i.e., sessions saved in cookies.
+	Sessions int `json:"sessions"`
+
+	// The number of requests the application is currently serving.
+	InFlight int `json:"in_flight"`
+
+	// The values that exceed their threshold, i.e., "heap" or "goroutines".
+	Exceeded []string `json:"exceeded"`
+}
+
+// String returns a single line description of the report, i.e.,
+//
+//	512MB heap, 1200 goroutines, 80 sessions, 3 in flight (exceeded heap)
+func (r WatchdogReport) String() string {
+	s := formatBytes(r.HeapBytes) + " heap, " + strconv.Itoa(r.Goroutines) + " goroutines, "
+	if r.Sessions >= 0 {
+		s += strconv.Itoa(r.Sessions) + " sessions, "
+	}
+	s += strconv.Itoa(r.InFlight) + " in flight"
+	if len(r.Exceeded) > 0 {
+		s += " (exceeded " + strings.Join(r.Exceeded, ", ") + ")"
+	}
+	return s
+}
+
+// A sessionCounter is a session store that reports its size, i.e.,
+// session.MemoryStore.
+type sessionCounter interface {
+	Len() int
+}
+
+// watchdog checks the application's resource usage against the configured
+// thresholds.
+type watchdog struct {
+	opts     WatchdogOptions
+	store    session.Store
+	inFlight func() int
+
+	mu       sync.Mutex
+	exceeded map[string]bool
+}
+
+// newWatchdog returns the watchdog configured by the given options, or nil if
+// the watchdog is disabled.
+func newWatchdog(opts *WatchdogOptions, store session.Store, inFlight func() int) *watchdog {
+	if opts == nil {
+		return nil
+	}
+	return &watchdog{
+		opts:     *opts,
+		store:    store,
+		inFlight: inFlight,
+		exceeded: make(map[string]bool),
+	}
+}
+
+// report returns the current resource usage.
+func (wd *watchdog) report() WatchdogReport {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	r := WatchdogReport{
+		HeapBytes:   m.HeapAlloc,
+		HeapObjects: m.HeapObjects,
+		Goroutines:  runtime.NumGoroutine(),
+		Sessions:    -1,
+		InFlight:    wd.inFlight(),
+	}
+	if counter, ok := wd.store.(sessionCounter); ok {
+		r.Sessions = counter.Len()
+	}
+
+	if wd.opts.MaxHeapBytes > 0 && r.HeapBytes > wd.opts.MaxHeapBytes {
+		r.Exceeded = append(r.Exceeded, "heap")
+	}
+	if wd.opts.MaxGoroutines > 0 && r.Goroutines > wd.opts.MaxGoroutines {
+		r.Exceeded = append(r.Exceeded, "goroutines")
+	}
+	if wd.opts.MaxSessions > 0 && r.Sessions > wd.opts.MaxSessions {
+		r.Exceeded = append(r.Exceeded, "sessions")
+	}
+	return r
+}
+
+// check returns the current resource usage, and reports a warning if a value
+// newly exceeds its threshold.
+func (wd *watchdog) check() WatchdogReport {
+	r := wd.report()
+
+	wd.mu.Lock()
+	warn := false
+	exceeded := make(map[string]bool, len(r.Exceeded))
+	for _, name := range r.Exceeded {
+		exceeded[name] = true
+		warn = warn || !wd.exceeded[name]
+	}
+	wd.exceeded = exceeded
+	wd.mu.Unlock()
+
+	if !warn {
+		return r
+	}
+	if wd.opts.OnWarning != nil {
+		wd.opts.OnWarning(r)
+		return r
+	}
+	log.Printf("[warning] seatbelt: watchdog: %s", r)
+	return r
+}
+
+// Watchdog returns the application's current resource usage, and reports a
+// warning if a value newly exceeds its threshold. It returns false if the
+// watchdog isn't enabled, see Option.Watchdog.
+func (a *App) Watchdog() (WatchdogReport, bool) {
+	if a.watchdog == nil {
+		return WatchdogReport{}, false
+	}
+	return a.watchdog.check(), true
+}
+
+// formatBytes formats the given number of bytes with a binary unit, i.e.,
+// "512MB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatUint(n, 10) + "B"
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatUint(n/div, 10) + string("KMGTPE"[exp]) + "B"
+}
@@ -0,0 +1,126 @@
+package seatbelt
+
+import (
+	stdcontext "context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-seatbelt/seatbelt/session"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		app := New(Option{SkipServeFiles: true})
+		if _, ok := app.Watchdog(); ok {
+			t.Fatalf("expected watchdog to be disabled")
+		}
+	})
+
+	t.Run("warn once per threshold", func(t *testing.T) {
+		store := session.NewMemoryStore()
+		var warnings []WatchdogReport
+		app := New(Option{
+			SkipServeFiles: true,
+			SessionStore:   store,
+			Watchdog: &WatchdogOptions{
+				MaxSessions: 1,
+				OnWarning: func(r WatchdogReport) {
+					warnings = append(warnings, r)
+				},
+			},
+		})
+
+		r, ok := app.Watchdog()
+		if !ok {
+			t.Fatalf("expected watchdog to be enabled")
+		}
+		if r.Sessions != 0 || r.Goroutines == 0 || r.HeapBytes == 0 {
+			t.Fatalf("expected a report of the application's usage but got %+v", r)
+		}
+
+		store.Set("a", nil, time.Now().Add(time.Hour))
+		store.Set("b", nil, time.Now().Add(time.Hour))
+		app.Watchdog()
+		app.Watchdog()
+		if len(warnings) != 1 {
+			t.Fatalf("expected %d warnings but got %d", 1, len(warnings))
+		}
+		if len(warnings[0].Exceeded) != 1 || warnings[0].Exceeded[0] != "sessions" {
+			t.Fatalf("expected sessions to be exceeded but got %v", warnings[0].Exceeded)
+		}
+
+		// Once the value drops below its threshold, it is reported again
+		// when it exceeds it.
+		store.Delete("b")
+		app.Watchdog()
+		store.Set("b", nil, time.Now().Add(time.Hour))
+		app.Watchdog()
+		if len(warnings) != 2 {
+			t.Fatalf("expected %d warnings but got %d", 2, len(warnings))
+		}
+	})
+
+	t.Run("serve report", func(t *testing.T) {
+		app := New(Option{
+			SkipServeFiles: true,
+			Watchdog: &WatchdogOptions{
+				Path:          "/__watchdog",
+				MaxGoroutines: 1,
+				OnWarning:     func(r WatchdogReport) {},
+			},
+		})
+
+		resp := app.Invoke(http.MethodGet, "/__watchdog", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected %d but got %d", http.StatusOK, resp.StatusCode)
+		}
+		var r WatchdogReport
+		if err := resp.JSON(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Sessions != -1 || r.InFlight != 1 {
+			t.Fatalf("expected no sessions and 1 request in flight but got %+v", r)
+		}
+		if len(r.Exceeded) != 1 || r.Exceeded[0] != "goroutines" {
+			t.Fatalf("expected goroutines to be exceeded but got %v", r.Exceeded)
+		}
+	})
+
+	t.Run("stop on drain", func(t *testing.T) {
+		checked := make(chan WatchdogReport, 1)
+		app := New(Option{
+			SkipServeFiles: true,
+			Watchdog: &WatchdogOptions{
+				Interval:      time.Millisecond,
+				MaxGoroutines: 1,
+				OnWarning: func(r WatchdogReport) {
+					checked <- r
+				},
+			},
+		})
+
+		select {
+		case <-checked:
+		case <-time.After(time.Second):
+			t.Fatalf("expected a background check")
+		}
+		if err := app.Drain(stdcontext.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, expected := range map[uint64]string{
+		0:         "0B",
+		1023:      "1023B",
+		1024:      "1KB",
+		512 << 20: "512MB",
+		3 << 30:   "3GB",
+	} {
+		if actual := formatBytes(n); actual != expected {
+			t.Fatalf("expected %s but got %s", expected, actual)
+		}
+	}
+}
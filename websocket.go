@@ -0,0 +1,97 @@
+package seatbelt
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// A WSConn is a WebSocket connection established with App.WebSocket.
+type WSConn struct {
+	conn *websocket.Conn
+}
+
+// ReadText reads the next text or binary message from the connection.
+func (ws *WSConn) ReadText() (string, error) {
+	_, data, err := ws.conn.ReadMessage()
+	return string(data), err
+}
+
+// WriteText writes the given string as a text message.
+func (ws *WSConn) WriteText(s string) error {
+	return ws.conn.WriteMessage(websocket.TextMessage, []byte(s))
+}
+
+// ReadJSON reads the next message from the connection and decodes it into v.
+func (ws *WSConn) ReadJSON(v interface{}) error {
+	return ws.conn.ReadJSON(v)
+}
+
+// WriteJSON writes v as a JSON encoded text message.
+func (ws *WSConn) WriteJSON(v interface{}) error {
+	return ws.conn.WriteJSON(v)
+}
+
+// Conn returns the underlying *websocket.Conn from the
+// github.com/gorilla/websocket package.
+func (ws *WSConn) Conn() *websocket.Conn {
+	return ws.conn
+}
+
+// Close closes the connection without sending a close message.
+func (ws *WSConn) Close() error {
+	return ws.conn.Close()
+}
+
+// upgrader upgrades HTTP requests to WebSocket connections. Its default
+// CheckOrigin rejects handshakes with an Origin header that doesn't match the
+// request's Host, which protects against cross-site WebSocket hijacking the
+// same way CSRF tokens protect forms.
+var upgrader = websocket.Upgrader{}
+
+// WebSocket routes WebSocket handshakes at the given path to the given
+// handler. The handshake is a regular GET request that runs through the
+// application's middleware, so the request's session, values, and path params
+// are available on the Context as usual.
+//
+// The connection is closed once the handler returns. If the handler returns an
+// error other than the client closing the connection, the error is logged and
+// the connection is closed with an internal error status, as the response has
+// already been hijacked and can't be handled by the error handler, i.e.,
+//
+//	app.WebSocket("/echo", func(c *seatbelt.Context, ws *seatbelt.WSConn) error {
+//		for {
+//			msg, err := ws.ReadText()
+//			if err != nil {
+//				return err
+//			}
+//			if err := ws.WriteText(msg); err != nil {
+//				return err
+//			}
+//		}
+//	})
+//
+// WebSocket routes are exempt from Option.RequestTimeout, as connections
+// outlive the handshake.
+func (a *App) WebSocket(path string, handle func(c *Context, ws *WSConn) error) *Route {
+	return a.Get(path, func(c *Context) error {
+		conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			// The upgrader has already replied with an HTTP error.
+			log.Printf("seatbelt: websocket handshake failed: %v", err)
+			return nil
+		}
+		defer conn.Close()
+
+		err = handle(c, &WSConn{conn: conn})
+		if err == nil || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return nil
+		}
+
+		log.Printf("seatbelt: websocket handler error: %v", err)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, http.StatusText(http.StatusInternalServerError)))
+		return nil
+	}).NoTimeout()
+}
@@ -0,0 +1,66 @@
+package seatbelt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocket(t *testing.T) {
+	// WebSocket connections outlive the request timeout.
+	app := New(Option{SkipServeFiles: true, RequestTimeout: 10 * time.Millisecond})
+
+	app.WebSocket("/echo/{name}", func(c *Context, ws *WSConn) error {
+		for {
+			msg, err := ws.ReadText()
+			if err != nil {
+				return err
+			}
+			if err := c.Request().Context().Err(); err != nil {
+				return err
+			}
+			if err := ws.WriteText(c.PathParam("name") + ": " + msg); err != nil {
+				return err
+			}
+		}
+	})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/echo/seatbelt"
+
+	t.Run("messages are echoed", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		time.Sleep(30 * time.Millisecond)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "seatbelt: hello" {
+			t.Fatalf("expected seatbelt: hello but got %s", data)
+		}
+	})
+
+	t.Run("cross-origin handshakes are rejected", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+		if err == nil {
+			t.Fatal("expected handshake to fail")
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected %d but got %d", http.StatusForbidden, resp.StatusCode)
+		}
+	})
+}